@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AssetClassDefineRequest struct {
+	ClassId    string `form:"classid" binding:"required"`
+	Name       string `form:"name" binding:"required"`
+	Attributes string `form:"attributes" binding:"required"` // JSON 数组，如 [{"name":"principal","type":"number","required":true}]
+}
+
+// 定义资产类别，需要管理员身份
+func assetClassDefine(ctx *gin.Context) {
+	req := new(AssetClassDefineRequest)
+	// classId := args[0]
+	// name := args[1]
+	// attributes := args[2]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, "assetClassDefine", [][]byte{
+		[]byte(req.ClassId),
+		[]byte(req.Name),
+		[]byte(req.Attributes),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 查询资产类别
+func queryAssetClass(ctx *gin.Context) {
+	// classId := args[0]
+	classId := ctx.Param("id")
+
+	resp, err := channelQuery(ctx, "queryAssetClass", [][]byte{
+		[]byte(classId),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AssetUpdateRequest struct {
+	AssetName  string `form:"assetname"`                 // 可选，新的资产名称
+	Attributes string `form:"attributes"`                // 可选，新的属性 JSON 对象，整体替换，按资产类别校验
+	Reason     string `form:"reason" binding:"required"` // 修改原因，写入资产变更记录
+}
+
+// 修改资产的名称和属性，需要资产拥有者或开户员身份，修改前的值可按 update 类型查询资产变更历史
+func assetUpdate(ctx *gin.Context) {
+	req := new(AssetUpdateRequest)
+	// assetId := args[0]
+	// name := args[1]
+	// attributes := args[2]
+	// reason := args[3]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, "assetUpdate", [][]byte{
+		[]byte(ctx.Param("id")),
+		[]byte(req.AssetName),
+		[]byte(req.Attributes),
+		[]byte(req.Reason),
+	})
+
+	if err != nil {
+		ctx.String(errorStatus(err), err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuctionOpenRequest struct {
+	SellerId       string `form:"sellerid" binding:"required"`
+	AssetId        string `form:"assetsid" binding:"required"`
+	ReservePrice   string `form:"reserve_price" binding:"required"`   // 保留价，代币的最小单位
+	BidDeadline    string `form:"bid_deadline" binding:"required"`    // 出价截止时间 RFC3339
+	RevealDeadline string `form:"reveal_deadline" binding:"required"` // 揭示截止时间 RFC3339
+}
+
+// 发起拍卖，返回拍卖 id
+func auctionOpen(ctx *gin.Context) {
+	req := new(AuctionOpenRequest)
+	// sellerId := args[0]
+	// assetId := args[1]
+	// reservePrice := args[2]
+	// bidDeadline := args[3]
+	// revealDeadline := args[4]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, "auctionOpen", [][]byte{
+		[]byte(req.SellerId),
+		[]byte(req.AssetId),
+		[]byte(req.ReservePrice),
+		[]byte(req.BidDeadline),
+		[]byte(req.RevealDeadline),
+	})
+
+	if err != nil {
+		ctx.String(errorStatus(err), err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
+
+type AuctionBidRequest struct {
+	BidderId string `form:"bidderid" binding:"required"`
+	Hash     string `form:"hash"`   // 出价哈希，为空时由 amount 和 salt 计算
+	Amount   string `form:"amount"` // 出价金额，只用于本地计算哈希，不会发送到链上
+	Salt     string `form:"salt"`   // 至少 16 字节随机数的十六进制，揭示时需要原样提交
+}
+
+// 出价哈希，与链码中的计算方法相同
+func bidHash(auctionId, bidderId string, amount int64, salt string) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%s", auctionId, bidderId, amount, salt)))
+	return hex.EncodeToString(hash[:])
+}
+
+// 提交密封出价，返回出价哈希
+func auctionBid(ctx *gin.Context) {
+	req := new(AuctionBidRequest)
+	// auctionId := args[0]
+	// bidderId := args[1]
+	// hash := args[2]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	auctionId := ctx.Param("id")
+	hash := req.Hash
+	if hash == "" {
+		amount, err := strconv.ParseInt(req.Amount, 10, 64)
+		if err != nil || req.Salt == "" {
+			ctx.String(http.StatusBadRequest, "hash or amount and salt required")
+			return
+		}
+		// 与链码揭示时的校验相同，盐值太短时金额可以被穷举
+		if salt, err := hex.DecodeString(req.Salt); err != nil || len(salt) < 16 {
+			ctx.String(http.StatusBadRequest, "salt must be at least 16 random bytes hex encoded")
+			return
+		}
+		hash = bidHash(auctionId, req.BidderId, amount, req.Salt)
+	}
+
+	_, err := channelExecute(ctx, "auctionBid", [][]byte{
+		[]byte(auctionId),
+		[]byte(req.BidderId),
+		[]byte(hash),
+	})
+
+	if err != nil {
+		ctx.String(errorStatus(err), err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, hash)
+}
+
+type AuctionRevealRequest struct {
+	BidderId string `form:"bidderid" binding:"required"`
+	Amount   string `form:"amount" binding:"required"`
+	Salt     string `form:"salt" binding:"required"`
+}
+
+// 揭示出价
+func auctionReveal(ctx *gin.Context) {
+	req := new(AuctionRevealRequest)
+	// auctionId := args[0]
+	// bidderId := args[1]
+	// amount := args[2]
+	// salt := args[3]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, "auctionReveal", [][]byte{
+		[]byte(ctx.Param("id")),
+		[]byte(req.BidderId),
+		[]byte(req.Amount),
+		[]byte(req.Salt),
+	})
+
+	if err != nil {
+		ctx.String(errorStatus(err), err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 结算拍卖，得标者在其它组织时 form 表单中传入 private，见 privateTransient
+func auctionSettle(ctx *gin.Context) {
+	// auctionId := args[0]
+	transient, err := privateTransient(ctx.PostForm("private"))
+	if err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecuteTransient(ctx, "auctionSettle", [][]byte{
+		[]byte(ctx.Param("id")),
+	}, transient)
+
+	if err != nil {
+		ctx.String(errorStatus(err), err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 取消拍卖
+func auctionCancel(ctx *gin.Context) {
+	// auctionId := args[0]
+	resp, err := channelExecute(ctx, "auctionCancel", [][]byte{
+		[]byte(ctx.Param("id")),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 拍卖查询
+func queryAuction(ctx *gin.Context) {
+	// auctionId := args[0]
+	resp, err := channelQuery(ctx, "queryAuction", [][]byte{
+		[]byte(ctx.Param("id")),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
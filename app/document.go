@@ -0,0 +1,223 @@
+package main
+
+// 资产证明文件：文件保存在本地的内容寻址存储中，链上只存证文件的 SHA-256 等引用
+// 文件按哈希存放在 docStorePath/哈希前两位/哈希，相同内容只保存一份，通过 GET /documents/:hash 下载
+// 存证失败时已保存的文件不删除，相同文件再次上传时复用
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// 内容寻址存储的根目录
+	docStorePath = "./docstore"
+	// 单个文件的大小上限
+	maxDocumentSize int64 = 32 << 20
+)
+
+// 文件在存储中的路径，哈希已校验为 SHA-256 的十六进制小写
+func documentPath(hash string) string {
+	return filepath.Join(docStorePath, hash[:2], hash)
+}
+
+// 校验并规范化文件哈希
+func parseDocumentHash(hash string) (string, error) {
+	hash = strings.ToLower(hash)
+	if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid document hash, expect hex encoded sha256")
+	}
+
+	return hash, nil
+}
+
+// 计算上传文件的 SHA-256 并保存到存储中，先写临时文件，校验完成后改名，已存在时直接复用
+func storeDocument(fh *multipart.FileHeader) (string, error) {
+	src, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(docStorePath, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile(docStorePath, "upload-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), src); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	path := documentPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// 文件的媒体类型，上传时没有声明则按内容识别
+func documentMediaType(fh *multipart.FileHeader) string {
+	if mediaType := fh.Header.Get("Content-Type"); mediaType != "" && mediaType != "application/octet-stream" {
+		return mediaType
+	}
+
+	src, err := fh.Open()
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer src.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(src, head)
+	return http.DetectContentType(head[:n])
+}
+
+type DocumentUploadRequest struct {
+	OwnerId string `form:"ownerid" binding:"required"`
+}
+
+// 上传资产的证明文件并存证，multipart 表单：ownerid，file 为文件
+func assetDocumentUpload(ctx *gin.Context) {
+	req := new(DocumentUploadRequest)
+	// ownerId := args[0]
+	// assetId := args[1]
+	// hash := args[2]
+	// name := args[3]
+	// mediaType := args[4]
+	// size := args[5]
+	// uri := args[6]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+	fh, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+	if fh.Size <= 0 || fh.Size > maxDocumentSize {
+		ctx.AbortWithError(http.StatusRequestEntityTooLarge, fmt.Errorf("document size must be 1 to %d bytes", maxDocumentSize))
+		return
+	}
+
+	hash, err := storeDocument(fh)
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp, err := channelExecute(ctx, "assetAttachDocument", [][]byte{
+		[]byte(req.OwnerId),
+		[]byte(ctx.Param("id")),
+		[]byte(hash),
+		[]byte(filepath.Base(fh.Filename)),
+		[]byte(documentMediaType(fh)),
+		[]byte(strconv.FormatInt(fh.Size, 10)),
+		[]byte("/documents/" + hash),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
+
+// 资产的证明文件列表
+func queryAssetDocuments(ctx *gin.Context) {
+	// assetId := args[0]
+	resp, err := channelQuery(ctx, "queryAssetDocuments", [][]byte{
+		[]byte(ctx.Param("id")),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
+
+// 校验文件是否已在资产上存证，form 表单中传入哈希 hash，或者上传文件 file 由这里计算哈希
+func verifyAssetDocument(ctx *gin.Context) {
+	// assetId := args[0]
+	// hash := args[1]
+	hash := ctx.PostForm("hash")
+	if hash == "" {
+		fh, err := ctx.FormFile("file")
+		if err != nil {
+			ctx.AbortWithError(400, fmt.Errorf("hash or file is required"))
+			return
+		}
+		src, err := fh.Open()
+		if err != nil {
+			ctx.AbortWithError(400, err)
+			return
+		}
+		defer src.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, src); err != nil {
+			ctx.AbortWithError(400, err)
+			return
+		}
+		hash = hex.EncodeToString(h.Sum(nil))
+	}
+
+	resp, err := channelQuery(ctx, "verifyAssetDocument", [][]byte{
+		[]byte(ctx.Param("id")),
+		[]byte(hash),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
+
+// 从存储中下载文件
+func downloadDocument(ctx *gin.Context) {
+	hash, err := parseDocumentHash(ctx.Param("hash"))
+	if err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+	path := documentPath(hash)
+	if _, err := os.Stat(path); err != nil {
+		ctx.String(http.StatusNotFound, "document not found")
+		return
+	}
+
+	ctx.File(path)
+}
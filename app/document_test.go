@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// sha256("test")
+const testDocHash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+func TestParseDocumentHash(t *testing.T) {
+	tests := []struct {
+		hash    string
+		want    string
+		wantErr bool
+	}{
+		{testDocHash, testDocHash, false},
+		{strings.ToUpper(testDocHash), testDocHash, false},
+		{"", "", true},
+		{testDocHash[:62], "", true},
+		{testDocHash + "00", "", true},
+		{"zz" + testDocHash[2:], "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseDocumentHash(tt.hash)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected error, got %s", tt.hash, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q: got %s %v, want %s", tt.hash, got, err, tt.want)
+		}
+	}
+}
+
+// 构造 multipart 表单中的文件
+func newFileHeader(t *testing.T, name string, content []byte) *multipart.FileHeader {
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	part, err := w.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	w.Close()
+
+	form, err := multipart.NewReader(body, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return form.File["file"][0]
+}
+
+func TestStoreDocument(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path string) { docStorePath = path }(docStorePath)
+	docStorePath = dir
+
+	hash, err := storeDocument(newFileHeader(t, "contract.pdf", []byte("test")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != testDocHash {
+		t.Fatalf("got hash %s, want %s", hash, testDocHash)
+	}
+	path := filepath.Join(dir, testDocHash[:2], testDocHash)
+	if documentPath(hash) != path {
+		t.Fatalf("got path %s, want %s", documentPath(hash), path)
+	}
+	stored, err := ioutil.ReadFile(path)
+	if err != nil || string(stored) != "test" {
+		t.Fatalf("stored %q %v", stored, err)
+	}
+
+	// 相同内容再次上传复用已保存的文件，不留下临时文件
+	hash, err = storeDocument(newFileHeader(t, "copy.pdf", []byte("test")))
+	if err != nil || hash != testDocHash {
+		t.Fatalf("got %s %v", hash, err)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != testDocHash[:2] {
+		t.Fatalf("unexpected store entries %v", entries)
+	}
+	files, _ := ioutil.ReadDir(filepath.Dir(path))
+	if len(files) != 1 {
+		t.Fatalf("got %d stored files, want 1", len(files))
+	}
+
+	hash, err = storeDocument(newFileHeader(t, "other.pdf", []byte("other")))
+	if err != nil || hash == testDocHash {
+		t.Fatalf("got %s %v", hash, err)
+	}
+	if _, err := os.Stat(documentPath(hash)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+)
+
+// 资产或用户被冻结时，链码返回 423 状态码，这里原样返回 HTTP 423
+// 其它错误仍然按 200 返回错误内容，见 userRegister 中的说明
+// 交易提案发给多个节点时，各节点的错误合并为 MultipleErrors，逐个检查
+func errorStatus(err error) int {
+	s, ok := status.FromError(err)
+	if !ok {
+		return http.StatusOK
+	}
+	if s.Group == status.ChaincodeStatus && s.Code == http.StatusLocked {
+		return http.StatusLocked
+	}
+	if s.Group == status.ClientStatus && s.Code == status.MultipleErrors.ToInt32() {
+		for _, detail := range s.Details {
+			if e, ok := detail.(error); ok && errorStatus(e) == http.StatusLocked {
+				return http.StatusLocked
+			}
+		}
+	}
+
+	return http.StatusOK
+}
+
+type FreezeRequest struct {
+	Reason string `form:"reason" binding:"required"` // 原因代码：court_order aml sanctions dispute investigation other
+	Expiry string `form:"expiry"`                    // 可选，到期时间 RFC3339，为空表示直到解冻
+	Note   string `form:"note"`                      // 可选，reason 为 other 时必填
+}
+
+// 资产冻结，需要监管方身份（证书属性 regulator=true）
+func assetFreeze(ctx *gin.Context) {
+	freezeAction(ctx, "assetFreeze")
+}
+
+// 用户冻结，名下所有资产都不能转出
+func userFreeze(ctx *gin.Context) {
+	freezeAction(ctx, "userFreeze")
+}
+
+// 冻结的参数：path 中的资产或用户 id，form 表单中的原因代码、到期时间、备注
+func freezeAction(ctx *gin.Context, fcn string) {
+	req := new(FreezeRequest)
+	// id := args[0]
+	// reason := args[1]
+	// expiry := args[2]
+	// note := args[3]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, fcn, [][]byte{
+		[]byte(ctx.Param("id")),
+		[]byte(req.Reason),
+		[]byte(req.Expiry),
+		[]byte(req.Note),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 资产解冻，form 表单中可以带备注
+func assetUnfreeze(ctx *gin.Context) {
+	// assetId := args[0]
+	// note := args[1]
+	resp, err := channelExecute(ctx, "assetUnfreeze", [][]byte{
+		[]byte(ctx.Param("id")),
+		[]byte(ctx.PostForm("note")),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 用户解冻
+func userUnfreeze(ctx *gin.Context) {
+	// userId := args[0]
+	// note := args[1]
+	resp, err := channelExecute(ctx, "userUnfreeze", [][]byte{
+		[]byte(ctx.Param("id")),
+		[]byte(ctx.PostForm("note")),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
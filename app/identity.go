@@ -0,0 +1,97 @@
+package main
+
+// 调用者身份：每个请求以调用者自己在 fabric-ca 登记的身份提交交易，链码按证书的 MSP ID + Subject 校验是否为用户本人、签署人等
+// 请求通过 HTTP Basic 认证传入 enrollment id 和 secret，X-Org 请求头指定所在组织，默认为 org1
+// 第一次请求时向该组织的 fabric-ca enroll，证书和私钥保存在 config.yaml 的 credentialStore 中，之后只核对 secret
+// 身份由 fabric-ca 的管理员通过 fabric-ca-client register 登记，登记时可以带上 admin、regulator、issuer 属性
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+)
+
+const (
+	// 指定调用者所在组织的请求头
+	callerOrgHeader = "X-Org"
+
+	callerOrgKey = "caller_org"
+	callerIdKey  = "caller_id"
+)
+
+var (
+	// 可以登录的组织，对应 config.yaml 中 organizations 的 key，组织需要配置 certificateAuthorities
+	callerOrgs = map[string]bool{"org1": true, "org2": true}
+
+	// 已 enroll 的身份：组织/enrollment id -> secret 的 SHA-256
+	enrolledMu sync.Mutex
+	enrolled   = make(map[string][]byte)
+
+	// 向组织的 fabric-ca enroll 身份，证书保存在 SDK 的 credentialStore 中
+	enrollIdentity = func(org, id, secret string) error {
+		cli, err := msp.New(sdk.Context(), msp.WithOrg(org))
+		if err != nil {
+			return err
+		}
+
+		return cli.Enroll(id, msp.WithSecret(secret))
+	}
+)
+
+// 认证调用者，失败时返回 401
+func authenticate(ctx *gin.Context) {
+	id, secret, ok := ctx.Request.BasicAuth()
+	if !ok || id == "" || secret == "" {
+		ctx.Header("WWW-Authenticate", `Basic realm="assetsExchange"`)
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "enrollment id and secret required"})
+		return
+	}
+	callerOrg := ctx.GetHeader(callerOrgHeader)
+	if callerOrg == "" {
+		callerOrg = org
+	}
+	if !callerOrgs[callerOrg] {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("unknown org: %s", callerOrg)})
+		return
+	}
+
+	if err := checkEnrollment(callerOrg, id, secret); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Set(callerOrgKey, callerOrg)
+	ctx.Set(callerIdKey, id)
+	ctx.Next()
+}
+
+// 核对 secret，没有 enroll 过或者与上次不同时向 fabric-ca enroll
+func checkEnrollment(callerOrg, id, secret string) error {
+	key := callerOrg + "/" + id
+	hash := sha256.Sum256([]byte(secret))
+
+	enrolledMu.Lock()
+	defer enrolledMu.Unlock()
+
+	if known, ok := enrolled[key]; ok && subtle.ConstantTimeCompare(known, hash[:]) == 1 {
+		return nil
+	}
+	if err := enrollIdentity(callerOrg, id, secret); err != nil {
+		return fmt.Errorf("enroll %s in %s failed: %s", id, callerOrg, err)
+	}
+	enrolled[key] = hash[:]
+
+	return nil
+}
+
+// 以调用者身份访问通道
+func callerChannelContext(ctx *gin.Context) context.ChannelProvider {
+	return sdk.ChannelContext(channelName, fabsdk.WithOrg(ctx.GetString(callerOrgKey)), fabsdk.WithUser(ctx.GetString(callerIdKey)))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthenticate(t *testing.T) {
+	defer func(f func(org, id, secret string) error) { enrollIdentity = f }(enrollIdentity)
+	enrolls := 0
+	enrollIdentity = func(org, id, secret string) error {
+		enrolls++
+		if secret != id+"pw" {
+			return fmt.Errorf("authentication failure")
+		}
+		return nil
+	}
+	enrolled = make(map[string][]byte)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(authenticate)
+	router.GET("/whoami", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, ctx.GetString(callerOrgKey)+"/"+ctx.GetString(callerIdKey))
+	})
+
+	tests := []struct {
+		name        string
+		id          string
+		secret      string
+		org         string
+		wantStatus  int
+		wantBody    string
+		wantEnrolls int
+	}{
+		{"no credentials", "", "", "", http.StatusUnauthorized, "", 0},
+		{"unknown org", "alice", "alicepw", "org9", http.StatusUnauthorized, "", 0},
+		{"wrong secret", "alice", "bobpw", "", http.StatusUnauthorized, "", 1},
+		{"default org", "alice", "alicepw", "", http.StatusOK, "org1/alice", 2},
+		// 已 enroll 的身份只核对 secret
+		{"cached", "alice", "alicepw", "org1", http.StatusOK, "org1/alice", 2},
+		{"cached wrong secret", "alice", "bobpw", "org1", http.StatusUnauthorized, "", 3},
+		// 同名身份在不同组织是不同的身份
+		{"other org", "alice", "alicepw", "org2", http.StatusOK, "org2/alice", 4},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+		if tt.id != "" {
+			req.SetBasicAuth(tt.id, tt.secret)
+		}
+		if tt.org != "" {
+			req.Header.Set(callerOrgHeader, tt.org)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: got status %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+		if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+			t.Errorf("%s: got %q, want %q", tt.name, w.Body.String(), tt.wantBody)
+		}
+		if enrolls != tt.wantEnrolls {
+			t.Errorf("%s: %d enrolls, want %d", tt.name, enrolls, tt.wantEnrolls)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 资产账本历史查询，返回资产每个版本的值、交易 id、时间戳和是否删除
+func queryAssetLedgerHistory(ctx *gin.Context) {
+	// assetId := args[0]
+	assetId := ctx.Param("id")
+
+	resp, err := channelQuery(ctx, "queryAssetLedgerHistory", [][]byte{
+		[]byte(assetId),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
+
+// 用户账本历史查询
+func queryUserLedgerHistory(ctx *gin.Context) {
+	// userId := args[0]
+	userId := ctx.Param("id")
+
+	resp, err := channelQuery(ctx, "queryUserLedgerHistory", [][]byte{
+		[]byte(userId),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
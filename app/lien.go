@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LienRegisterRequest struct {
+	HolderId string `form:"holderid" binding:"required"` // 质权人
+	Amount   string `form:"amount" binding:"required"`   // 担保金额，代币的最小单位，整数
+	Priority string `form:"priority" binding:"required"` // 受偿顺位，从 1 开始
+	Expiry   string `form:"expiry"`                      // 可选，到期时间 RFC3339
+}
+
+// 质权登记，返回质权 id，质权人确认后生效
+func lienRegister(ctx *gin.Context) {
+	req := new(LienRegisterRequest)
+	// assetId := args[0]
+	// holderId := args[1]
+	// amount := args[2]
+	// priority := args[3]
+	// expiry := args[4]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, "lienRegister", [][]byte{
+		[]byte(ctx.Param("id")),
+		[]byte(req.HolderId),
+		[]byte(req.Amount),
+		[]byte(req.Priority),
+		[]byte(req.Expiry),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
+
+// 资产的质权查询，默认返回有效和待确认的质权，?all=true 时包括已解除和已到期的质权
+func queryAssetLiens(ctx *gin.Context) {
+	// assetId := args[0]
+	// all := args[1]
+	args := [][]byte{
+		[]byte(ctx.Param("id")),
+	}
+	if ctx.Query("all") == "true" {
+		args = append(args, []byte("all"))
+	}
+
+	resp, err := channelQuery(ctx, "queryAssetLiens", args)
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
+
+// 质权人确认质权
+func lienAccept(ctx *gin.Context) {
+	// assetId := args[0]
+	// lienId := args[1]
+	resp, err := channelExecute(ctx, "lienAccept", [][]byte{
+		[]byte(ctx.Param("id")),
+		[]byte(ctx.Param("lienid")),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 质权解除，由质权人操作，也用于拒绝待确认的质权
+func lienRelease(ctx *gin.Context) {
+	// assetId := args[0]
+	// lienId := args[1]
+	resp, err := channelExecute(ctx, "lienRelease", [][]byte{
+		[]byte(ctx.Param("id")),
+		[]byte(ctx.Param("lienid")),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 质权人同意把资产转给 form 表单中的 recipientid，为空时撤回同意
+func lienApprove(ctx *gin.Context) {
+	// assetId := args[0]
+	// lienId := args[1]
+	// recipientId := args[2]
+	resp, err := channelExecute(ctx, "lienApprove", [][]byte{
+		[]byte(ctx.Param("id")),
+		[]byte(ctx.Param("lienid")),
+		[]byte(ctx.PostForm("recipientid")),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
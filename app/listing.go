@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ListingRequest struct {
+	Price      string `form:"price" binding:"required"`       // 要价，代币的最小单位
+	ValidFrom  string `form:"valid_from"`                     // 可选，有效期开始 RFC3339，为空时立即生效
+	ValidUntil string `form:"valid_until" binding:"required"` // 有效期结束 RFC3339
+}
+
+type ListingCreateRequest struct {
+	ListingRequest
+	SellerId string `form:"sellerid" binding:"required"`
+	AssetId  string `form:"assetsid" binding:"required"`
+}
+
+// 挂牌出售资产，返回挂牌 id
+func listingCreate(ctx *gin.Context) {
+	req := new(ListingCreateRequest)
+	// sellerId := args[0]
+	// assetId := args[1]
+	// price := args[2]
+	// validFrom := args[3]
+	// validUntil := args[4]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, "listingCreate", [][]byte{
+		[]byte(req.SellerId),
+		[]byte(req.AssetId),
+		[]byte(req.Price),
+		[]byte(req.ValidFrom),
+		[]byte(req.ValidUntil),
+	})
+
+	if err != nil {
+		ctx.String(errorStatus(err), err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
+
+// 修改挂牌的要价和有效期
+func listingUpdate(ctx *gin.Context) {
+	req := new(ListingRequest)
+	// listingId := args[0]
+	// price := args[1]
+	// validFrom := args[2]
+	// validUntil := args[3]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, "listingUpdate", [][]byte{
+		[]byte(ctx.Param("id")),
+		[]byte(req.Price),
+		[]byte(req.ValidFrom),
+		[]byte(req.ValidUntil),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 撤销挂牌
+func listingCancel(ctx *gin.Context) {
+	// listingId := args[0]
+	resp, err := channelExecute(ctx, "listingCancel", [][]byte{
+		[]byte(ctx.Param("id")),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 按挂牌价格购买，form 表单中的 buyerid 为买方
+func listingPurchase(ctx *gin.Context) {
+	// listingId := args[0]
+	// buyerId := args[1]
+	buyerId := ctx.PostForm("buyerid")
+	if buyerId == "" {
+		ctx.String(http.StatusBadRequest, "buyerid required")
+		return
+	}
+	transient, err := privateTransient(ctx.PostForm("private"))
+	if err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecuteTransient(ctx, "listingPurchase", [][]byte{
+		[]byte(ctx.Param("id")),
+		[]byte(buyerId),
+	}, transient)
+
+	if err != nil {
+		ctx.String(errorStatus(err), err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 挂牌查询
+func queryListing(ctx *gin.Context) {
+	// listingId := args[0]
+	resp, err := channelQuery(ctx, "queryListing", [][]byte{
+		[]byte(ctx.Param("id")),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
+
+type ListingsQueryRequest struct {
+	ClassId   string `form:"class"`
+	SellerId  string `form:"seller"`
+	MinPrice  int64  `form:"min_price"`
+	MaxPrice  int64  `form:"max_price"`
+	PageSize  string `form:"page_size"`
+	PageToken string `form:"page_token"` // 上一页返回的 bookmark
+}
+
+// 在售挂牌查询，需要 CouchDB 作为状态数据库
+func queryListings(ctx *gin.Context) {
+	req := new(ListingsQueryRequest)
+	// query := args[0]
+	// pageSize := args[1]
+	// bookmark := args[2]
+	if err := ctx.ShouldBindQuery(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	queryBytes, err := json.Marshal(map[string]interface{}{
+		"class_id":  req.ClassId,
+		"seller_id": req.SellerId,
+		"min_price": req.MinPrice,
+		"max_price": req.MaxPrice,
+	})
+	if err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelQuery(ctx, "queryListings", [][]byte{
+		queryBytes,
+		[]byte(req.PageSize),
+		[]byte(req.PageToken),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	// 返回的 bookmark 作为下一页的 page_token
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
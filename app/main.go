@@ -18,22 +18,88 @@ import (
 
 func main() {
 	router := gin.Default()
+	// 每个请求都要通过 HTTP Basic 认证，以调用者在 fabric-ca 登记的身份提交交易
+	router.Use(authenticate)
 	// 定义路由， RESTful 一套web服务标准 
 	{
 		router.POST("/users", userRegister)	//用户注册
+		router.GET("/users", listUsers) //用户列表
 		router.GET("/users/:id", queryUser) //查询用户信息
+		router.POST("/users/:id/freeze", userFreeze) //用户冻结
+		router.POST("/users/:id/unfreeze", userUnfreeze) //用户解冻
+		router.GET("/users/:id/balance", balanceOf) //代币余额查询
+		router.PUT("/users/:id/signers", userSetSigners) //设置多签签署人和门限
+		router.PUT("/users/:id/profile", updateUserProfile) //修改用户资料和 KYC 状态
+		router.GET("/users/:id/profile/history", queryUserProfileHistory) //用户资料变更记录查询，包括冻结和解冻
+		router.GET("/roles", queryRoles) //角色查询
+		router.POST("/roles/grant", grantRole) //授予角色
+		router.POST("/roles/revoke", revokeRole) //撤销角色
+		router.GET("/acl/:fcn", queryAcl) //方法 ACL 查询
+		router.PUT("/acl/:fcn", setAcl) //设置方法 ACL
+		router.POST("/tokens/mint", tokenMint) //铸造代币
+		router.POST("/tokens/transfer", tokenTransfer) //代币转账
+		router.POST("/market/listings", listingCreate) //挂牌出售
+		router.GET("/market/listings", queryListings) //在售挂牌查询
+		router.GET("/market/listings/:id", queryListing) //挂牌查询
+		router.PUT("/market/listings/:id", listingUpdate) //修改挂牌
+		router.DELETE("/market/listings/:id", listingCancel) //撤销挂牌
+		router.POST("/market/listings/:id/purchase", listingPurchase) //按挂牌价格购买
+		router.POST("/market/auctions", auctionOpen) //发起拍卖
+		router.GET("/market/auctions/:id", queryAuction) //拍卖查询
+		router.POST("/market/auctions/:id/bids", auctionBid) //提交密封出价
+		router.POST("/market/auctions/:id/reveal", auctionReveal) //揭示出价
+		router.POST("/market/auctions/:id/settle", auctionSettle) //结算拍卖
+		router.POST("/market/auctions/:id/cancel", auctionCancel) //取消拍卖
 		router.DELETE("/users/:id", deleteUser) //删除用户
-		router.GET("/asset/get/:id", queryAsset) //资产查询
-		router.GET("/asset/exchange/history", assetsExchangeHistory) //资产变更历史查询
-		router.POST("/asset/enroll", assetsEnroll) //资产登记
-		router.POST("/asset/exchange", assetsExchange) //资产转让
+		router.GET("/users/:id/ledger-history", queryUserLedgerHistory) //用户账本历史查询
+		router.GET("/asset/:id", queryAsset) //资产查询
+		router.GET("/asset/:id/private", queryAssetPrivate) //资产保密部分查询
+		router.POST("/asset/:id/freeze", assetFreeze) //资产冻结
+		router.POST("/asset/:id/unfreeze", assetUnfreeze) //资产解冻
+		router.GET("/asset/:id/liens", queryAssetLiens) //资产质权查询
+		router.POST("/asset/:id/liens", lienRegister) //质权登记
+		router.POST("/asset/:id/liens/:lienid/accept", lienAccept) //质权人确认质权
+		router.POST("/asset/:id/liens/:lienid/release", lienRelease) //质权解除
+		router.POST("/asset/:id/liens/:lienid/approve", lienApprove) //质权人同意转让
+		router.POST("/asset/:id/units", unitsTransfer) //份额转让
+		router.POST("/assets/pools", poolBundle) //资产打包为资产池
+		router.POST("/assets/pools/:id/unbundle", poolUnbundle) //资产池拆包
+		router.GET("/asset/:id/documents", queryAssetDocuments) //资产证明文件列表
+		router.POST("/asset/:id/documents", assetDocumentUpload) //上传证明文件并存证
+		router.POST("/asset/:id/documents/verify", verifyAssetDocument) //校验证明文件是否已存证
+		router.GET("/documents/:hash", downloadDocument) //下载证明文件
+		router.GET("/asset/:id/ledger-history", queryAssetLedgerHistory) //资产账本历史查询，含属性修改和删除
+		router.GET("/assets", queryAssets) //资产富查询
+		router.GET("/assets/list", listAssets) //资产列表
+		router.GET("/assets/exchange/history", assetsExchangeHistory) //资产变更历史查询
+		router.POST("/assets/enroll", assetsEnroll) //资产登记
+		router.PATCH("/asset/:id", assetUpdate) //修改资产名称和属性
+		router.POST("/assets/classes", assetClassDefine) //定义资产类别
+		router.GET("/assets/classes/:id", queryAssetClass) //查询资产类别
+		router.POST("/assets/exchange", assetsExchange) //资产转让
+		router.POST("/assets/exchange/offers", transferOffer) //发起转让要约
+		router.GET("/assets/exchange/offers/:id", queryTransferOffer) //查询转让要约
+		router.POST("/assets/exchange/offers/:id/accept", transferAccept) //接受转让要约
+		router.POST("/assets/exchange/offers/:id/buy", buyAsset) //按要约价格购买资产
+		router.POST("/assets/exchange/offers/:id/reject", transferReject) //拒绝转让要约
+		router.POST("/assets/exchange/offers/:id/cancel", transferCancel) //撤回转让要约
+		router.GET("/assets/exchange/pending/:id", queryPendingTransfer) //查询待签转让
+		router.POST("/assets/exchange/pending/:id/approve", multisigApprove) //签署人同意待签转让
+		router.POST("/assets/exchange/pending/:id/reject", multisigReject) //签署人拒绝待签转让
+		router.POST("/assets/exchange/pending/:id/expire", multisigExpire) //过期的待签转让写入账本
+		router.POST("/assets/swap", swapPropose) //发起资产互换
+		router.GET("/assets/swap/:id", querySwap) //查询资产互换
+		router.POST("/assets/swap/:id/accept", swapAccept) //接受资产互换
+		router.POST("/assets/swap/:id/cancel", swapCancel) //取消资产互换
 	}
 	router.Run()
 }
 
 type UserRegisterRequest struct {
-	Id   string `form:"id" binding:"required"`
-	Name string `form:"name" binding:"required"`
+	Id      string `form:"id" binding:"required"`
+	Name    string `form:"name" binding:"required"`
+	MspId   string `form:"mspid"`   // 可选，开户员为其它身份开户时传入该身份的 MSP ID
+	Subject string `form:"subject"` // 可选，该身份证书的 Subject，与 mspid 同时传入
 }
 
 // 用户开户
@@ -41,6 +107,8 @@ func userRegister(ctx *gin.Context) {
 	// 参数处理 
 	// name := args[0]
 	// id := args[1]
+	// mspId := args[2]
+	// subject := args[3]
 	
 	req := new(UserRegisterRequest)
 	if err := ctx.ShouldBind(req); err != nil {
@@ -48,11 +116,16 @@ func userRegister(ctx *gin.Context) {
 		return
 	}
 
-	// 区块链交互
-	resp, err := channelExecute("userRegister", [][]byte{
+	args := [][]byte{
 		[]byte(req.Name),
 		[]byte(req.Id),
-	})
+	}
+	if req.MspId != "" || req.Subject != "" {
+		args = append(args, []byte(req.MspId), []byte(req.Subject))
+	}
+
+	// 区块链交互
+	resp, err := channelExecute(ctx, "userRegister", args)
 	
 	// 因为 postman 对于 非200-300 直接的错误，会直接返回错误编号，而不显示错误内容
 	// 所以此处通过 200 直接返回，并显示错误内容
@@ -70,7 +143,7 @@ func queryUser(ctx *gin.Context) {
 	// ownerId := args[0]
 	userId := ctx.Param("id")
 
-	resp, err := channelQuery("queryUser", [][]byte{
+	resp, err := channelQuery(ctx, "queryUser", [][]byte{
 		[]byte(userId),
 	})
 
@@ -85,16 +158,30 @@ func queryUser(ctx *gin.Context) {
 }
 
 // 用户销户
+// 名下仍有资产时需要通过 successor 参数指定承接人，否则链码拒绝销户
+// 承接人在其它组织时通过 private 参数传入资产的保密部分，见 privateTransient
 func deleteUser(ctx *gin.Context) {
 	// id := args[0]
+	// successorId := args[1]
 	userId := ctx.Param("id")
+	successorId := ctx.Query("successor") // 可为空
+	transient, err := privateTransient(ctx.Query("private"))
+	if err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
 
-	resp, err := channelExecute("userDestroy", [][]byte{
+	args := [][]byte{
 		[]byte(userId),
-	})
+	}
+	if successorId != "" {
+		args = append(args, []byte(successorId))
+	}
+
+	resp, err := channelExecuteTransient(ctx, "userDestroy", args, transient)
 
 	if err != nil {
-		ctx.String(http.StatusOK, err.Error())
+		ctx.String(errorStatus(err), err.Error())
 		return
 	}
 
@@ -106,7 +193,7 @@ func queryAsset(ctx *gin.Context) {
 	// assetId := args[0]
 	assetId := ctx.Param("id")
 
-	resp, err := channelQuery("queryAsset", [][]byte{
+	resp, err := channelQuery(ctx, "queryAsset", [][]byte{
 		[]byte(assetId),
 	})
 
@@ -120,10 +207,13 @@ func queryAsset(ctx *gin.Context) {
 }
 
 type AssetsEnrollRequest struct {
-	AssetName string `form:"assetname" binding:"required"`
-	AssetId   string `form:"assetsid" binding:"required"`
-	Metadata  string `form:"metadata" binding:"required"`
-	OwnerId   string `form:"ownerid" binding:"required"`
+	AssetName  string `form:"assetname" binding:"required"`
+	AssetId    string `form:"assetsid" binding:"required"`
+	ClassId    string `form:"classid" binding:"required"`
+	Attributes string `form:"attributes"` // JSON 对象，按资产类别校验，如 {"brand":"BYD","seats":5}
+	OwnerId    string `form:"ownerid" binding:"required"`
+	Private    string `form:"private"` // 可选，资产的保密部分 JSON，如 {"debtor_name":"x","outstanding_balance":1000}，通过 transient 传递
+	Units      string `form:"units"`   // 可选，份额总数，按份额持有时登记者持有全部份额
 }
 
 // 资产登记
@@ -132,19 +222,31 @@ func assetsEnroll(ctx *gin.Context) {
 	// 参数在 form 表单中，用 ShouldBind() 方法来提取参数
 	// assetName := args[0]
 	// assetId := args[1]
-	// metadata := args[2]
-	// ownerId := args[3]
+	// classId := args[2]
+	// attributes := args[3]
+	// ownerId := args[4]
+	// units := args[5]
 	if err := ctx.ShouldBind(req); err != nil {
 		ctx.AbortWithError(400, err)
 		return
 	}
 
-	resp, err := channelExecute("assetEnroll", [][]byte{
+	// 保密部分不能作为参数传递，参数会写入区块
+	var transient map[string][]byte
+	if req.Private != "" {
+		transient = map[string][]byte{
+			assetPrivateTransientKey: []byte(req.Private),
+		}
+	}
+
+	resp, err := channelExecuteTransient(ctx, "assetEnroll", [][]byte{
 		[]byte(req.AssetName),
 		[]byte(req.AssetId),
-		[]byte(req.Metadata),
+		[]byte(req.ClassId),
+		[]byte(req.Attributes),
 		[]byte(req.OwnerId),
-	})
+		[]byte(req.Units),
+	}, transient)
 
 	if err != nil {
 		ctx.String(http.StatusOK, err.Error())
@@ -158,27 +260,36 @@ type AssetsExchangeRequest struct {
 	OriginOwnerId  string `form:"originownerid" binding:"required"`
 	AssetId        string `form:"assetsid" binding:"required"`
 	CurrentOwnerId string `form:"currentownerid" binding:"required"`
+	Expiry         string `form:"expiry"`  // 可选，出让者设置了多签时待签转让的过期时间 RFC3339
+	Private        string `form:"private"` // 可选，受让者在其它组织时资产的保密部分，见 privateTransient
 }
 
-// 资产转让/交易
+// 资产转让/交易，出让者设置了多签时返回待签转让 id
 func assetsExchange(ctx *gin.Context) {
 	req := new(AssetsExchangeRequest)
 	// ownerId := args[0]
 	// assetId := args[1]
 	// currentOwnerId := args[2]
+	// expiry := args[3]
 	if err := ctx.ShouldBind(req); err != nil {
 		ctx.AbortWithError(400, err)
 		return
 	}
+	transient, err := privateTransient(req.Private)
+	if err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
 
-	resp, err := channelExecute("assetExchange", [][]byte{
+	resp, err := channelExecuteTransient(ctx, "assetExchange", [][]byte{
 		[]byte(req.OriginOwnerId),
 		[]byte(req.AssetId),
 		[]byte(req.CurrentOwnerId),
-	})
+		[]byte(req.Expiry),
+	}, transient)
 
 	if err != nil {
-		ctx.String(http.StatusOK, err.Error())
+		ctx.String(errorStatus(err), err.Error())
 		return
 	}
 
@@ -187,15 +298,21 @@ func assetsExchange(ctx *gin.Context) {
 
 // 资产历史变更记录
 func assetsExchangeHistory(ctx *gin.Context) {
-	// 参数的个数,可以有1个或2个
+	// 参数的个数,可以有1到4个
 	// assetId := args[0]
-	// queryType = args[1]  {"all" "enroll" "exchange"}
+	// queryType = args[1]  {"all" "enroll" "exchange" "freeze" "pool" "update" "multisig"}
+	// pageSize := args[2]
+	// bookmark := args[3]
 	assetId := ctx.Query("assetid")
 	queryType := ctx.Query("querytype") // 可为空
+	pageSize := ctx.Query("page_size")  // 可为空
+	bookmark := ctx.Query("bookmark")   // 可为空，上一页返回的 bookmark
 
-	resp, err := channelQuery("queryAssetHistory", [][]byte{
+	resp, err := channelQuery(ctx, "queryAssetHistory", [][]byte{
 		[]byte(assetId),
 		[]byte(queryType),
+		[]byte(pageSize),
+		[]byte(bookmark),
 	})
 
 	if err != nil {
@@ -213,8 +330,13 @@ var (
 	channelName   = "mychannel"
 	chaincodeName = "assetscc"
 	org           = "org1"	// 对应了 configtx.yaml 文件的160行
-	user          = "Admin"
+	user          = "Admin"	// 只用于通道管理，业务交易以调用者自己的身份提交，见 identity.go
 	configPath = "./config.yaml"
+	// 链码事件名的正则过滤器，链码发出的事件均为大写字母开头的驼峰名称，如 AssetTransferred
+	chaincodeEventFilter = "^[A-Z][A-Za-z]+$"
+	// 交易提案发给各组织的节点，资产键的背书策略要求资产拥有者组织背书
+	// 加入 Org3 后需要在 config.yaml 中配置 peer0.org3.example.com 并加到这里
+	endorsingPeers = []string{"peer0.org1.example.com", "peer0.org2.example.com"}
 )
 
 // 初始化 SDK，需要用到 配置文件：config.yaml
@@ -269,31 +391,41 @@ func queryBlockchain() {
 }
 
 // 区块链交互
-func channelExecute(fcn string, args [][]byte) (channel.Response, error) {
-	ctx := sdk.ChannelContext(channelName, fabsdk.WithOrg(org), fabsdk.WithUser(user))
+// 交易以调用者在 fabric-ca 登记的身份提交，见 identity.go
+func channelExecute(ctx *gin.Context, fcn string, args [][]byte) (channel.Response, error) {
+	return channelExecuteTransient(ctx, fcn, args, nil)
+}
+
+// 带 transient 数据的交易，transient 数据只发给背书节点，不会写入区块，用于传递私有数据
+func channelExecuteTransient(ctx *gin.Context, fcn string, args [][]byte, transient map[string][]byte) (channel.Response, error) {
+	chctx := callerChannelContext(ctx)
 
-	cli, err := channel.New(ctx)
+	cli, err := channel.New(chctx)
+	if err != nil {
+		return channel.Response{}, err
+	}
+
+	// 链码事件监听，在发送交易之前注册，避免错过本交易的事件
+	// 事件名和负载格式见链码的 events.go
+	reg, ccevt, err := cli.RegisterChaincodeEvent(chaincodeName, chaincodeEventFilter)
 	if err != nil {
 		return channel.Response{}, err
 	}
 	
 	// 状态更新，insert/update/delete
 	resp, err := cli.Execute(channel.Request{
-		ChaincodeID: chaincodeName,
-		Fcn:         fcn,
-		Args:        args,
-	}, channel.WithTargetEndpoints("peer0.org1.example.com"))
+		ChaincodeID:  chaincodeName,
+		Fcn:          fcn,
+		Args:         args,
+		TransientMap: transient,
+	}, channel.WithTargetEndpoints(endorsingPeers...))
 	if err != nil {
+		cli.UnregisterChaincodeEvent(reg)
 		return channel.Response{}, err
 	}
 
-	// 链码事件监听
 	go func() {
 		// channel 
-		reg, ccevt, err := cli.RegisterChaincodeEvent(chaincodeName, "eventname")
-		if err != nil {
-			return
-		}
 		defer cli.UnregisterChaincodeEvent(reg)
 
 		timeoutctx, cancel := context.WithTimeout(context.Background(), time.Minute)
@@ -302,7 +434,12 @@ func channelExecute(fcn string, args [][]byte) (channel.Response, error) {
 		for {
 			select {
 			case evt := <-ccevt:
-				fmt.Printf("received event of tx %s: %+v", resp.TransactionID, evt)
+				// 过滤器会收到所有交易的事件，只处理本交易的
+				if evt.TxID != string(resp.TransactionID) {
+					continue
+				}
+				fmt.Printf("received event %s of tx %s: %s\n", evt.EventName, evt.TxID, evt.Payload)
+				return
 			case <-timeoutctx.Done():
 				fmt.Println("event timeout, exit!")
 				return
@@ -314,14 +451,14 @@ func channelExecute(fcn string, args [][]byte) (channel.Response, error) {
 		// if err != nil {
 		// 	return
 		// }
-		// eventcli.RegisterChaincodeEvent(chaincodeName, "eventname")
+		// eventcli.RegisterChaincodeEvent(chaincodeName, chaincodeEventFilter)
 		// ... same as channel moudle
 		// 
 	}()
 
 	// 交易状态事件监听
 	go func() {
-		eventcli, err := event.New(ctx)
+		eventcli, err := event.New(chctx)
 		if err != nil {
 			return
 		}
@@ -346,10 +483,8 @@ func channelExecute(fcn string, args [][]byte) (channel.Response, error) {
 	return resp, nil
 }
 
-func channelQuery(fcn string, args [][]byte) (channel.Response, error) {
-	ctx := sdk.ChannelContext(channelName, fabsdk.WithOrg(org), fabsdk.WithUser(user))
-
-	cli, err := channel.New(ctx)
+func channelQuery(ctx *gin.Context, fcn string, args [][]byte) (channel.Response, error) {
+	cli, err := channel.New(callerChannelContext(ctx))
 	if err != nil {
 		return channel.Response{}, err
 	}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserSignersRequest struct {
+	Signers   string `form:"signers"`                      // 签署人 JSON 数组，如 [{"name":"cfo","msp_id":"Org1MSP","subject":"CN=cfo,OU=client"}]，subject 为签署人 fabric-ca 证书的 Subject
+	Threshold string `form:"threshold" binding:"required"` // 门限，0 且签署人为空时取消多签
+}
+
+// 设置用户的多签签署人和门限
+func userSetSigners(ctx *gin.Context) {
+	req := new(UserSignersRequest)
+	// userId := args[0]
+	// signers := args[1]
+	// threshold := args[2]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+	if req.Signers == "" {
+		req.Signers = "[]"
+	}
+
+	resp, err := channelExecute(ctx, "userSetSigners", [][]byte{
+		[]byte(ctx.Param("id")),
+		[]byte(req.Signers),
+		[]byte(req.Threshold),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 签署人同意待签转让，达到门限时资产转让
+// 签署人以自己在 fabric-ca 登记的身份认证后调用，链码按证书的 MSP ID + Subject 计票，见 identity.go
+// 受让者在其它组织时，最后一个签署人在 form 表单中传入 private，见 privateTransient
+func multisigApprove(ctx *gin.Context) {
+	// pendingId := args[0]
+	transient, err := privateTransient(ctx.PostForm("private"))
+	if err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecuteTransient(ctx, "multisigApprove", [][]byte{
+		[]byte(ctx.Param("id")),
+	}, transient)
+
+	if err != nil {
+		ctx.String(errorStatus(err), err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 签署人拒绝待签转让，同样以签署人自己的身份调用
+func multisigReject(ctx *gin.Context) {
+	// pendingId := args[0]
+	resp, err := channelExecute(ctx, "multisigReject", [][]byte{
+		[]byte(ctx.Param("id")),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 把已过期的待签转让标记为过期，写入资产变更记录
+func multisigExpire(ctx *gin.Context) {
+	// pendingId := args[0]
+	resp, err := channelExecute(ctx, "multisigExpire", [][]byte{
+		[]byte(ctx.Param("id")),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 待签转让查询，包括所有表态
+func queryPendingTransfer(ctx *gin.Context) {
+	// pendingId := args[0]
+	resp, err := channelQuery(ctx, "queryPendingTransfer", [][]byte{
+		[]byte(ctx.Param("id")),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PoolBundleRequest struct {
+	OwnerId  string   `form:"ownerid" binding:"required"`
+	PoolId   string   `form:"poolid" binding:"required"`
+	PoolName string   `form:"poolname" binding:"required"`
+	AssetIds []string `form:"assetsid" binding:"required"` // 打包的资产 id，表单中重复传入 assetsid
+}
+
+// 把同一拥有者的多项资产打包成资产池，资产池按普通资产转让
+func poolBundle(ctx *gin.Context) {
+	req := new(PoolBundleRequest)
+	// ownerId := args[0]
+	// poolId := args[1]
+	// poolName := args[2]
+	// assetIds := args[3]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	assetIdsBytes, err := json.Marshal(req.AssetIds)
+	if err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, "poolBundle", [][]byte{
+		[]byte(req.OwnerId),
+		[]byte(req.PoolId),
+		[]byte(req.PoolName),
+		assetIdsBytes,
+	})
+
+	if err != nil {
+		ctx.String(errorStatus(err), err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 拆包资产池，form 表单中的 ownerid 为资产池拥有者
+func poolUnbundle(ctx *gin.Context) {
+	// ownerId := args[0]
+	// poolId := args[1]
+	ownerId := ctx.PostForm("ownerid")
+	if ownerId == "" {
+		ctx.String(http.StatusBadRequest, "ownerid required")
+		return
+	}
+
+	resp, err := channelExecute(ctx, "poolUnbundle", [][]byte{
+		[]byte(ownerId),
+		[]byte(ctx.Param("id")),
+	})
+
+	if err != nil {
+		ctx.String(errorStatus(err), err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 与链码 privatedata.go 中的 transient key 一致
+const assetPrivateTransientKey = "asset_private"
+
+// 跨组织转让时资产的保密部分，form 表单中的 private 为 JSON 对象：资产 id → 保密部分，
+// 如 {"a1":{"asset_id":"a1","debtor_name":"x",...}}，资产池和互换可以包含多项资产
+// 保密部分必须与 GET /asset/:id/private 返回的内容逐字节相同，链码按账本上的哈希核对
+// 没有传入时返回 nil，受让者与出让者在同一组织时不需要传入
+func privateTransient(private string) (map[string][]byte, error) {
+	if private == "" {
+		return nil, nil
+	}
+
+	privs := make(map[string]json.RawMessage)
+	if err := json.Unmarshal([]byte(private), &privs); err != nil {
+		return nil, fmt.Errorf("invalid private: %s", err)
+	}
+	transient := make(map[string][]byte, len(privs))
+	for assetId, priv := range privs {
+		transient[assetPrivateTransientKey+"_"+assetId] = priv
+	}
+
+	return transient, nil
+}
+
+// 资产保密部分查询，只有拥有者所在组织的节点上有数据
+// 当前只向 peer0.org1 发送请求，Org2 拥有的资产需要把 org 和目标节点换成 Org2 的
+func queryAssetPrivate(ctx *gin.Context) {
+	// assetId := args[0]
+	assetId := ctx.Param("id")
+
+	resp, err := channelQuery(ctx, "queryAssetPrivate", [][]byte{
+		[]byte(assetId),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
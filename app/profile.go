@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserProfileRequest struct {
+	Type       string `form:"type"`         // 可选，individual / enterprise
+	KycStatus  string `form:"kyc_status"`   // 可选，pending / verified / suspended，需要开户员身份，监管方只能暂停和恢复
+	KycDocHash string `form:"kyc_doc_hash"` // 可选，链下 KYC 材料的 SHA-256，十六进制
+	Note       string `form:"note"`         // 可选，备注，写入资料变更记录
+}
+
+// 修改用户资料和 KYC 状态，参数为空表示不修改
+func updateUserProfile(ctx *gin.Context) {
+	req := new(UserProfileRequest)
+	// userId := args[0]
+	// userType := args[1]
+	// kycStatus := args[2]
+	// kycDocHash := args[3]
+	// note := args[4]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, "updateUserProfile", [][]byte{
+		[]byte(ctx.Param("id")),
+		[]byte(req.Type),
+		[]byte(req.KycStatus),
+		[]byte(req.KycDocHash),
+		[]byte(req.Note),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 用户资料变更记录查询
+func queryUserProfileHistory(ctx *gin.Context) {
+	// userId := args[0]
+	// pageSize := args[1]
+	// bookmark := args[2]
+	resp, err := channelQuery(ctx, "queryUserProfileHistory", [][]byte{
+		[]byte(ctx.Param("id")),
+		[]byte(ctx.Query("page_size")), // 可为空
+		[]byte(ctx.Query("bookmark")),  // 可为空，上一页返回的 bookmark
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
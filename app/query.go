@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AssetsQueryRequest struct {
+	Owner      string `form:"owner"`
+	Name       string `form:"name"`
+	ClassId    string `form:"class"`
+	Attributes string `form:"attributes"` // JSON 对象，如 {"principal":{"gte":1000,"lte":5000}}
+	PageSize   string `form:"page_size"`
+	PageToken  string `form:"page_token"` // 上一页返回的 bookmark
+}
+
+// 资产富查询，需要 CouchDB 作为状态数据库
+func queryAssets(ctx *gin.Context) {
+	req := new(AssetsQueryRequest)
+	// query := args[0]
+	// pageSize := args[1]
+	// bookmark := args[2]
+	if err := ctx.ShouldBindQuery(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	query := map[string]interface{}{
+		"owner":    req.Owner,
+		"name":     req.Name,
+		"class_id": req.ClassId,
+	}
+	if req.Attributes != "" {
+		query["attributes"] = json.RawMessage(req.Attributes)
+	}
+	queryBytes, err := json.Marshal(query)
+	if err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelQuery(ctx, "queryAssets", [][]byte{
+		queryBytes,
+		[]byte(req.PageSize),
+		[]byte(req.PageToken),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	// 返回的 bookmark 作为下一页的 page_token
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
+
+// 用户列表
+func listUsers(ctx *gin.Context) {
+	listAction(ctx, "listUsers")
+}
+
+// 资产列表
+func listAssets(ctx *gin.Context) {
+	listAction(ctx, "listAssets")
+}
+
+// 列表查询的参数都只有分页大小和 bookmark，返回的 JSON 中带有下一页的 bookmark
+func listAction(ctx *gin.Context, fcn string) {
+	// pageSize := args[0]
+	// bookmark := args[1]
+	pageSize := ctx.Query("page_size") // 可为空
+	bookmark := ctx.Query("bookmark")  // 可为空
+
+	resp, err := channelQuery(ctx, fcn, [][]byte{
+		[]byte(pageSize),
+		[]byte(bookmark),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
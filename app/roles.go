@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleRequest struct {
+	MspId   string `form:"mspid" binding:"required"`
+	Subject string `form:"subject" binding:"required"` // 证书的 Subject，如 CN=user1,OU=client,O=Hyperledger,ST=North Carolina,C=US
+	Role    string `form:"role" binding:"required"`    // admin registrar regulator auditor trader
+}
+
+// 授予角色，需要管理员身份
+func grantRole(ctx *gin.Context) {
+	roleAction(ctx, "grantRole")
+}
+
+// 撤销角色，需要管理员身份
+func revokeRole(ctx *gin.Context) {
+	roleAction(ctx, "revokeRole")
+}
+
+func roleAction(ctx *gin.Context, fcn string) {
+	req := new(RoleRequest)
+	// mspId := args[0]
+	// subject := args[1]
+	// role := args[2]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, fcn, [][]byte{
+		[]byte(req.MspId),
+		[]byte(req.Subject),
+		[]byte(req.Role),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 角色查询，query 中不带 mspid 和 subject 时查询调用者自己的身份（MSP ID、证书 Subject）和角色
+func queryRoles(ctx *gin.Context) {
+	// mspId := args[0]
+	// subject := args[1]
+	args := [][]byte{}
+	if mspId, subject := ctx.Query("mspid"), ctx.Query("subject"); mspId != "" || subject != "" {
+		args = append(args, []byte(mspId), []byte(subject))
+	}
+
+	resp, err := channelQuery(ctx, "queryRoles", args)
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
+
+type AclRequest struct {
+	Roles string `form:"roles" binding:"required"` // JSON 数组，如 ["admin","auditor"]，[] 表示不限制角色
+}
+
+// 设置方法的 ACL，需要管理员身份
+func setAcl(ctx *gin.Context) {
+	req := new(AclRequest)
+	// fcn := args[0]
+	// roles := args[1]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, "setAcl", [][]byte{
+		[]byte(ctx.Param("fcn")),
+		[]byte(req.Roles),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 方法 ACL 查询
+func queryAcl(ctx *gin.Context) {
+	// fcn := args[0]
+	resp, err := channelQuery(ctx, "queryAcl", [][]byte{
+		[]byte(ctx.Param("fcn")),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UnitsTransferRequest struct {
+	FromId string `form:"fromid" binding:"required"`
+	ToId   string `form:"toid" binding:"required"`
+	Units  string `form:"units" binding:"required"`
+}
+
+// 份额化资产的份额转让
+func unitsTransfer(ctx *gin.Context) {
+	req := new(UnitsTransferRequest)
+	// fromId := args[0]
+	// assetId := args[1]
+	// toId := args[2]
+	// units := args[3]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, "unitsTransfer", [][]byte{
+		[]byte(req.FromId),
+		[]byte(ctx.Param("id")),
+		[]byte(req.ToId),
+		[]byte(req.Units),
+	})
+
+	if err != nil {
+		ctx.String(errorStatus(err), err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AssetSwapRequest struct {
+	ProposerId          string `form:"proposerid" binding:"required"`
+	ProposerAssetId     string `form:"proposerassetid" binding:"required"`
+	CounterpartyId      string `form:"counterpartyid" binding:"required"`
+	CounterpartyAssetId string `form:"counterpartyassetid" binding:"required"`
+	Expiry              string `form:"expiry" binding:"required"` // RFC3339 格式，如 2020-05-01T00:00:00+08:00
+}
+
+// 发起资产互换
+func swapPropose(ctx *gin.Context) {
+	req := new(AssetSwapRequest)
+	// proposerId := args[0]
+	// proposerAssetId := args[1]
+	// counterpartyId := args[2]
+	// counterpartyAssetId := args[3]
+	// expiry := args[4]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, "swapPropose", [][]byte{
+		[]byte(req.ProposerId),
+		[]byte(req.ProposerAssetId),
+		[]byte(req.CounterpartyId),
+		[]byte(req.CounterpartyAssetId),
+		[]byte(req.Expiry),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	// Payload 为互换 id，对手方凭此接受
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
+
+// 查询资产互换
+func querySwap(ctx *gin.Context) {
+	// swapId := args[0]
+	swapId := ctx.Param("id")
+
+	resp, err := channelQuery(ctx, "querySwap", [][]byte{
+		[]byte(swapId),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
+
+// 接受资产互换，双方资产在同一交易中交换
+func swapAccept(ctx *gin.Context) {
+	swapAction(ctx, "swapAccept")
+}
+
+// 取消资产互换，发起方撤回或对手方拒绝
+func swapCancel(ctx *gin.Context) {
+	swapAction(ctx, "swapCancel")
+}
+
+// 接受、取消的参数都只有 path 中的互换 id，跨组织互换时 form 表单中传入 private，见 privateTransient
+func swapAction(ctx *gin.Context, fcn string) {
+	// swapId := args[0]
+	swapId := ctx.Param("id")
+	transient, err := privateTransient(ctx.PostForm("private"))
+	if err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecuteTransient(ctx, fcn, [][]byte{
+		[]byte(swapId),
+	}, transient)
+
+	if err != nil {
+		ctx.String(errorStatus(err), err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TokenMintRequest struct {
+	UserId string `form:"userid" binding:"required"`
+	Amount string `form:"amount" binding:"required"` // 正整数，代币的最小单位
+}
+
+// 铸造代币，需要发行方身份（证书属性 issuer=true）
+func tokenMint(ctx *gin.Context) {
+	req := new(TokenMintRequest)
+	// userId := args[0]
+	// amount := args[1]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, "tokenMint", [][]byte{
+		[]byte(req.UserId),
+		[]byte(req.Amount),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+type TokenTransferRequest struct {
+	FromId string `form:"fromid" binding:"required"`
+	ToId   string `form:"toid" binding:"required"`
+	Amount string `form:"amount" binding:"required"`
+}
+
+// 代币转账
+func tokenTransfer(ctx *gin.Context) {
+	req := new(TokenTransferRequest)
+	// fromId := args[0]
+	// toId := args[1]
+	// amount := args[2]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, "tokenTransfer", [][]byte{
+		[]byte(req.FromId),
+		[]byte(req.ToId),
+		[]byte(req.Amount),
+	})
+
+	if err != nil {
+		ctx.String(errorStatus(err), err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 代币余额查询
+func balanceOf(ctx *gin.Context) {
+	// userId := args[0]
+	userId := ctx.Param("id")
+
+	resp, err := channelQuery(ctx, "balanceOf", [][]byte{
+		[]byte(userId),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
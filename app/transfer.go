@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TransferOfferRequest struct {
+	OriginOwnerId  string `form:"originownerid" binding:"required"`
+	AssetId        string `form:"assetsid" binding:"required"`
+	CurrentOwnerId string `form:"currentownerid" binding:"required"`
+	Expiry         string `form:"expiry" binding:"required"` // RFC3339 格式，如 2020-05-01T00:00:00+08:00
+	Price          string `form:"price"`                     // 可选，转让价格（代币最小单位），设置后受让者通过 buy 付款接受
+}
+
+// 发起转让要约
+func transferOffer(ctx *gin.Context) {
+	req := new(TransferOfferRequest)
+	// ownerId := args[0]
+	// assetId := args[1]
+	// recipientId := args[2]
+	// expiry := args[3]
+	// price := args[4]
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecute(ctx, "transferOffer", [][]byte{
+		[]byte(req.OriginOwnerId),
+		[]byte(req.AssetId),
+		[]byte(req.CurrentOwnerId),
+		[]byte(req.Expiry),
+		[]byte(req.Price),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	// Payload 为要约 id，受让者凭此接受或拒绝
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
+
+// 查询转让要约
+func queryTransferOffer(ctx *gin.Context) {
+	// offerId := args[0]
+	offerId := ctx.Param("id")
+
+	resp, err := channelQuery(ctx, "queryTransferOffer", [][]byte{
+		[]byte(offerId),
+	})
+
+	if err != nil {
+		ctx.String(http.StatusOK, err.Error())
+		return
+	}
+
+	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
+}
+
+// 接受转让要约
+func transferAccept(ctx *gin.Context) {
+	transferOfferAction(ctx, "transferAccept")
+}
+
+// 按要约价格购买资产，代币付款和资产交割在同一交易中完成
+func buyAsset(ctx *gin.Context) {
+	transferOfferAction(ctx, "buyAsset")
+}
+
+// 拒绝转让要约
+func transferReject(ctx *gin.Context) {
+	transferOfferAction(ctx, "transferReject")
+}
+
+// 撤回转让要约
+func transferCancel(ctx *gin.Context) {
+	transferOfferAction(ctx, "transferCancel")
+}
+
+// 接受、购买、拒绝、撤回的参数都只有 path 中的要约 id，跨组织转让时 form 表单中传入 private，见 privateTransient
+func transferOfferAction(ctx *gin.Context, fcn string) {
+	// offerId := args[0]
+	offerId := ctx.Param("id")
+	transient, err := privateTransient(ctx.PostForm("private"))
+	if err != nil {
+		ctx.AbortWithError(400, err)
+		return
+	}
+
+	resp, err := channelExecuteTransient(ctx, fcn, [][]byte{
+		[]byte(offerId),
+	}, transient)
+
+	if err != nil {
+		ctx.String(errorStatus(err), err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,238 @@
+package main
+
+// 资产类别：管理员定义类别及其带类型的属性，资产登记时按类别校验属性
+// 如 车辆：排量(number)、品牌(string)、座位数(number)；贷款：本金(number)、到期日(date)、五级分类(enum)
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// 属性类型
+const (
+	attrString = "string"
+	attrNumber = "number"
+	attrDate   = "date" // 2006-01-02 或 RFC3339 格式
+	attrEnum   = "enum"
+
+	dateLayout = "2006-01-02"
+)
+
+// AssetClass 资产类别
+type AssetClass struct {
+	Id         string          `json:"id"`
+	Name       string          `json:"name"`
+	Attributes []*AttributeDef `json:"attributes"`
+}
+
+// AttributeDef 资产类别中的属性定义
+type AttributeDef struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`             // string / number / date / enum
+	Required bool     `json:"required"`         // 登记资产时是否必填
+	Values   []string `json:"values,omitempty"` // enum 类型的可选值
+}
+
+// 以 class_ 开头的，认为是资产类别
+func constructClassKey(classId string) string {
+	return fmt.Sprintf("class_%s", classId)
+}
+
+// 读取资产类别
+func getAssetClass(stub shim.ChaincodeStubInterface, classId string) (*AssetClass, error) {
+	classBytes, err := stub.GetState(constructClassKey(classId))
+	if err != nil || len(classBytes) == 0 {
+		return nil, fmt.Errorf("asset class not found")
+	}
+
+	class := new(AssetClass)
+	if err := json.Unmarshal(classBytes, class); err != nil {
+		return nil, fmt.Errorf("unmarshal asset class error: %s", err)
+	}
+
+	return class, nil
+}
+
+// 校验属性定义本身是否合法
+func (c *AssetClass) validate() error {
+	names := make(map[string]bool)
+	for _, def := range c.Attributes {
+		if def == nil || def.Name == "" {
+			return fmt.Errorf("attribute name required")
+		}
+		if names[def.Name] {
+			return fmt.Errorf("duplicate attribute %s", def.Name)
+		}
+		names[def.Name] = true
+
+		switch def.Type {
+		case attrString, attrNumber, attrDate:
+			if len(def.Values) != 0 {
+				return fmt.Errorf("attribute %s: values only allowed for enum", def.Name)
+			}
+		case attrEnum:
+			if len(def.Values) == 0 {
+				return fmt.Errorf("attribute %s: enum requires values", def.Name)
+			}
+		default:
+			return fmt.Errorf("attribute %s: unknown type %s", def.Name, def.Type)
+		}
+	}
+
+	return nil
+}
+
+// 按类别解析并校验资产属性，返回的属性值中 number 为 json.Number，其余为 string
+func (c *AssetClass) parseAttributes(attrsJson string) (map[string]interface{}, error) {
+	raw := make(map[string]interface{})
+	if attrsJson != "" {
+		// UseNumber 保留数字的原始精度，避免金额被转成 float64
+		decoder := json.NewDecoder(bytes.NewBufferString(attrsJson))
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("invalid attributes: %s", err)
+		}
+	}
+
+	defs := make(map[string]*AttributeDef)
+	for _, def := range c.Attributes {
+		defs[def.Name] = def
+	}
+	for name := range raw {
+		if _, ok := defs[name]; !ok {
+			return nil, fmt.Errorf("attribute %s not defined in class %s", name, c.Id)
+		}
+	}
+
+	attrs := make(map[string]interface{})
+	for _, def := range c.Attributes {
+		val, ok := raw[def.Name]
+		if !ok || val == nil {
+			if def.Required {
+				return nil, fmt.Errorf("attribute %s required", def.Name)
+			}
+			continue
+		}
+
+		switch def.Type {
+		case attrNumber:
+			num, ok := val.(json.Number)
+			if !ok {
+				return nil, fmt.Errorf("attribute %s must be a number", def.Name)
+			}
+			attrs[def.Name] = num
+		case attrString:
+			str, ok := val.(string)
+			if !ok {
+				return nil, fmt.Errorf("attribute %s must be a string", def.Name)
+			}
+			attrs[def.Name] = str
+		case attrDate:
+			str, ok := val.(string)
+			if !ok {
+				return nil, fmt.Errorf("attribute %s must be a date string", def.Name)
+			}
+			if _, err := time.Parse(dateLayout, str); err != nil {
+				if _, err := time.Parse(time.RFC3339, str); err != nil {
+					return nil, fmt.Errorf("attribute %s: invalid date %s", def.Name, str)
+				}
+			}
+			attrs[def.Name] = str
+		case attrEnum:
+			str, ok := val.(string)
+			if !ok {
+				return nil, fmt.Errorf("attribute %s must be a string", def.Name)
+			}
+			valid := false
+			for _, v := range def.Values {
+				if v == str {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return nil, fmt.Errorf("attribute %s: %s not in %v", def.Name, str, def.Values)
+			}
+			attrs[def.Name] = str
+		}
+	}
+
+	return attrs, nil
+}
+
+// 定义资产类别，只有管理员可以定义；已存在的类别不能修改，避免已登记资产的属性失效
+func assetClassDefine(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 3 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	classId := args[0]
+	name := args[1]
+	attrsJson := args[2] // 属性定义列表，如 [{"name":"principal","type":"number","required":true}]
+	if classId == "" || name == "" || attrsJson == "" {
+		return shim.Error("invalid args")
+	}
+
+	if !isAdmin(stub) {
+		return shim.Error("permission denied: admin only")
+	}
+	if classId == poolClassId {
+		return shim.Error(fmt.Sprintf("asset class %s is reserved", poolClassId))
+	}
+
+	class := &AssetClass{
+		Id:   classId,
+		Name: name,
+	}
+	if err := json.Unmarshal([]byte(attrsJson), &class.Attributes); err != nil {
+		return shim.Error(fmt.Sprintf("invalid attributes: %s", err))
+	}
+	if err := class.validate(); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 3：验证数据是否存在
+	if classBytes, err := stub.GetState(constructClassKey(classId)); err == nil && len(classBytes) != 0 {
+		return shim.Error("asset class already exist")
+	}
+
+	// 4： 状态写入
+	classBytes, err := json.Marshal(class)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal asset class error: %s", err))
+	}
+	if err := stub.PutState(constructClassKey(classId), classBytes); err != nil {
+		return shim.Error(fmt.Sprintf("save asset class error: %s", err))
+	}
+
+	return shim.Success(nil)
+}
+
+// 资产类别查询
+func queryAssetClass(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	classId := args[0]
+	if classId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	classBytes, err := stub.GetState(constructClassKey(classId))
+	if err != nil || len(classBytes) == 0 {
+		return shim.Error("asset class not found")
+	}
+
+	return shim.Success(classBytes)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAssetClassValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		attrs   []*AttributeDef
+		wantErr string
+	}{
+		{"empty", nil, ""},
+		{"all types", []*AttributeDef{
+			{Name: "principal", Type: attrNumber, Required: true},
+			{Name: "borrower", Type: attrString},
+			{Name: "due", Type: attrDate},
+			{Name: "grade", Type: attrEnum, Values: []string{"normal", "loss"}},
+		}, ""},
+		{"nil attribute", []*AttributeDef{nil}, "attribute name required"},
+		{"no name", []*AttributeDef{{Type: attrString}}, "attribute name required"},
+		{"duplicate", []*AttributeDef{{Name: "a", Type: attrString}, {Name: "a", Type: attrNumber}}, "duplicate attribute a"},
+		{"unknown type", []*AttributeDef{{Name: "a", Type: "bool"}}, "unknown type bool"},
+		{"enum without values", []*AttributeDef{{Name: "a", Type: attrEnum}}, "enum requires values"},
+		{"values on string", []*AttributeDef{{Name: "a", Type: attrString, Values: []string{"x"}}}, "values only allowed for enum"},
+	}
+	for _, tt := range tests {
+		err := (&AssetClass{Id: "c", Attributes: tt.attrs}).validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	class := &AssetClass{
+		Id: "loan",
+		Attributes: []*AttributeDef{
+			{Name: "principal", Type: attrNumber, Required: true},
+			{Name: "borrower", Type: attrString},
+			{Name: "due", Type: attrDate},
+			{Name: "grade", Type: attrEnum, Values: []string{"normal", "loss"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		json    string
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "required only",
+			json: `{"principal":100}`,
+			want: map[string]interface{}{"principal": json.Number("100")},
+		},
+		{
+			// 数字保留原始精度，不转换为 float64
+			name: "all attributes",
+			json: `{"principal":12345678901234567890.01,"borrower":"acme","due":"2021-06-30","grade":"loss"}`,
+			want: map[string]interface{}{
+				"principal": json.Number("12345678901234567890.01"),
+				"borrower":  "acme",
+				"due":       "2021-06-30",
+				"grade":     "loss",
+			},
+		},
+		{
+			name: "rfc3339 date",
+			json: `{"principal":1,"due":"2021-06-30T08:00:00+08:00"}`,
+			want: map[string]interface{}{"principal": json.Number("1"), "due": "2021-06-30T08:00:00+08:00"},
+		},
+		{
+			// null 视为没有填写
+			name: "null optional",
+			json: `{"principal":1,"borrower":null}`,
+			want: map[string]interface{}{"principal": json.Number("1")},
+		},
+		{name: "empty", json: "", wantErr: "attribute principal required"},
+		{name: "null required", json: `{"principal":null}`, wantErr: "attribute principal required"},
+		{name: "invalid json", json: `{"principal":`, wantErr: "invalid attributes"},
+		{name: "not an object", json: `[1]`, wantErr: "invalid attributes"},
+		{name: "undefined", json: `{"principal":1,"color":"red"}`, wantErr: "attribute color not defined in class loan"},
+		{name: "string as number", json: `{"principal":"100"}`, wantErr: "attribute principal must be a number"},
+		{name: "number as string", json: `{"principal":1,"borrower":1}`, wantErr: "attribute borrower must be a string"},
+		{name: "invalid date", json: `{"principal":1,"due":"2021-02-30"}`, wantErr: "invalid date 2021-02-30"},
+		{name: "number as date", json: `{"principal":1,"due":20210630}`, wantErr: "must be a date string"},
+		{name: "enum value", json: `{"principal":1,"grade":"doubtful"}`, wantErr: "doubtful not in [normal loss]"},
+	}
+	for _, tt := range tests {
+		got, err := class.parseAttributes(tt.json)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAssetEnrollWithClass(t *testing.T) {
+	s := newTestStub(t)
+	s.registerUser(testMspId, "alice")
+
+	// 只有管理员可以定义类别，类别不能重复定义
+	s.asUser(testMspId, "alice").mustFail("assetClassDefine", testClass, "Loan", `[{"name":"principal","type":"number"}]`)
+	s.defineClass()
+	s.asAdmin().mustFail("assetClassDefine", testClass, "Loan", `[{"name":"principal","type":"number"}]`)
+	s.asAdmin().mustFail("assetClassDefine", poolClassId, "Pool", `[]`)
+
+	s.asUser(testMspId, "alice").mustFail("assetEnroll", "a1", "a1", "unknown", `{"principal":1}`, "alice")
+	s.asUser(testMspId, "alice").mustFail("assetEnroll", "a1", "a1", testClass, `{"principal":"1"}`, "alice")
+	s.asUser(testMspId, "alice").mustInvoke("assetEnroll", "a1", "a1", testClass, `{"principal":1.50}`, "alice")
+
+	asset := s.getAsset("a1")
+	if asset.ClassId != testClass || asset.DocType != assetDocType || asset.Metadata != "" {
+		t.Fatalf("unexpected asset %+v", asset)
+	}
+	if principal, ok := asset.Attributes["principal"].(float64); !ok || principal != 1.5 {
+		t.Fatalf("unexpected attributes %v", asset.Attributes)
+	}
+}
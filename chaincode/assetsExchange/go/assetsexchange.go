@@ -7,6 +7,8 @@ package main
 
 import (
 	"fmt"
+	"time"
+	"strconv"
 	"encoding/json"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	pb "github.com/hyperledger/fabric/protos/peer"
@@ -17,6 +19,27 @@ type AssertsManageCC struct{}
 
 const (
 	originOwner = "originOwnerPlaceholder"
+
+	// 用户状态
+	userActive = "active"
+	userClosed = "closed"
+
+	// 资产文档的类型标记，CouchDB 富查询据此区分资产和其它数据
+	assetDocType = "asset"
+
+	// 资产变更记录的组合键：assetHistory~资产id~序号，序号补齐位数保证按字典序即时间顺序排列
+	historyObjectType = "assetHistory"
+	historySeqFormat  = "%020d"
+
+	// 资产变更记录的类型，旧版本的记录没有类型，按 OriginOwnerId 区分登记和转让
+	historyEnroll   = "enroll"
+	historyExchange = "exchange"
+	historyFreeze   = "freeze"
+	historyUnfreeze = "unfreeze"
+	historyBundle   = "bundle"
+	historyUnbundle = "unbundle"
+	historyUpdate   = "update"
+	historyMultisig = "multisig"
 )
 
 // User 用户
@@ -24,22 +47,89 @@ type User struct {
 	Name string `json:"name"` // messagepack || protobuf 格式也可
 	Id   string `json:"id"`
 	//Assets map[string]string `json:"assets"` // key:资产id, value:资产Name,但是map是无序的，换用切片
-	Assets []string `json:"assets"` // 存储资产 id
+	// 资产 id 列表，由 owner~assetId 索引维护，只在查询时填充，不写入账本
+	// 旧版本的账本中存有该字段，需要执行一次 migrateOwnerIndex 迁移到索引
+	Assets  []string `json:"assets"`
+	MspId   string   `json:"msp_id"`  // 开户者所属组织的 MSP ID
+	Subject string   `json:"subject"` // 开户者证书的 Subject，与 MspId 一起确定用户身份
+	Status  string   `json:"status"`  // active / closed，旧版本的用户为空，视为 active
+	// 销户信息，销户后用户对象作为墓碑保留
+	Closure *UserClosure `json:"closure,omitempty"`
+	// 监管冻结信息，见 freeze.go
+	Freeze *Freeze `json:"freeze,omitempty"`
+	// 多签：授权签署人和门限，门限大于 0 时资产转让需要签署人同意，见 multisig.go
+	Signers   []*Signer `json:"signers,omitempty"`
+	Threshold int       `json:"threshold,omitempty"`
+	// 用户类型和 KYC 状态，旧版本的用户为空，见 profile.go
+	Profile *UserProfile `json:"profile,omitempty"`
+}
+
+// UserClosure 销户记录
+type UserClosure struct {
+	ClosedAt           time.Time `json:"closed_at"`
+	TxId               string    `json:"tx_id"`
+	ClosedByMspId      string    `json:"closed_by_msp_id"` // 执行销户的身份，本人或管理员
+	ClosedBy           string    `json:"closed_by"`
+	SuccessorId        string    `json:"successor_id,omitempty"`        // 承接资产的用户
+	TransferredIds     []string  `json:"transferred_ids,omitempty"`     // 转给承接人的资产 id
+	TransferredBalance int64     `json:"transferred_balance,omitempty"` // 转给承接人的代币余额
 }
 
 // Asset 资产
 type Asset struct {
-	Name string `json:"name"`
-	Id   string `json:"id"`
+	DocType string `json:"doc_type"`
+	Name    string `json:"name"`
+	Id      string `json:"id"`
 	//Metadata map[string]string `json:"metadata"` // 特殊属性，map无序，数据结构不合适，换为切片
-	Metadata string `json:"metadata"` // 特殊属性
+	Metadata string `json:"metadata,omitempty"` // 特殊属性，旧版本登记的资产使用，新登记的资产使用 ClassId + Attributes
+	ClassId  string `json:"class_id"`           // 资产类别
+	Owner    string `json:"owner"`              // 拥有者 id，同时维护 owner~assetId 索引；份额化资产为登记者
+	Units    int64  `json:"units,omitempty"`    // 份额总数，为 0 表示整体持有，见 shares.go
+	// 保密部分所在的私有数据集合及其 SHA-256，没有保密部分时为空，见 privatedata.go
+	PrivateCollection string `json:"private_collection,omitempty"`
+	PrivateHash       string `json:"private_hash,omitempty"`
+	// 监管冻结信息，见 freeze.go
+	Freeze *Freeze `json:"freeze,omitempty"`
+	// 未解除的质权（含待质权人确认的），由 lien~资产id~质权id 维护，只在查询时填充，不写入账本，见 lien.go
+	Liens []*Lien `json:"liens,omitempty"`
+	// 资产池中的资产 id，只有资产池有；池中的资产以 PoolId 指向所在的资产池，见 pool.go
+	Members []string `json:"members,omitempty"`
+	PoolId  string   `json:"pool_id,omitempty"`
+	// 份额化资产的股权结构表，由 share~资产id~持有人id 维护，只在查询时填充，不写入账本
+	Shares []*AssetShare `json:"shares,omitempty"`
+	// 按资产类别校验过的属性，number 类型为 JSON 数字，其余为字符串
+	// json 序列化 map 时按 key 排序，各背书节点写入的值一致
+	Attributes map[string]interface{} `json:"attributes"`
 }
 
 // AssetHistory 资产变更历史
 type AssetHistory struct {
-	AssetId        string `json:"asset_id"`
-	OriginOwnerId  string `json:"origin_owner_id"`  // 资产的原始拥有者
-	CurrentOwnerId string `json:"current_owner_id"` // 变更后当前的拥有者
+	AssetId        string       `json:"asset_id"`
+	OriginOwnerId  string       `json:"origin_owner_id"`      // 资产的原始拥有者
+	CurrentOwnerId string       `json:"current_owner_id"`     // 变更后当前的拥有者
+	Seq            uint64       `json:"seq"`                  // 该资产的第几条变更记录，从 1 开始单调递增
+	TxId           string       `json:"tx_id"`                // 产生该记录的交易 id
+	Timestamp      time.Time    `json:"timestamp"`            // 交易提案中的时间戳
+	Action         string       `json:"action,omitempty"`     // 记录类型：enroll / exchange / freeze / unfreeze / bundle / unbundle / update / multisig
+	Freeze         *Freeze      `json:"freeze,omitempty"`     // 冻结和解冻记录的冻结信息
+	Price          int64        `json:"price,omitempty"`      // 有对价转让的成交价格，单位为代币的最小单位
+	Units          int64        `json:"units,omitempty"`      // 份额化资产登记时为份额总数，转让时为转让的份额数
+	PoolId         string       `json:"pool_id,omitempty"`    // 池中的资产随资产池转让、打包和拆包时为资产池 id
+	AuctionId      string       `json:"auction_id,omitempty"` // 拍卖成交的转让记录为拍卖 id
+	Update         *AssetUpdate `json:"update,omitempty"`     // 资产信息修改记录的修改原因和修改前后的值，见 update.go
+	// 多签用户的资产：发起、同意、拒绝、过期写入 multisig 记录，达到门限时的转让记录带最后一次同意，见 multisig.go
+	Multisig *MultisigRecord `json:"multisig,omitempty"`
+}
+
+// 变更记录的类型，兼容没有 Action 的旧记录
+func (h *AssetHistory) action() string {
+	if h.Action != "" {
+		return h.Action
+	}
+	if h.OriginOwnerId == originOwner {
+		return historyEnroll
+	}
+	return historyExchange
 }
 
 // 以 user_ 开头的，认为是用户
@@ -47,20 +137,116 @@ func constructUserKey(userId string) string {
 	return fmt.Sprintf("user_%s", userId)
 }
 
+// user_ 范围扫描的结束键，'`' 是 '_' 的下一个字符
+const userKeyRangeEnd = "user`"
+
 // 以 asset_ 开头的，认为是资产
 func constructAssetKey(assetId string) string {
 	return fmt.Sprintf("asset_%s", assetId)
 }
 
+// asset_ 范围扫描的结束键
+const assetKeyRangeEnd = "asset`"
+
+// 以 historyseq_ 开头的，记录资产最新的变更记录序号
+func constructHistorySeqKey(assetId string) string {
+	return fmt.Sprintf("historyseq_%s", assetId)
+}
+
+// 校验资产 id 可以用于登记或打包：资产不存在，也没有用过
+// 拆包后资产池被删除，但变更记录和序号保留，同一 id 不能再使用，否则会接续已拆包资产池的记录
+func checkAssetIdUnused(stub shim.ChaincodeStubInterface, assetId string) error {
+	assetBytes, err := stub.GetState(constructAssetKey(assetId))
+	if err != nil {
+		return fmt.Errorf("get asset error: %s", err)
+	}
+	if len(assetBytes) != 0 {
+		return fmt.Errorf("asset already exist")
+	}
+	seqBytes, err := stub.GetState(constructHistorySeqKey(assetId))
+	if err != nil {
+		return fmt.Errorf("get history seq error: %s", err)
+	}
+	if len(seqBytes) != 0 {
+		return fmt.Errorf("asset id %s already used", assetId)
+	}
+
+	return nil
+}
+
 // 换用 shim 自带的 创造组合键方法 
 // func constructAssetHistoryKey(OriginOwnerId, AssetId, CurrentOwnerId string) string {
 // 	return fmt.Sprintf("history_%s_%s_%s",OriginOwnerId, AssetId, CurrentOwnerId)
 // }
 
+// 交易提案中的时间戳，所有背书节点得到的值一致，链码中不能使用 time.Now()
+func getTxTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get tx timestamp error: %s", err)
+	}
+
+	return time.Unix(ts.GetSeconds(), int64(ts.GetNanos())).UTC(), nil
+}
+
+// 写入一条资产变更记录，记录只追加不覆盖
+// 序号、交易 id、时间戳在这里填充。同一交易内对同一资产只能写入一条记录，
+// 因为交易内 GetState 读不到本交易 PutState 的结果，序号会重复
+func putAssetHistory(stub shim.ChaincodeStubInterface, history *AssetHistory) error {
+	seq := uint64(0)
+	seqBytes, err := stub.GetState(constructHistorySeqKey(history.AssetId))
+	if err != nil {
+		return fmt.Errorf("get history seq error: %s", err)
+	}
+	if len(seqBytes) != 0 {
+		if seq, err = strconv.ParseUint(string(seqBytes), 10, 64); err != nil {
+			return fmt.Errorf("parse history seq error: %s", err)
+		}
+	}
+	seq++
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+
+	history.Seq = seq
+	history.TxId = stub.GetTxID()
+	history.Timestamp = txTime
+
+	historyBytes, err := json.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("marshal assert history error: %s", err)
+	}
+
+	// CreateCompositeKey 创建组合键，并验证
+	historyKey, err := stub.CreateCompositeKey(historyObjectType, []string{
+		history.AssetId,
+		fmt.Sprintf(historySeqFormat, seq),
+	})
+	if err != nil {
+		return fmt.Errorf("create key error: %s", err)
+	}
+
+	// 记录已存在说明序号出错，拒绝覆盖
+	if oldBytes, err := stub.GetState(historyKey); err == nil && len(oldBytes) != 0 {
+		return fmt.Errorf("history %d of asset %s already exist", seq, history.AssetId)
+	}
+
+	if err := stub.PutState(historyKey, historyBytes); err != nil {
+		return fmt.Errorf("save assert history error: %s", err)
+	}
+	if err := stub.PutState(constructHistorySeqKey(history.AssetId), []byte(strconv.FormatUint(seq, 10))); err != nil {
+		return fmt.Errorf("save history seq error: %s", err)
+	}
+
+	return nil
+}
+
 // 用户开户
 func userRegister(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// 1：检查参数的个数
-	if len(args) != 2 {
+	// 1：检查参数的个数，开户员为其它身份开户时传入该身份的 MSP ID 和证书 Subject
+	if len(args) != 2 && len(args) != 4 {
 		return shim.Error("not enough args")
 	}
 
@@ -70,6 +256,9 @@ func userRegister(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	if name == "" || id == "" {
 		return shim.Error("invalid args")
 	}
+	if len(args) == 4 && (args[2] == "" || args[3] == "") {
+		return shim.Error("invalid args")
+	}
 
 	// 3：验证数据是否存在 
 	// 验证需要读取 stateDB，需要 shim 包中的 GetState 方法
@@ -78,21 +267,47 @@ func userRegister(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 		return shim.Error("user already exist")
 	}
 
+	// 开户者的身份与用户绑定，之后只有本人或管理员可以操作该用户
+	mspId, subject, err := getCallerIdentity(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	// 开户员或管理员可以为其它身份开户，用户绑定到传入的身份
+	if len(args) == 4 {
+		if !isAdmin(stub) && !callerHasRole(stub, roleRegistrar) {
+			return shim.Error("permission denied: registrar only")
+		}
+		mspId, subject = args[2], args[3]
+	}
+
+	// 新用户的 KYC 状态为 pending，由开户员核验后才能接收资产
+	profile, err := newUserProfile(stub, userTypeIndividual)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	// 4： 状态写入
 	user := &User{
-		Name:   name,
-		Id:     id,
-		Assets: make([]string, 0),
+		Name:    name,
+		Id:      id,
+		MspId:   mspId,
+		Subject: subject,
+		Status:  userActive,
+		Profile: profile,
 	}
 
-	// 序列化对象
-	userBytes, err := json.Marshal(user)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal user error %s", err))
+	if err := putUser(stub, user); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putProfileHistory(stub, user, profileRegister, ""); err != nil {
+		return shim.Error(err.Error())
 	}
 
-	if err := stub.PutState(constructUserKey(id), userBytes); err != nil {
-		return shim.Error(fmt.Sprintf("put user error %s", err))
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:   eventUserRegistered,
+		UserId: id,
+	}); err != nil {
+		return shim.Error(err.Error())
 	}
 
 	// 成功返回
@@ -100,97 +315,213 @@ func userRegister(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 }
 
 // 用户销户
+// 名下仍有资产时拒绝销户，除非指定承接人，由承接人接收全部资产（每项资产写入变更记录）
+// 销户后用户对象保留为墓碑，记录销户信息，不再删除
 func userDestroy(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// 1：检查参数的个数
-	if len(args) != 1 {
+	// 1：检查参数的个数，承接人 id 可以不传
+	if len(args) != 1 && len(args) != 2 {
 		return shim.Error("not enough args")
 	}
 
 	// 2：验证参数的正确性
 	id := args[0]
-	if id == "" {
+	successorId := ""
+	if len(args) == 2 {
+		successorId = args[1]
+	}
+	if id == "" || id == successorId {
 		return shim.Error("invalid args")
 	}
 
 	// 3：验证数据是否存在 
-	userBytes, err := stub.GetState(constructUserKey(id))
-	if err != nil || len(userBytes) == 0 {
-		return shim.Error("user not found")
+	// 只有用户本人或管理员可以销户
+	user, err := getUserWithAccess(stub, id)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	// 被冻结的用户不能销户
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkUserNotFrozen(stub, user); err != nil {
+		return errorResponse(err)
 	}
 
-	// 4： 状态写入
-	if err := stub.DelState(constructUserKey(id)); err != nil {
-		return shim.Error(fmt.Sprintf("delete user error: %s", err))
+	assetIds, err := getOwnerAssetIds(stub, id)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	// 多签用户的资产不能随销户整体转出，需要管理员先取消多签
+	if len(assetIds) != 0 {
+		if err := checkNotMultisig(user); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	if len(assetIds) != 0 && successorId == "" {
+		return shim.Error(fmt.Sprintf("user still holds %d assets, transfer them or specify a successor", len(assetIds)))
+	}
+	// 进行中拍卖的出价结束时要退回或付出，等拍卖结算、流拍或取消后才能销户
+	auctionIds, err := getBidderAuctionIds(stub, id)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(auctionIds) != 0 {
+		return shim.Error(fmt.Sprintf("user has open bids in auctions %v, wait until they are settled or cancelled", auctionIds))
+	}
+	balance, err := getInt64State(stub, constructBalanceKey(id))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if balance != 0 && successorId == "" {
+		return shim.Error(fmt.Sprintf("user still holds %d tokens, transfer them or specify a successor", balance))
+	}
+	if successorId != "" {
+		if _, err := getActiveUser(stub, successorId); err != nil {
+			return shim.Error(err.Error())
+		}
 	}
 
-	// 删除用户名下的资产
-	user := new(User)
-	if err := json.Unmarshal(userBytes, user); err != nil {
-		return shim.Error(fmt.Sprintf("unmarshal user error: %s", err))
+	// 4： 状态写入
+	// 名下的资产转给承接人，份额化资产转让持有的全部份额
+	for _, assetid := range assetIds {
+		if err := transferHoldings(stub, id, assetid, successorId); err != nil {
+			return errorResponse(err)
+		}
 	}
-	for _, assetid := range user.Assets {
-		if err := stub.DelState(constructAssetKey(assetid)); err != nil {
-			return shim.Error(fmt.Sprintf("delete asset error: %s", err))
+	// 代币余额转给承接人
+	if balance != 0 {
+		if err := moveTokens(stub, id, successorId, balance); err != nil {
+			return shim.Error(err.Error())
 		}
 	}
 
+	mspId, subject, err := getCallerIdentity(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	user.Status = userClosed
+	user.Closure = &UserClosure{
+		ClosedAt:           now,
+		TxId:               stub.GetTxID(),
+		ClosedByMspId:      mspId,
+		ClosedBy:           subject,
+		SuccessorId:        successorId,
+		TransferredIds:     assetIds,
+		TransferredBalance: balance,
+	}
+	profile := *user.profile()
+	profile.KycStatus = kycClosed
+	profile.UpdatedAt = now
+	user.Profile = &profile
+	if err := putUser(stub, user); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putProfileHistory(stub, user, profileClose, ""); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:     eventUserDestroyed,
+		UserId:   id,
+		To:       successorId,
+		AssetIds: assetIds,
+		Amount:   balance,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
 	return shim.Success(nil)
 }
 
 // 资产登记
 func assetEnroll(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// 1：检查参数的个数
-	if len(args) != 4 {
+	// 1：检查参数的个数，份额总数可以不传
+	if len(args) != 5 && len(args) != 6 {
 		return shim.Error("not enough args")
 	}
 
 	// 2：验证参数的正确性
 	assetName := args[0]
 	assetId := args[1]
-	metadata := args[2]
-	ownerId := args[3]
-	if assetName == "" || assetId == "" || ownerId == "" {
+	classId := args[2]
+	attrsJson := args[3] // 资产属性，JSON 对象，按资产类别校验
+	ownerId := args[4]
+	if assetName == "" || assetId == "" || classId == "" || ownerId == "" {
 		return shim.Error("invalid args")
 	}
+	// 份额总数，大于 0 时按份额持有，登记者持有全部份额
+	units := int64(0)
+	if len(args) == 6 && args[5] != "" {
+		var err error
+		if units, err = strconv.ParseInt(args[5], 10, 64); err != nil || units <= 0 {
+			return shim.Error(fmt.Sprintf("invalid units: %s", args[5]))
+		}
+	}
 
 	// 3：验证数据是否存在 
-	userBytes, err := stub.GetState(constructUserKey(ownerId))
-	if err != nil || len(userBytes) == 0 {
-		return shim.Error("user not found")
+	// 只有资产拥有者本人或管理员可以登记资产
+	user, err := getUserWithAccess(stub, ownerId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	// 只有通过 KYC 核验的用户可以登记资产
+	if err := checkUserVerified(user); err != nil {
+		return shim.Error(err.Error())
 	}
 
-	if assetBytes, err := stub.GetState(constructAssetKey(assetId)); err == nil && len(assetBytes) != 0 {
-		return shim.Error("asset already exist")
+	if err := checkAssetIdUnused(stub, assetId); err != nil {
+		return shim.Error(err.Error())
 	}
 
-	// 4： 状态写入
-	// 1. 写入资产对象 2. 更新用户对象 3. 写入资产变更记录
-	asset := &Asset{
-		Name:     assetName,
-		Id:       assetId,
-		Metadata: metadata,
+	class, err := getAssetClass(stub, classId)
+	if err != nil {
+		return shim.Error(err.Error())
 	}
-	assetBytes, err := json.Marshal(asset)
+	attrs, err := class.parseAttributes(attrsJson)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal asset error: %s", err))
+		return shim.Error(err.Error())
 	}
-	if err := stub.PutState(constructAssetKey(assetId), assetBytes); err != nil {
-		return shim.Error(fmt.Sprintf("save asset error: %s", err))
+	// 保密部分可选，通过 transient map 传入
+	priv, err := getTransientAssetPrivate(stub, assetId)
+	if err != nil {
+		return shim.Error(err.Error())
 	}
 
-	user := new(User)
-	// 反序列化user
-	if err := json.Unmarshal(userBytes, user); err != nil {
-		return shim.Error(fmt.Sprintf("unmarshal user error: %s", err))
+	// 4： 状态写入
+	// 1. 写入资产对象 2. 写入拥有者索引 3. 写入资产变更记录
+	asset := &Asset{
+		DocType:    assetDocType,
+		Name:       assetName,
+		Id:         assetId,
+		ClassId:    classId,
+		Owner:      ownerId,
+		Units:      units,
+		Attributes: attrs,
 	}
-	user.Assets = append(user.Assets, assetId)
-	// 序列化user
-	userBytes, err = json.Marshal(user)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal user error: %s", err))
+	// 保密部分写入拥有者所在组织的集合
+	if priv != nil {
+		if user.MspId == "" {
+			return shim.Error("owner has no bound organization, cannot store asset private data")
+		}
+		if err := putAssetPrivate(stub, asset, user.MspId, priv); err != nil {
+			return shim.Error(err.Error())
+		}
 	}
-	if err := stub.PutState(constructUserKey(user.Id), userBytes); err != nil {
-		return shim.Error(fmt.Sprintf("update user error: %s", err))
+	if err := putAsset(stub, asset); err != nil {
+		return shim.Error(err.Error())
+	}
+	// 资产的修改需要拥有者组织背书
+	if err := setAssetEndorsement(stub, assetId, user.MspId); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putOwnerIndex(stub, user, assetId); err != nil {
+		return shim.Error(err.Error())
+	}
+	if units > 0 {
+		if err := putShareUnits(stub, assetId, user, units); err != nil {
+			return shim.Error(err.Error())
+		}
 	}
 
 	// 资产变更历史
@@ -198,33 +529,30 @@ func assetEnroll(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 		AssetId:        assetId,
 		OriginOwnerId:  originOwner, // 第一次登记的资产持有人标记为 originOwnerPlaceholder
 		CurrentOwnerId: ownerId,
+		Action:         historyEnroll,
+		Units:          units,
 	}
-	historyBytes, err := json.Marshal(history)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal assert history error: %s", err))
+	if err := putAssetHistory(stub, history); err != nil {
+		return shim.Error(err.Error())
 	}
-	 
-	// CreateCompositeKey 创建组合键，并验证
-	historyKey, err := stub.CreateCompositeKey("history", []string{
-		assetId,
-		originOwner,
-		ownerId,
-	})
-	if err != nil {
-		return shim.Error(fmt.Sprintf("create key error: %s", err))
-	}// 验证结束
 
-	if err := stub.PutState(historyKey, historyBytes); err != nil {
-		return shim.Error(fmt.Sprintf("save assert history error: %s", err))
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:    eventAssetEnrolled,
+		AssetId: assetId,
+		To:      ownerId,
+		Amount:  units,
+	}); err != nil {
+		return shim.Error(err.Error())
 	}
 
 	return shim.Success(nil)
 }
 
 // 资产转让
+// 出让者设置了多签时创建待签转让并返回其 id，签署人同意后才转让，过期时间可以不传
 func assetExchange(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	// 1：检查参数的个数
-	if len(args) != 3 {
+	if len(args) != 3 && len(args) != 4 {
 		return shim.Error("not enough args")
 	}
 
@@ -238,100 +566,265 @@ func assetExchange(stub shim.ChaincodeStubInterface, args []string) pb.Response
 
 	// 3：验证数据是否存在 
 
-	// 资产出让者
-	originOwnerBytes, err := stub.GetState(constructUserKey(ownerId))
-	if err != nil || len(originOwnerBytes) == 0 {
-		return shim.Error("user not found")
+	// 资产出让者，只有本人或管理员可以转让资产
+	owner, err := getActiveUser(stub, ownerId)
+	if err != nil {
+		return shim.Error(err.Error())
 	}
-	// 资产接收者
-	currentOwnerBytes, err := stub.GetState(constructUserKey(currentOwnerId))
-	if err != nil || len(currentOwnerBytes) == 0 {
-		return shim.Error("user not found")
+	if owner.Threshold > 0 {
+		expiry := ""
+		if len(args) == 4 {
+			expiry = args[3]
+		}
+		return proposeMultisigTransfer(stub, owner, assetId, currentOwnerId, expiry)
 	}
-	// 被处置的资产
-	assetBytes, err := stub.GetState(constructAssetKey(assetId))
-	if err != nil || len(assetBytes) == 0 {
-		return shim.Error("asset not found")
+	if err := checkUserAccess(stub, owner); err != nil {
+		return shim.Error(err.Error())
 	}
 
-	// 校验原始拥有者确实拥有当前所要变更的资产
-	originOwner := new(User)
-	// 反序列化user
-	if err := json.Unmarshal(originOwnerBytes, originOwner); err != nil {
-		return shim.Error(fmt.Sprintf("unmarshal user error: %s", err))
-	}
-	aidexist := false
-	for _, aid := range originOwner.Assets {
-		if aid == assetId {
-			aidexist = true
-			break
-		}
+	// 4： 状态写入
+	if err := transferAsset(stub, ownerId, assetId, currentOwnerId); err != nil {
+		return errorResponse(err)
 	}
-	if !aidexist {
-		return shim.Error("asset owner not match")
+
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:    eventAssetTransferred,
+		AssetId: assetId,
+		From:    ownerId,
+		To:      currentOwnerId,
+	}); err != nil {
+		return shim.Error(err.Error())
 	}
 
-	// 4： 状态写入
-	// 1. 原始拥有者删除资产id 2. 新拥有者加入资产id 3. 资产变更记录
-	assetIds := make([]string, 0)
-	for _, aid := range originOwner.Assets {
-		// 1
-		if aid == assetId {
-			continue
+	return shim.Success(nil)
+}
+
+// 资产所有权变更，资产转让、转让要约、资产互换等都经由这里完成
+// 校验出让者确实拥有该资产，更新资产的拥有者和 owner~assetId 索引并写入资产变更记录。不校验调用者身份，由调用方负责
+// 不写入用户对象，同一交易内可以对不同资产多次调用
+func transferAsset(stub shim.ChaincodeStubInterface, ownerId, assetId, currentOwnerId string) error {
+	return transferAssetForPrice(stub, ownerId, assetId, currentOwnerId, 0)
+}
+
+// 有对价的资产转让，成交价格记入资产变更记录，价格为 0 表示无对价。代币的支付由调用方完成
+func transferAssetForPrice(stub shim.ChaincodeStubInterface, ownerId, assetId, currentOwnerId string, price int64) error {
+	return transferAssetWithHistory(stub, &AssetHistory{
+		AssetId:        assetId,
+		OriginOwnerId:  ownerId,
+		CurrentOwnerId: currentOwnerId,
+		Action:         historyExchange,
+		Price:          price,
+	})
+}
+
+// 按给定的转让记录完成资产转让，记录中的 Multisig 表示该转让已由签署人同意，只有多签执行时带上
+func transferAssetWithHistory(stub shim.ChaincodeStubInterface, history *AssetHistory) error {
+	asset, owner, currentOwner, err := checkAssetTransfer(stub, history)
+	if err != nil {
+		return err
+	}
+	ownerId := owner.Id
+	assetId := asset.Id
+	currentOwnerId := currentOwner.Id
+
+	// 消耗质权人对受让者的同意
+	if err := consumeLienApprovals(stub, assetId, currentOwnerId); err != nil {
+		return err
+	}
+	// 撤下资产的在售挂牌
+	if err := withdrawAssetListing(stub, assetId); err != nil {
+		return err
+	}
+
+	// 1. 更新资产的拥有者 2. 移动拥有者索引 3. 资产变更记录
+	// 受让者在其它组织时，保密部分移到受让者组织的集合
+	if err := moveAssetPrivate(stub, asset, currentOwner.MspId); err != nil {
+		return err
+	}
+	asset.Owner = currentOwnerId
+	if err := putAsset(stub, asset); err != nil {
+		return err
+	}
+	// 受让者在其它组织时，背书策略改为受让者组织
+	if currentOwner.MspId != owner.MspId {
+		if err := setAssetEndorsement(stub, assetId, currentOwner.MspId); err != nil {
+			return err
+		}
+	}
+	if err := delOwnerIndex(stub, ownerId, assetId); err != nil {
+		return err
+	}
+	if err := putOwnerIndex(stub, currentOwner, assetId); err != nil {
+		return err
+	}
+	// 资产池转让时池中的资产一起转让
+	if len(asset.Members) != 0 {
+		if err := transferPoolMembers(stub, asset, owner, currentOwner); err != nil {
+			return err
 		}
-		assetIds = append(assetIds, aid)
 	}
-	originOwner.Assets = assetIds
-	// 原始拥有者 进行更新
-	originOwnerBytes, err = json.Marshal(originOwner)
+
+	// 插入资产变更记录
+	return putAssetHistory(stub, history)
+}
+
+// 资产接收者的校验，已销户或未通过 KYC 核验的用户不能接收资产
+// 多签转让在发起时同样校验，避免签署人为注定失败的转让表态
+func checkTransferRecipient(stub shim.ChaincodeStubInterface, ownerId, recipientId string) (*User, error) {
+	if ownerId == recipientId {
+		return nil, fmt.Errorf("cannot transfer asset to its owner")
+	}
+	recipient, err := getActiveUser(stub, recipientId)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal user error: %s", err))
+		return nil, err
 	}
-	if err := stub.PutState(constructUserKey(ownerId), originOwnerBytes); err != nil {
-		return shim.Error(fmt.Sprintf("update user error: %s", err))
+	if err := checkUserVerified(recipient); err != nil {
+		return nil, err
 	}
 
-	// 当前拥有者插入资产id 并更新
-	currentOwner := new(User)
-	// 反序列化user
-	if err := json.Unmarshal(currentOwnerBytes, currentOwner); err != nil {
-		return shim.Error(fmt.Sprintf("unmarshal user error: %s", err))
+	return recipient, nil
+}
+
+// 转让前的校验，只读取不写入，返回资产、出让者和受让者
+// 拍卖结算用它挑选能够交割的出价，校验不通过的出价跳过，不会留下部分写入
+func checkAssetTransfer(stub shim.ChaincodeStubInterface, history *AssetHistory) (*Asset, *User, *User, error) {
+	ownerId := history.OriginOwnerId
+	assetId := history.AssetId
+	currentOwnerId := history.CurrentOwnerId
+	currentOwner, err := checkTransferRecipient(stub, ownerId, currentOwnerId)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// 被处置的资产
+	asset, err := getAsset(stub, assetId)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	currentOwner.Assets = append(currentOwner.Assets, assetId)
 
-	currentOwnerBytes, err = json.Marshal(currentOwner)
+	// 校验原始拥有者确实拥有当前所要变更的资产
+	if asset.Owner != ownerId {
+		return nil, nil, nil, fmt.Errorf("asset owner not match")
+	}
+	// 份额化资产只能转让份额
+	if err := checkWholeAsset(asset); err != nil {
+		return nil, nil, nil, err
+	}
+	// 资产或出让者被冻结时不能转出
+	owner, err := getUser(stub, ownerId)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal user error: %s", err))
+		return nil, nil, nil, err
 	}
-	if err := stub.PutState(constructUserKey(currentOwnerId), currentOwnerBytes); err != nil {
-		return shim.Error(fmt.Sprintf("update user error: %s", err))
+	if err := checkNotFrozen(stub, asset, owner); err != nil {
+		return nil, nil, nil, err
+	}
+	// 设置了多签的用户，资产只能在签署人同意后转出
+	if history.Multisig == nil {
+		if err := checkNotMultisig(owner); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	// 拍卖中的资产只能由该拍卖结算转出
+	if err := checkNotAuctioned(stub, assetId, history.AuctionId); err != nil {
+		return nil, nil, nil, err
+	}
+	// 有效质权的质权人都要同意转给受让者
+	if _, err := checkLienApprovals(stub, assetId, currentOwnerId); err != nil {
+		return nil, nil, nil, err
+	}
+	// 资产池中的资产随资产池一起转让，同样校验
+	for _, memberId := range asset.Members {
+		member, err := getAsset(stub, memberId)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("pool member %s: %s", memberId, err)
+		}
+		if member.PoolId != asset.Id || member.Owner != ownerId {
+			return nil, nil, nil, fmt.Errorf("pool member %s not match", memberId)
+		}
+		if err := checkNotFrozen(stub, member, owner); err != nil {
+			return nil, nil, nil, err
+		}
+		if _, err := checkLienApprovals(stub, memberId, currentOwnerId); err != nil {
+			return nil, nil, nil, err
+		}
 	}
 
-	// 插入资产变更记录
-	history := &AssetHistory{
-		AssetId:        assetId,
-		OriginOwnerId:  ownerId,
-		CurrentOwnerId: currentOwnerId,
+	return asset, owner, currentOwner, nil
+}
+
+// 读取资产
+func getAsset(stub shim.ChaincodeStubInterface, assetId string) (*Asset, error) {
+	assetBytes, err := stub.GetState(constructAssetKey(assetId))
+	if err != nil || len(assetBytes) == 0 {
+		return nil, fmt.Errorf("asset not found")
 	}
-	historyBytes, err := json.Marshal(history)
+
+	asset := new(Asset)
+	if err := json.Unmarshal(assetBytes, asset); err != nil {
+		return nil, fmt.Errorf("unmarshal asset error: %s", err)
+	}
+
+	return asset, nil
+}
+
+// 保存资产
+func putAsset(stub shim.ChaincodeStubInterface, asset *Asset) error {
+	stored := *asset
+	stored.Liens = nil
+	stored.Shares = nil
+	assetBytes, err := json.Marshal(&stored)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal assert history error: %s", err))
+		return fmt.Errorf("marshal asset error: %s", err)
+	}
+	if err := stub.PutState(constructAssetKey(asset.Id), assetBytes); err != nil {
+		return fmt.Errorf("save asset error: %s", err)
 	}
 
-	historyKey, err := stub.CreateCompositeKey("history", []string{
-		assetId,
-		ownerId,
-		currentOwnerId,
-	})
+	return nil
+}
+
+// 读取用户
+func getUser(stub shim.ChaincodeStubInterface, userId string) (*User, error) {
+	userBytes, err := stub.GetState(constructUserKey(userId))
+	if err != nil || len(userBytes) == 0 {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	user := new(User)
+	// 反序列化user
+	if err := json.Unmarshal(userBytes, user); err != nil {
+		return nil, fmt.Errorf("unmarshal user error: %s", err)
+	}
+
+	return user, nil
+}
+
+// 读取未销户的用户
+func getActiveUser(stub shim.ChaincodeStubInterface, userId string) (*User, error) {
+	user, err := getUser(stub, userId)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("create key error: %s", err))
+		return nil, err
+	}
+	if user.Status == userClosed {
+		return nil, fmt.Errorf("user %s closed", userId)
 	}
 
-	if err := stub.PutState(historyKey, historyBytes); err != nil {
-		return shim.Error(fmt.Sprintf("save assert history error: %s", err))
+	return user, nil
+}
+
+// 保存用户，资产列表由 owner~assetId 索引维护，不写入用户对象
+func putUser(stub shim.ChaincodeStubInterface, user *User) error {
+	stored := *user
+	stored.Assets = nil
+	// 序列化user
+	userBytes, err := json.Marshal(&stored)
+	if err != nil {
+		return fmt.Errorf("marshal user error: %s", err)
+	}
+	if err := stub.PutState(constructUserKey(user.Id), userBytes); err != nil {
+		return fmt.Errorf("update user error: %s", err)
 	}
 
-	return shim.Success(nil)
+	return nil
 }
 
 // 用户查询
@@ -348,9 +841,18 @@ func queryUser(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	}
 
 	// 3：验证数据是否存在 
-	userBytes, err := stub.GetState(constructUserKey(ownerId))
-	if err != nil || len(userBytes) == 0 {
-		return shim.Error("user not found")
+	user, err := getUser(stub, ownerId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 资产列表从拥有者索引中读取
+	if user.Assets, err = getOwnerAssetIds(stub, ownerId); err != nil {
+		return shim.Error(err.Error())
+	}
+	userBytes, err := json.Marshal(user)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal user error: %s", err))
 	}
 
 	return shim.Success(userBytes)
@@ -370,18 +872,34 @@ func queryAsset(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	}
 
 	// 3：验证数据是否存在 
-	assetBytes, err := stub.GetState(constructAssetKey(assetId))
-	if err != nil || len(assetBytes) == 0 {
-		return shim.Error("asset not found")
+	asset, err := getAsset(stub, assetId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	// 填充未解除的质权
+	if asset.Liens, err = getAssetLiens(stub, assetId, true); err != nil {
+		return shim.Error(err.Error())
+	}
+	// 填充份额化资产的股权结构表
+	if asset.Units > 0 {
+		if asset.Shares, err = getAssetShares(stub, assetId); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	assetBytes, err := json.Marshal(asset)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal asset error: %s", err))
 	}
 
 	return shim.Success(assetBytes)
 }
 
-// 资产变更历史查询
+// 资产变更历史查询，分页返回
+// 按记录类型过滤是在取出一页之后进行的，过滤后一页的记录数可能少于分页大小
+// 旧版本的记录需要先执行 migrateAssetHistory 迁移，见 history.go
 func queryAssetHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// 1：检查参数的个数,可以有1个或2个
-	if len(args) != 2 && len(args) != 1 {
+	// 1：检查参数的个数,可以有1到4个：资产 id、记录类型、分页大小、bookmark
+	if len(args) < 1 || len(args) > 4 {
 		return shim.Error("not enough args")
 	}
 
@@ -392,31 +910,38 @@ func queryAssetHistory(stub shim.ChaincodeStubInterface, args []string) pb.Respo
 	}
 
 	queryType := "all"
-	if len(args) == 2 {
+	if len(args) >= 2 && args[1] != "" {
 		queryType = args[1]
 	}
+	pageArgs := make([]string, 0)
+	if len(args) > 2 {
+		pageArgs = args[2:]
+	}
+	pageSize, bookmark, err := parsePageArgs(pageArgs)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
-	if queryType != "all" && queryType != "enroll" && queryType != "exchange" {
+	if queryType != "all" && queryType != historyEnroll && queryType != historyExchange && queryType != historyFreeze && queryType != "pool" && queryType != historyUpdate && queryType != historyMultisig {
 		return shim.Error(fmt.Sprintf("queryType unknown %s", queryType))
 	}
 
 	// 3：验证数据是否存在 
+	// 已拆包的资产池没有资产记录，变更记录仍然可以查询
 	assetBytes, err := stub.GetState(constructAssetKey(assetId))
-	if err != nil || len(assetBytes) == 0 {
-		return shim.Error("asset not found")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get asset error: %s", err))
+	}
+	if len(assetBytes) == 0 {
+		seqBytes, err := stub.GetState(constructHistorySeqKey(assetId))
+		if err != nil || len(seqBytes) == 0 {
+			return shim.Error("asset not found")
+		}
 	}
 
 	// 查询相关数据
-	keys := make([]string, 0)
-	keys = append(keys, assetId)
-	switch queryType {
-		case "enroll":
-			keys = append(keys, originOwner)
-		case "exchange", "all": // 不添加任何附件key
-		default:
-			return shim.Error(fmt.Sprintf("unsupport queryType: %s", queryType))
-	}
-	result, err := stub.GetStateByPartialCompositeKey("history", keys)
+	// 组合键中序号补齐了位数，按键的顺序遍历即为时间顺序
+	result, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(historyObjectType, []string{assetId}, pageSize, bookmark)
 	if err != nil {
 		return shim.Error(fmt.Sprintf("query history error: %s", err))
 	}
@@ -434,16 +959,26 @@ func queryAssetHistory(stub shim.ChaincodeStubInterface, args []string) pb.Respo
 			return shim.Error(fmt.Sprintf("unmarshal error: %s", err))
 		}
 
-		// 过滤掉不是资产转让的记录
-		//  ！= exchange ？
-		if queryType == "exchange" && history.OriginOwnerId == originOwner {
+		// 按记录类型过滤，freeze 包括冻结和解冻，pool 包括打包和拆包
+		action := history.action()
+		if action == historyUnfreeze {
+			action = historyFreeze
+		}
+		if action == historyBundle || action == historyUnbundle {
+			action = "pool"
+		}
+		if queryType != "all" && queryType != action {
 			continue
 		}
 
 		histories = append(histories, history)
 	}
 
-	historiesBytes, err := json.Marshal(histories)
+	historiesBytes, err := json.Marshal(&PageResult{
+		Records:  histories,
+		Count:    metadata.GetFetchedRecordsCount(),
+		Bookmark: metadata.GetBookmark(),
+	})
 	if err != nil {
 		return shim.Error(fmt.Sprintf("marshal error: %s", err))
 	}
@@ -454,7 +989,14 @@ func queryAssetHistory(stub shim.ChaincodeStubInterface, args []string) pb.Respo
 // Init is called during Instantiate transaction after the chaincode container
 // has been established for the first time, allowing the chaincode to
 // initialize its internal data
+// 参数见 initRoles：角色授予和方法的 ACL，实例化和升级时都可以传入
 func (c *AssertsManageCC) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+
+	if err := initRoles(stub, args); err != nil {
+		return shim.Error(err.Error())
+	}
+
 	return shim.Success(nil)
 }
 
@@ -465,9 +1007,18 @@ func (c *AssertsManageCC) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 	// 调用shim包的方法，获取函数/方法名和对应的参数
 	funcName, args := stub.GetFunctionAndParameters()
 
+	// 按账本上的 ACL 校验调用者的角色，见 roles.go
+	if err := checkAcl(stub, funcName); err != nil {
+		return shim.Error(err.Error())
+	}
+
 	switch funcName {
 	case "userRegister":
 		return userRegister(stub, args)
+	case "updateUserProfile":
+		return updateUserProfile(stub, args)
+	case "queryUserProfileHistory":
+		return queryUserProfileHistory(stub, args)
 	case "userDestroy":
 		return userDestroy(stub, args)
 	case "assetEnroll":
@@ -480,11 +1031,135 @@ func (c *AssertsManageCC) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 		return queryAsset(stub, args)
 	case "queryAssetHistory":
 		return queryAssetHistory(stub, args)
+	case "queryAssets":
+		return queryAssets(stub, args)
+	case "queryAssetPrivate":
+		return queryAssetPrivate(stub, args)
+	case "assetUpdate":
+		return assetUpdate(stub, args)
+	case "assetAttachDocument":
+		return assetAttachDocument(stub, args)
+	case "queryAssetDocuments":
+		return queryAssetDocuments(stub, args)
+	case "verifyAssetDocument":
+		return verifyAssetDocument(stub, args)
+	case "assetFreeze":
+		return assetFreeze(stub, args)
+	case "assetUnfreeze":
+		return assetUnfreeze(stub, args)
+	case "userFreeze":
+		return userFreeze(stub, args)
+	case "userUnfreeze":
+		return userUnfreeze(stub, args)
+	case "lienRegister":
+		return lienRegister(stub, args)
+	case "lienAccept":
+		return lienAccept(stub, args)
+	case "lienRelease":
+		return lienRelease(stub, args)
+	case "lienApprove":
+		return lienApprove(stub, args)
+	case "queryAssetLiens":
+		return queryAssetLiens(stub, args)
+	case "tokenMint":
+		return tokenMint(stub, args)
+	case "tokenTransfer":
+		return tokenTransfer(stub, args)
+	case "balanceOf":
+		return balanceOf(stub, args)
+	case "buyAsset":
+		return buyAsset(stub, args)
+	case "listingCreate":
+		return listingCreate(stub, args)
+	case "listingUpdate":
+		return listingUpdate(stub, args)
+	case "listingCancel":
+		return listingCancel(stub, args)
+	case "listingPurchase":
+		return listingPurchase(stub, args)
+	case "queryListing":
+		return queryListing(stub, args)
+	case "queryListings":
+		return queryListings(stub, args)
+	case "auctionOpen":
+		return auctionOpen(stub, args)
+	case "auctionBid":
+		return auctionBid(stub, args)
+	case "auctionReveal":
+		return auctionReveal(stub, args)
+	case "auctionSettle":
+		return auctionSettle(stub, args)
+	case "auctionCancel":
+		return auctionCancel(stub, args)
+	case "queryAuction":
+		return queryAuction(stub, args)
+	case "unitsTransfer":
+		return unitsTransfer(stub, args)
+	case "poolBundle":
+		return poolBundle(stub, args)
+	case "poolUnbundle":
+		return poolUnbundle(stub, args)
+	case "userSetSigners":
+		return userSetSigners(stub, args)
+	case "multisigApprove":
+		return multisigApprove(stub, args)
+	case "multisigReject":
+		return multisigReject(stub, args)
+	case "multisigExpire":
+		return multisigExpire(stub, args)
+	case "queryPendingTransfer":
+		return queryPendingTransfer(stub, args)
+	case "listUsers":
+		return listUsers(stub, args)
+	case "listAssets":
+		return listAssets(stub, args)
+	case "queryAssetLedgerHistory":
+		return queryAssetLedgerHistory(stub, args)
+	case "queryUserLedgerHistory":
+		return queryUserLedgerHistory(stub, args)
+	case "migrateOwnerIndex":
+		return migrateOwnerIndex(stub, args)
+	case "migrateAssetEndorsement":
+		return migrateAssetEndorsement(stub, args)
+	case "migrateAssetHistory":
+		return migrateAssetHistory(stub, args)
+	case "assetClassDefine":
+		return assetClassDefine(stub, args)
+	case "queryAssetClass":
+		return queryAssetClass(stub, args)
+	case "transferOffer":
+		return transferOffer(stub, args)
+	case "transferAccept":
+		return transferAccept(stub, args)
+	case "transferReject":
+		return transferReject(stub, args)
+	case "transferCancel":
+		return transferCancel(stub, args)
+	case "queryTransferOffer":
+		return queryTransferOffer(stub, args)
+	case "swapPropose":
+		return swapPropose(stub, args)
+	case "swapAccept":
+		return swapAccept(stub, args)
+	case "swapCancel":
+		return swapCancel(stub, args)
+	case "querySwap":
+		return querySwap(stub, args)
+	case "grantRole":
+		return grantRole(stub, args)
+	case "revokeRole":
+		return revokeRole(stub, args)
+	case "setAcl":
+		return setAcl(stub, args)
+	case "queryRoles":
+		return queryRoles(stub, args)
+	case "queryAcl":
+		return queryAcl(stub, args)
 	default:
 		return shim.Error(fmt.Sprintf("unsupported function: %s", funcName))
 	}
 
-	// stub.SetEvent("name", []byte("data"))
+	// 链码事件由各方法在状态写入成功后发出，见 events.go
 }
 
 func main() {
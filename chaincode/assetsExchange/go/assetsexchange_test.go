@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestUserRegisterBindsCaller(t *testing.T) {
+	s := newTestStub(t)
+
+	s.asUser(testMspId2, "carol").mustInvoke("userRegister", "Carol", "carol")
+	carol := s.getUser("carol")
+	if carol.MspId != testMspId2 || carol.Subject != "CN=carol" {
+		t.Fatalf("user bound to %s %s", carol.MspId, carol.Subject)
+	}
+	if carol.Status != userActive || carol.profile().KycStatus != kycPending {
+		t.Fatalf("new user status %s kyc %s", carol.Status, carol.profile().KycStatus)
+	}
+
+	// 只有开户员或管理员可以为其它身份开户
+	msg := s.asUser(testMspId2, "carol").mustFail("userRegister", "Dave", "dave", testMspId2, "CN=dave")
+	if !strings.Contains(msg, "permission denied") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+	s.asUser(testMspId2, "carol").mustFail("userRegister", "Carol", "carol")
+}
+
+func TestAssetExchangeAccess(t *testing.T) {
+	s := newTestStub(t)
+	s.defineClass()
+	s.registerUser(testMspId, "alice")
+	s.registerUser(testMspId, "bob")
+	s.enrollAsset("a1", "alice")
+
+	tests := []struct {
+		name    string
+		caller  string
+		from    string
+		to      string
+		wantErr string
+	}{
+		{"other user", "bob", "alice", "bob", "permission denied: caller is not user alice"},
+		{"owner", "alice", "alice", "bob", ""},
+		{"previous owner", "alice", "bob", "alice", "permission denied: caller is not user bob"},
+		{"not owner", "alice", "alice", "bob", "asset owner not match"},
+		{"admin", "", "bob", "alice", ""},
+	}
+	for _, tt := range tests {
+		if tt.caller == "" {
+			s.asAdmin()
+		} else {
+			s.asUser(testMspId, tt.caller)
+		}
+		resp := s.invoke("assetExchange", tt.from, "a1", tt.to)
+		if tt.wantErr == "" {
+			if resp.Status != 200 {
+				t.Fatalf("%s: %s", tt.name, resp.Message)
+			}
+			if owner := s.getAsset("a1").Owner; owner != tt.to {
+				t.Fatalf("%s: owner is %s, want %s", tt.name, owner, tt.to)
+			}
+			evt := s.lastEvent()
+			if evt.Type != eventAssetTransferred || evt.From != tt.from || evt.To != tt.to {
+				t.Fatalf("%s: unexpected event %+v", tt.name, evt)
+			}
+			continue
+		}
+		if resp.Status == 200 || !strings.Contains(resp.Message, tt.wantErr) {
+			t.Fatalf("%s: got %d %q, want error %q", tt.name, resp.Status, resp.Message, tt.wantErr)
+		}
+	}
+}
+
+func TestAssetExchangeRecipient(t *testing.T) {
+	s := newTestStub(t)
+	s.defineClass()
+	s.registerUser(testMspId, "alice")
+	s.enrollAsset("a1", "alice")
+
+	// 未通过 KYC 核验的用户不能接收资产
+	s.asUser(testMspId, "bob").mustInvoke("userRegister", "Bob", "bob")
+	msg := s.asUser(testMspId, "alice").mustFail("assetExchange", "alice", "a1", "bob")
+	if !strings.Contains(msg, "kyc status is pending") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+	s.asUser(testMspId, "alice").mustFail("assetExchange", "alice", "a1", "alice")
+	s.asUser(testMspId, "alice").mustFail("assetExchange", "alice", "a1", "nobody")
+	if owner := s.getAsset("a1").Owner; owner != "alice" {
+		t.Fatalf("owner changed to %s", owner)
+	}
+}
+
+func TestUserDestroyAccess(t *testing.T) {
+	s := newTestStub(t)
+	s.registerUser(testMspId, "alice")
+	s.registerUser(testMspId, "bob")
+
+	msg := s.asUser(testMspId, "bob").mustFail("userDestroy", "alice")
+	if !strings.Contains(msg, "permission denied") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+	s.asUser(testMspId, "alice").mustInvoke("userDestroy", "alice")
+
+	alice := s.getUser("alice")
+	if alice.Status != userClosed || alice.Closure == nil || alice.Closure.ClosedBy != "CN=alice" {
+		t.Fatalf("unexpected closure %+v", alice.Closure)
+	}
+	// 销户后的用户不能再操作
+	s.asUser(testMspId, "alice").mustFail("userDestroy", "alice")
+}
+
+func TestAssetHistoryRepeatedTransfers(t *testing.T) {
+	s := newTestStub(t)
+	s.defineClass()
+	s.registerUser(testMspId, "alice")
+	s.registerUser(testMspId, "bob")
+	s.enrollAsset("a1", "alice")
+
+	// 同一对拥有者之间来回转让，每次都追加一条记录
+	owners := []string{"alice", "bob", "alice", "bob", "alice"}
+	for i := 1; i < len(owners); i++ {
+		s.asUser(testMspId, owners[i-1]).mustInvoke("assetExchange", owners[i-1], "a1", owners[i])
+	}
+
+	histories := s.assetHistory("a1")
+	if len(histories) != len(owners) {
+		t.Fatalf("got %d histories, want %d", len(histories), len(owners))
+	}
+	txIds := make(map[string]bool)
+	for i, h := range histories {
+		if h.Seq != uint64(i+1) {
+			t.Fatalf("history %d has seq %d", i, h.Seq)
+		}
+		if h.CurrentOwnerId != owners[i] {
+			t.Fatalf("history %d current owner %s, want %s", i, h.CurrentOwnerId, owners[i])
+		}
+		if txIds[h.TxId] {
+			t.Fatalf("history %d reuses tx %s", i, h.TxId)
+		}
+		txIds[h.TxId] = true
+		if i > 0 && !h.Timestamp.After(histories[i-1].Timestamp) {
+			t.Fatalf("history %d not after history %d", i, i-1)
+		}
+	}
+	if histories[0].Action != historyEnroll || histories[0].OriginOwnerId != originOwner {
+		t.Fatalf("first history is %s from %s", histories[0].Action, histories[0].OriginOwnerId)
+	}
+	if histories[4].Action != historyExchange || histories[4].OriginOwnerId != "bob" {
+		t.Fatalf("last history is %s from %s", histories[4].Action, histories[4].OriginOwnerId)
+	}
+
+	// 按类型过滤
+	page := &PageResult{Records: &[]*AssetHistory{}}
+	if err := json.Unmarshal(s.mustInvoke("queryAssetHistory", "a1", historyExchange), page); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(*page.Records.(*[]*AssetHistory)); n != 4 {
+		t.Fatalf("got %d exchange histories, want 4", n)
+	}
+	s.mustFail("queryAssetHistory", "a1", "transfer")
+}
@@ -0,0 +1,743 @@
+package main
+
+// 密封竞价拍卖（commit-reveal）：不良资产包通常以拍卖方式处置
+// 1. 拥有者发起拍卖，设置保留价、出价截止时间和揭示截止时间
+// 2. 出价截止前，竞买人只提交出价的哈希 sha256("拍卖id|竞买人id|金额|盐值")，链上看不到金额
+//    盐值为至少 16 字节随机数的十六进制，否则金额可以被穷举；竞买人必须未销户、通过 KYC 核验且未被冻结
+// 3. 出价截止后、揭示截止前，竞买人公开金额和盐值，链码核对哈希；不低于保留价的出价从余额中冻结到出价记录中
+// 4. 揭示截止后结算：按出价从高到低（同价时先出价者优先）选择第一个能够交割的出价得标，
+//    竞买人已销户、未通过 KYC 核验或被冻结，以及资产不能转给该竞买人（如质权人未同意）时跳过该出价；
+//    资产经 transferAssetWithHistory 转给得标者，得标金额付给拥有者，其余冻结的出价退回
+//    没有能够交割的出价时拍卖流拍，所有冻结的出价退回
+// 未在揭示截止前公开的出价视为放弃
+// 出价以组合键 bid~拍卖id~竞买人id 存储，每个竞买人只有一个出价，截止前可以覆盖
+// 拍卖期间资产被锁定，组合键 auction~资产id 记录进行中的拍卖，资产不能以其它方式转让、挂牌、互换、打包或再次拍卖，
+// 拍卖结算、流拍或取消时解除锁定
+// 组合键 bidder~竞买人id~拍卖id 记录竞买人参与的进行中的拍卖，结算、流拍或取消时删除；
+// 有进行中出价的用户不能销户，避免冻结的出价退回到已销户的用户
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	bidObjectType = "bid"
+	auctionIndex  = "auction~assetId"
+	// 竞买人参与的进行中的拍卖，有进行中出价的用户不能销户
+	bidderIndex = "bidder~auctionId"
+
+	// 出价盐值的最小字节数
+	minBidSaltSize = 16
+
+	// 拍卖状态
+	auctionStateOpen      = "open"
+	auctionStateSettled   = "settled"
+	auctionStateFailed    = "failed" // 流拍
+	auctionStateCancelled = "cancelled"
+)
+
+// Auction 资产拍卖
+type Auction struct {
+	Id             string    `json:"id"` // 发起拍卖的交易 id
+	AssetId        string    `json:"asset_id"`
+	SellerId       string    `json:"seller_id"`
+	ReservePrice   int64     `json:"reserve_price"`   // 保留价，单位为代币的最小单位
+	BidDeadline    time.Time `json:"bid_deadline"`    // 出价截止时间
+	RevealDeadline time.Time `json:"reveal_deadline"` // 揭示截止时间
+	State          string    `json:"state"`           // open / settled / failed / cancelled
+	WinnerId       string    `json:"winner_id,omitempty"`
+	WinningBid     int64     `json:"winning_bid,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	// 出价列表，只在查询时填充，不写入账本
+	Bids []*Bid `json:"bids,omitempty"`
+}
+
+// Bid 密封出价
+type Bid struct {
+	AuctionId   string     `json:"auction_id"`
+	BidderId    string     `json:"bidder_id"`
+	Hash        string     `json:"hash"`             // 出价哈希，十六进制
+	Amount      int64      `json:"amount,omitempty"` // 揭示后的金额
+	Revealed    bool       `json:"revealed"`
+	Escrowed    bool       `json:"escrowed"` // 金额已从竞买人余额中冻结，结算或取消时付给拥有者或退回
+	SubmittedAt time.Time  `json:"submitted_at"`
+	RevealedAt  *time.Time `json:"revealed_at,omitempty"`
+}
+
+// 出价哈希，竞买人在链下用同样的方法计算后提交
+func bidHash(auctionId, bidderId string, amount int64, salt string) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%s", auctionId, bidderId, amount, salt)))
+	return hex.EncodeToString(hash[:])
+}
+
+// 校验出价盐值为至少 minBidSaltSize 字节的十六进制
+func checkBidSalt(salt string) error {
+	if saltBytes, err := hex.DecodeString(salt); err != nil || len(saltBytes) < minBidSaltSize {
+		return fmt.Errorf("invalid bid salt, expect at least %d random bytes hex encoded", minBidSaltSize)
+	}
+
+	return nil
+}
+
+// 以 auction_ 开头的，认为是拍卖
+func constructAuctionKey(auctionId string) string {
+	return fmt.Sprintf("auction_%s", auctionId)
+}
+
+func getAuction(stub shim.ChaincodeStubInterface, auctionId string) (*Auction, error) {
+	auctionBytes, err := stub.GetState(constructAuctionKey(auctionId))
+	if err != nil || len(auctionBytes) == 0 {
+		return nil, fmt.Errorf("auction not found")
+	}
+
+	auction := new(Auction)
+	if err := json.Unmarshal(auctionBytes, auction); err != nil {
+		return nil, fmt.Errorf("unmarshal auction error: %s", err)
+	}
+
+	return auction, nil
+}
+
+func putAuction(stub shim.ChaincodeStubInterface, auction *Auction) error {
+	stored := *auction
+	stored.Bids = nil
+	auctionBytes, err := json.Marshal(&stored)
+	if err != nil {
+		return fmt.Errorf("marshal auction error: %s", err)
+	}
+	if err := stub.PutState(constructAuctionKey(auction.Id), auctionBytes); err != nil {
+		return fmt.Errorf("save auction error: %s", err)
+	}
+
+	return nil
+}
+
+func getBid(stub shim.ChaincodeStubInterface, auctionId, bidderId string) (*Bid, error) {
+	bidKey, err := stub.CreateCompositeKey(bidObjectType, []string{auctionId, bidderId})
+	if err != nil {
+		return nil, fmt.Errorf("create key error: %s", err)
+	}
+	bidBytes, err := stub.GetState(bidKey)
+	if err != nil {
+		return nil, fmt.Errorf("get bid error: %s", err)
+	}
+	if len(bidBytes) == 0 {
+		return nil, nil
+	}
+
+	bid := new(Bid)
+	if err := json.Unmarshal(bidBytes, bid); err != nil {
+		return nil, fmt.Errorf("unmarshal bid error: %s", err)
+	}
+
+	return bid, nil
+}
+
+func putBid(stub shim.ChaincodeStubInterface, bid *Bid) error {
+	bidKey, err := stub.CreateCompositeKey(bidObjectType, []string{bid.AuctionId, bid.BidderId})
+	if err != nil {
+		return fmt.Errorf("create key error: %s", err)
+	}
+	bidBytes, err := json.Marshal(bid)
+	if err != nil {
+		return fmt.Errorf("marshal bid error: %s", err)
+	}
+	if err := stub.PutState(bidKey, bidBytes); err != nil {
+		return fmt.Errorf("save bid error: %s", err)
+	}
+
+	return nil
+}
+
+// 资产进行中的拍卖 id，没有时为空
+func getOpenAuctionId(stub shim.ChaincodeStubInterface, assetId string) (string, error) {
+	indexKey, err := stub.CreateCompositeKey(auctionIndex, []string{assetId})
+	if err != nil {
+		return "", fmt.Errorf("create key error: %s", err)
+	}
+	auctionId, err := stub.GetState(indexKey)
+	if err != nil {
+		return "", fmt.Errorf("get auction index error: %s", err)
+	}
+
+	return string(auctionId), nil
+}
+
+func putOpenAuctionId(stub shim.ChaincodeStubInterface, assetId, auctionId string) error {
+	indexKey, err := stub.CreateCompositeKey(auctionIndex, []string{assetId})
+	if err != nil {
+		return fmt.Errorf("create key error: %s", err)
+	}
+	if auctionId == "" {
+		if err := stub.DelState(indexKey); err != nil {
+			return fmt.Errorf("delete auction index error: %s", err)
+		}
+		return nil
+	}
+	if err := stub.PutState(indexKey, []byte(auctionId)); err != nil {
+		return fmt.Errorf("save auction index error: %s", err)
+	}
+
+	return nil
+}
+
+// 记录或删除竞买人参与的进行中的拍卖
+func putBidderIndex(stub shim.ChaincodeStubInterface, bidderId, auctionId string, open bool) error {
+	indexKey, err := stub.CreateCompositeKey(bidderIndex, []string{bidderId, auctionId})
+	if err != nil {
+		return fmt.Errorf("create key error: %s", err)
+	}
+	if !open {
+		if err := stub.DelState(indexKey); err != nil {
+			return fmt.Errorf("delete bidder index error: %s", err)
+		}
+		return nil
+	}
+	if err := stub.PutState(indexKey, []byte{0x00}); err != nil {
+		return fmt.Errorf("save bidder index error: %s", err)
+	}
+
+	return nil
+}
+
+// 竞买人参与的进行中的拍卖 id
+func getBidderAuctionIds(stub shim.ChaincodeStubInterface, bidderId string) ([]string, error) {
+	result, err := stub.GetStateByPartialCompositeKey(bidderIndex, []string{bidderId})
+	if err != nil {
+		return nil, fmt.Errorf("query bidder index error: %s", err)
+	}
+	defer result.Close()
+
+	auctionIds := make([]string, 0)
+	for result.HasNext() {
+		indexVal, err := result.Next()
+		if err != nil {
+			return nil, fmt.Errorf("query error: %s", err)
+		}
+		_, attrs, err := stub.SplitCompositeKey(indexVal.GetKey())
+		if err != nil || len(attrs) != 2 {
+			return nil, fmt.Errorf("invalid bidder index key: %s", indexVal.GetKey())
+		}
+		auctionIds = append(auctionIds, attrs[1])
+	}
+
+	return auctionIds, nil
+}
+
+// 校验资产没有进行中的拍卖，except 为正在结算的拍卖
+func checkNotAuctioned(stub shim.ChaincodeStubInterface, assetId, except string) error {
+	auctionId, err := getOpenAuctionId(stub, assetId)
+	if err != nil {
+		return err
+	}
+	if auctionId != "" && auctionId != except {
+		return fmt.Errorf("asset %s is under auction %s", assetId, auctionId)
+	}
+
+	return nil
+}
+
+// 读取拍卖的所有出价
+func getAuctionBids(stub shim.ChaincodeStubInterface, auctionId string) ([]*Bid, error) {
+	result, err := stub.GetStateByPartialCompositeKey(bidObjectType, []string{auctionId})
+	if err != nil {
+		return nil, fmt.Errorf("query bids error: %s", err)
+	}
+	defer result.Close()
+
+	bids := make([]*Bid, 0)
+	for result.HasNext() {
+		bidVal, err := result.Next()
+		if err != nil {
+			return nil, fmt.Errorf("query error: %s", err)
+		}
+
+		bid := new(Bid)
+		if err := json.Unmarshal(bidVal.GetValue(), bid); err != nil {
+			return nil, fmt.Errorf("unmarshal bid error: %s", err)
+		}
+		bids = append(bids, bid)
+	}
+
+	return bids, nil
+}
+
+// 拍卖结束时调用：退回所有冻结的出价，except 为得标者，其出价付给拥有者，并删除所有竞买人的索引
+// 每个竞买人只有一个出价，拥有者不能出价，同一交易内不会重复写入同一个余额
+func releaseBids(stub shim.ChaincodeStubInterface, bids []*Bid, except string) error {
+	for _, bid := range bids {
+		if err := putBidderIndex(stub, bid.BidderId, bid.AuctionId, false); err != nil {
+			return err
+		}
+		if !bid.Escrowed || bid.BidderId == except {
+			continue
+		}
+		if err := creditTokens(stub, bid.BidderId, bid.Amount); err != nil {
+			return err
+		}
+		bid.Escrowed = false
+		if err := putBid(stub, bid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 校验出价能否交割：竞买人未被冻结，资产可以转给竞买人，只读取不写入
+func checkBidSettleable(stub shim.ChaincodeStubInterface, auction *Auction, bid *Bid) error {
+	bidder, err := getUser(stub, bid.BidderId)
+	if err != nil {
+		return err
+	}
+	if err := checkUserNotFrozen(stub, bidder); err != nil {
+		return err
+	}
+
+	_, _, _, err = checkAssetTransfer(stub, &AssetHistory{
+		AssetId:        auction.AssetId,
+		OriginOwnerId:  auction.SellerId,
+		CurrentOwnerId: bid.BidderId,
+		AuctionId:      auction.Id,
+	})
+	return err
+}
+
+func emitAuctionEvent(stub shim.ChaincodeStubInterface, eventType string, auction *Auction, to string, amount int64) error {
+	return emitEvent(stub, &ChaincodeEvent{
+		Type:    eventType,
+		AssetId: auction.AssetId,
+		From:    auction.SellerId,
+		To:      to,
+		Amount:  amount,
+		RefId:   auction.Id,
+	})
+}
+
+// 发起拍卖，只有资产拥有者本人或管理员可以发起
+func auctionOpen(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：拥有者 id、资产 id、保留价、出价截止时间、揭示截止时间
+	if len(args) != 5 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	sellerId := args[0]
+	assetId := args[1]
+	if sellerId == "" || assetId == "" {
+		return shim.Error("invalid args")
+	}
+	reservePrice, err := parseTokenAmount(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	bidDeadline, err := time.Parse(time.RFC3339, args[3])
+	if err != nil {
+		return shim.Error(fmt.Sprintf("invalid bid deadline: %s", err))
+	}
+	revealDeadline, err := time.Parse(time.RFC3339, args[4])
+	if err != nil {
+		return shim.Error(fmt.Sprintf("invalid reveal deadline: %s", err))
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !bidDeadline.After(now) || !revealDeadline.After(bidDeadline) {
+		return shim.Error("bid deadline must be in the future and before reveal deadline")
+	}
+
+	// 3：验证数据是否存在
+	seller, err := getUserWithAccess(stub, sellerId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkNotMultisig(seller); err != nil {
+		return shim.Error(err.Error())
+	}
+	asset, err := getAsset(stub, assetId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if asset.Owner != sellerId {
+		return shim.Error("asset owner not match")
+	}
+	if err := checkWholeAsset(asset); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkNotFrozen(stub, asset, seller); err != nil {
+		return errorResponse(err)
+	}
+	if err := checkNotAuctioned(stub, assetId, ""); err != nil {
+		return shim.Error(err.Error())
+	}
+	if listingId, err := getOpenListingId(stub, assetId); err != nil {
+		return shim.Error(err.Error())
+	} else if listingId != "" {
+		return shim.Error(fmt.Sprintf("asset %s already listed in %s", assetId, listingId))
+	}
+
+	// 4： 状态写入
+	auction := &Auction{
+		Id:             stub.GetTxID(),
+		AssetId:        assetId,
+		SellerId:       sellerId,
+		ReservePrice:   reservePrice,
+		BidDeadline:    bidDeadline.UTC(),
+		RevealDeadline: revealDeadline.UTC(),
+		State:          auctionStateOpen,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := putAuction(stub, auction); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putOpenAuctionId(stub, assetId, auction.Id); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitAuctionEvent(stub, eventAuctionOpened, auction, "", reservePrice); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 返回拍卖 id
+	return shim.Success([]byte(auction.Id))
+}
+
+// 提交密封出价，出价截止前可以覆盖自己的出价
+func auctionBid(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：拍卖 id、竞买人 id、出价哈希
+	if len(args) != 3 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	auctionId := args[0]
+	bidderId := args[1]
+	hash := args[2]
+	if auctionId == "" || bidderId == "" {
+		return shim.Error("invalid args")
+	}
+	if hashBytes, err := hex.DecodeString(hash); err != nil || len(hashBytes) != sha256.Size {
+		return shim.Error("invalid bid hash, expect hex encoded sha256")
+	}
+
+	// 3：验证数据是否存在
+	auction, err := getAuction(stub, auctionId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if auction.State != auctionStateOpen || !now.Before(auction.BidDeadline) {
+		return shim.Error("auction not accepting bids")
+	}
+	if bidderId == auction.SellerId {
+		return shim.Error("seller cannot bid")
+	}
+	// 竞买人必须未销户、通过 KYC 核验且未被冻结，结算时会再次校验
+	bidder, err := getUserWithAccess(stub, bidderId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if bidder.Status == userClosed {
+		return shim.Error(fmt.Sprintf("user %s closed", bidderId))
+	}
+	if err := checkUserVerified(bidder); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkUserNotFrozen(stub, bidder); err != nil {
+		return errorResponse(err)
+	}
+
+	// 4： 状态写入
+	if err := putBid(stub, &Bid{
+		AuctionId:   auctionId,
+		BidderId:    bidderId,
+		Hash:        hash,
+		SubmittedAt: now,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putBidderIndex(stub, bidderId, auctionId, true); err != nil {
+		return shim.Error(err.Error())
+	}
+	// 事件中只有竞买人，没有金额
+	if err := emitAuctionEvent(stub, eventBidSubmitted, auction, bidderId, 0); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 揭示出价，核对哈希，不低于保留价的出价冻结竞买人的代币
+func auctionReveal(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：拍卖 id、竞买人 id、金额、盐值
+	if len(args) != 4 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	auctionId := args[0]
+	bidderId := args[1]
+	salt := args[3]
+	if auctionId == "" || bidderId == "" {
+		return shim.Error("invalid args")
+	}
+	amount, err := parseTokenAmount(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkBidSalt(salt); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 3：验证数据是否存在
+	auction, err := getAuction(stub, auctionId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if auction.State != auctionStateOpen || now.Before(auction.BidDeadline) || !now.Before(auction.RevealDeadline) {
+		return shim.Error("auction not in reveal period")
+	}
+	bidder, err := getUserWithAccess(stub, bidderId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	bid, err := getBid(stub, auctionId, bidderId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if bid == nil {
+		return shim.Error("bid not found")
+	}
+	if bid.Revealed {
+		return shim.Error("bid already revealed")
+	}
+	if bidHash(auctionId, bidderId, amount, salt) != bid.Hash {
+		return shim.Error("bid hash not match")
+	}
+
+	// 4： 状态写入，低于保留价的出价只记录，不冻结
+	if amount >= auction.ReservePrice {
+		if err := checkUserNotFrozen(stub, bidder); err != nil {
+			return errorResponse(err)
+		}
+		if err := debitTokens(stub, bidderId, amount); err != nil {
+			return shim.Error(err.Error())
+		}
+		bid.Escrowed = true
+	}
+	bid.Amount = amount
+	bid.Revealed = true
+	bid.RevealedAt = &now
+	if err := putBid(stub, bid); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitAuctionEvent(stub, eventBidRevealed, auction, bidderId, amount); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 结算拍卖，揭示截止后任何人都可以提交
+func auctionSettle(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	auctionId := args[0]
+	if auctionId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	auction, err := getAuction(stub, auctionId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if auction.State != auctionStateOpen {
+		return shim.Error(fmt.Sprintf("auction is %s", auction.State))
+	}
+	if now.Before(auction.RevealDeadline) {
+		return shim.Error("reveal period not ended")
+	}
+	bids, err := getAuctionBids(stub, auctionId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 按出价从高到低排序，同价时先出价者优先
+	escrowed := make([]*Bid, 0, len(bids))
+	for _, bid := range bids {
+		if bid.Escrowed {
+			escrowed = append(escrowed, bid)
+		}
+	}
+	sort.SliceStable(escrowed, func(i, j int) bool {
+		if escrowed[i].Amount != escrowed[j].Amount {
+			return escrowed[i].Amount > escrowed[j].Amount
+		}
+		return escrowed[i].SubmittedAt.Before(escrowed[j].SubmittedAt)
+	})
+	// 第一个能够交割的出价得标，校验只读取不写入，不能交割的出价跳过
+	var winner *Bid
+	for _, bid := range escrowed {
+		if err := checkBidSettleable(stub, auction, bid); err == nil {
+			winner = bid
+			break
+		}
+	}
+
+	// 4： 状态写入
+	eventType := eventAuctionFailed
+	if winner != nil {
+		if err := transferAssetWithHistory(stub, &AssetHistory{
+			AssetId:        auction.AssetId,
+			OriginOwnerId:  auction.SellerId,
+			CurrentOwnerId: winner.BidderId,
+			Action:         historyExchange,
+			Price:          winner.Amount,
+			AuctionId:      auction.Id,
+		}); err != nil {
+			return errorResponse(err)
+		}
+		if err := creditTokens(stub, auction.SellerId, winner.Amount); err != nil {
+			return shim.Error(err.Error())
+		}
+		winner.Escrowed = false
+		if err := putBid(stub, winner); err != nil {
+			return shim.Error(err.Error())
+		}
+		auction.State = auctionStateSettled
+		auction.WinnerId = winner.BidderId
+		auction.WinningBid = winner.Amount
+		eventType = eventAssetSold
+	} else {
+		auction.State = auctionStateFailed
+	}
+	if err := releaseBids(stub, bids, auction.WinnerId); err != nil {
+		return shim.Error(err.Error())
+	}
+	auction.UpdatedAt = now
+	if err := putAuction(stub, auction); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putOpenAuctionId(stub, auction.AssetId, ""); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitAuctionEvent(stub, eventType, auction, auction.WinnerId, auction.WinningBid); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 取消拍卖并退回冻结的出价
+// 拥有者只能在出价截止前取消，之后只有管理员可以取消，避免拥有者看到出价后反悔
+func auctionCancel(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	auctionId := args[0]
+	if auctionId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	auction, err := getAuction(stub, auctionId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if auction.State != auctionStateOpen {
+		return shim.Error(fmt.Sprintf("auction is %s", auction.State))
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !isAdmin(stub) {
+		if !now.Before(auction.BidDeadline) {
+			return shim.Error("permission denied: only admin can cancel after bid deadline")
+		}
+		if _, err := getUserWithAccess(stub, auction.SellerId); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	bids, err := getAuctionBids(stub, auctionId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 4： 状态写入
+	if err := releaseBids(stub, bids, ""); err != nil {
+		return shim.Error(err.Error())
+	}
+	auction.State = auctionStateCancelled
+	auction.UpdatedAt = now
+	if err := putAuction(stub, auction); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putOpenAuctionId(stub, auction.AssetId, ""); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitAuctionEvent(stub, eventAuctionCancelled, auction, "", 0); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 拍卖查询，包括所有出价，未揭示的出价只有哈希
+func queryAuction(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	auctionId := args[0]
+	if auctionId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	auction, err := getAuction(stub, auctionId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if auction.Bids, err = getAuctionBids(stub, auctionId); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	auctionBytes, err := json.Marshal(auction)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(auctionBytes)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSalt = "00112233445566778899aabbccddeeff"
+
+func TestBidHash(t *testing.T) {
+	// sha256("tx9|bob|500|" + testSalt)
+	want := "7fb4289499d0c0715c2a35cf862573dd257994ec2ffaa2bff8bc125334ee8994"
+	if got := bidHash("tx9", "bob", 500, testSalt); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	// 任何一项不同，哈希都不同
+	others := []string{
+		bidHash("tx8", "bob", 500, testSalt),
+		bidHash("tx9", "bo", 500, testSalt),
+		bidHash("tx9", "bob", 501, testSalt),
+		bidHash("tx9", "bob", 500, testSalt+"00"),
+	}
+	for i, other := range others {
+		if other == want {
+			t.Errorf("variant %d has the same hash", i)
+		}
+	}
+}
+
+func TestCheckBidSalt(t *testing.T) {
+	tests := []struct {
+		salt    string
+		wantErr bool
+	}{
+		{testSalt, false},
+		{strings.ToUpper(testSalt), false},
+		{testSalt + "0011", false},
+		{"", true},
+		{testSalt[:30], true},
+		{testSalt[:31], true},
+		{"salt", true},
+		{strings.Repeat("zz", 16), true},
+	}
+	for _, tt := range tests {
+		if err := checkBidSalt(tt.salt); (err != nil) != tt.wantErr {
+			t.Errorf("checkBidSalt(%q) = %v, want error %v", tt.salt, err, tt.wantErr)
+		}
+	}
+}
+
+// 拥有者 alice 持有 a1，竞买人 bob、carol、dave 各有 1000 代币
+func newAuctionStub(t *testing.T) *testStub {
+	s := newTestStub(t)
+	s.defineClass()
+	for _, id := range []string{"alice", "bob", "carol", "dave"} {
+		s.registerUser(testMspId, id)
+	}
+	s.enrollAsset("a1", "alice")
+	for _, id := range []string{"bob", "carol", "dave"} {
+		s.mint(id, 1000)
+	}
+
+	return s
+}
+
+// 发起拍卖：保留价 400，一小时后截止出价，两小时后截止揭示
+func (s *testStub) openAuction() string {
+	s.t.Helper()
+	bidDeadline := s.now.Add(time.Hour).Format(time.RFC3339)
+	revealDeadline := s.now.Add(2 * time.Hour).Format(time.RFC3339)
+	return string(s.asUser(testMspId, "alice").mustInvoke("auctionOpen", "alice", "a1", "400", bidDeadline, revealDeadline))
+}
+
+func (s *testStub) bid(auctionId, bidderId string, amount int64) {
+	s.t.Helper()
+	s.asUser(testMspId, bidderId).mustInvoke("auctionBid", auctionId, bidderId, bidHash(auctionId, bidderId, amount, testSalt))
+}
+
+func (s *testStub) reveal(auctionId, bidderId string, amount int64) {
+	s.t.Helper()
+	s.asUser(testMspId, bidderId).mustInvoke("auctionReveal", auctionId, bidderId, strconv.FormatInt(amount, 10), testSalt)
+}
+
+func (s *testStub) getAuction(auctionId string) *Auction {
+	s.t.Helper()
+	auction := new(Auction)
+	if err := json.Unmarshal(s.mustInvoke("queryAuction", auctionId), auction); err != nil {
+		s.t.Fatal(err)
+	}
+
+	return auction
+}
+
+func TestAuctionBidChecks(t *testing.T) {
+	s := newAuctionStub(t)
+	s.asUser(testMspId, "erin").mustInvoke("userRegister", "Erin", "erin")
+	auctionId := s.openAuction()
+
+	hash := bidHash(auctionId, "bob", 500, testSalt)
+	tests := []struct {
+		name    string
+		caller  string
+		bidder  string
+		hash    string
+		wantErr string
+	}{
+		{"seller", "alice", "alice", hash, "seller cannot bid"},
+		{"other user", "carol", "bob", hash, "permission denied"},
+		{"kyc pending", "erin", "erin", hash, "kyc status is pending"},
+		{"invalid hash", "bob", "bob", "500", "invalid bid hash"},
+		{"unknown bidder", "bob", "nobody", hash, "user not found"},
+	}
+	for _, tt := range tests {
+		msg := s.asUser(testMspId, tt.caller).mustFail("auctionBid", auctionId, tt.bidder, tt.hash)
+		if !strings.Contains(msg, tt.wantErr) {
+			t.Errorf("%s: got %q, want %q", tt.name, msg, tt.wantErr)
+		}
+	}
+
+	s.asRegulator().mustInvoke("userFreeze", "dave", "aml")
+	resp := s.asUser(testMspId, "dave").invoke("auctionBid", auctionId, "dave", hash)
+	if resp.Status != statusFrozen {
+		t.Fatalf("frozen bidder: got %d %s", resp.Status, resp.Message)
+	}
+
+	// 揭示期之前不能揭示，截止后不能再出价
+	s.bid(auctionId, "bob", 500)
+	s.asUser(testMspId, "bob").mustFail("auctionReveal", auctionId, "bob", "500", testSalt)
+	s.now = s.now.Add(time.Hour)
+	s.asUser(testMspId, "carol").mustFail("auctionBid", auctionId, "carol", hash)
+
+	// 盐值太短、金额不符都不能揭示
+	msg := s.asUser(testMspId, "bob").mustFail("auctionReveal", auctionId, "bob", "500", "abcd")
+	if !strings.Contains(msg, "invalid bid salt") {
+		t.Fatalf("short salt: got %q", msg)
+	}
+	msg = s.asUser(testMspId, "bob").mustFail("auctionReveal", auctionId, "bob", "600", testSalt)
+	if !strings.Contains(msg, "bid hash not match") {
+		t.Fatalf("wrong amount: got %q", msg)
+	}
+	s.reveal(auctionId, "bob", 500)
+	if balance := s.balance("bob"); balance != 500 {
+		t.Fatalf("bob balance %d after escrow, want 500", balance)
+	}
+	s.asUser(testMspId, "bob").mustFail("auctionReveal", auctionId, "bob", "500", testSalt)
+}
+
+func TestAuctionLocksAsset(t *testing.T) {
+	s := newAuctionStub(t)
+	auctionId := s.openAuction()
+
+	later := s.now.Add(time.Hour).Format(time.RFC3339)
+	for _, call := range [][]string{
+		{"assetExchange", "alice", "a1", "bob"},
+		{"transferOffer", "alice", "a1", "bob", later},
+		{"listingCreate", "alice", "a1", "100", "", later},
+		{"auctionOpen", "alice", "a1", "400", later, s.now.Add(2 * time.Hour).Format(time.RFC3339)},
+	} {
+		msg := s.asUser(testMspId, "alice").mustFail(call[0], call[1:]...)
+		if !strings.Contains(msg, "under auction "+auctionId) {
+			t.Errorf("%s: got %q", call[0], msg)
+		}
+	}
+
+	// 取消后解除锁定
+	s.asUser(testMspId, "alice").mustInvoke("auctionCancel", auctionId)
+	s.asUser(testMspId, "alice").mustInvoke("assetExchange", "alice", "a1", "bob")
+}
+
+func TestAuctionSettleSkipsUnsettleableBid(t *testing.T) {
+	s := newAuctionStub(t)
+	auctionId := s.openAuction()
+
+	s.bid(auctionId, "bob", 500)
+	s.bid(auctionId, "carol", 700)
+	s.bid(auctionId, "dave", 300)
+	s.now = s.now.Add(time.Hour)
+	s.reveal(auctionId, "bob", 500)
+	s.reveal(auctionId, "carol", 700)
+	s.reveal(auctionId, "dave", 300)
+
+	// 最高出价者在结算前被冻结，由次高出价者得标
+	s.asRegulator().mustInvoke("userFreeze", "carol", "aml")
+	s.asUser(testMspId, "bob").mustFail("auctionSettle", auctionId)
+	s.now = s.now.Add(time.Hour)
+	s.asUser(testMspId, "bob").mustInvoke("auctionSettle", auctionId)
+
+	auction := s.getAuction(auctionId)
+	if auction.State != auctionStateSettled || auction.WinnerId != "bob" || auction.WinningBid != 500 {
+		t.Fatalf("auction %s won by %s at %d", auction.State, auction.WinnerId, auction.WinningBid)
+	}
+	for _, bid := range auction.Bids {
+		if bid.Escrowed {
+			t.Errorf("bid of %s still escrowed", bid.BidderId)
+		}
+	}
+	if owner := s.getAsset("a1").Owner; owner != "bob" {
+		t.Fatalf("asset owner %s, want bob", owner)
+	}
+	balances := map[string]int64{"alice": 500, "bob": 500, "carol": 1000, "dave": 1000}
+	for id, want := range balances {
+		if got := s.balance(id); got != want {
+			t.Errorf("%s balance %d, want %d", id, got, want)
+		}
+	}
+	histories := s.assetHistory("a1")
+	last := histories[len(histories)-1]
+	if last.CurrentOwnerId != "bob" || last.Price != 500 || last.AuctionId != auctionId {
+		t.Fatalf("unexpected history %+v", last)
+	}
+	evt := s.lastEvent()
+	if evt.Type != eventAssetSold || evt.To != "bob" || evt.Amount != 500 || evt.RefId != auctionId {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+
+	// 结算后解除锁定，不能重复结算
+	s.asUser(testMspId, "bob").mustFail("auctionSettle", auctionId)
+	s.asUser(testMspId, "bob").mustInvoke("assetExchange", "bob", "a1", "dave")
+}
+
+func TestAuctionFailsWithoutSettleableBid(t *testing.T) {
+	s := newAuctionStub(t)
+	auctionId := s.openAuction()
+
+	s.bid(auctionId, "bob", 500)
+	s.bid(auctionId, "carol", 300)
+	s.now = s.now.Add(time.Hour)
+	s.reveal(auctionId, "bob", 500)
+	s.reveal(auctionId, "carol", 300)
+	// bob 在揭示后 KYC 被暂停，carol 低于保留价
+	s.asAdmin().mustInvoke("updateUserProfile", "bob", "", kycSuspended, "", "")
+	s.now = s.now.Add(time.Hour)
+	s.asUser(testMspId, "carol").mustInvoke("auctionSettle", auctionId)
+
+	auction := s.getAuction(auctionId)
+	if auction.State != auctionStateFailed || auction.WinnerId != "" {
+		t.Fatalf("auction %s won by %s", auction.State, auction.WinnerId)
+	}
+	if owner := s.getAsset("a1").Owner; owner != "alice" {
+		t.Fatalf("asset owner %s, want alice", owner)
+	}
+	for _, id := range []string{"bob", "carol"} {
+		if got := s.balance(id); got != 1000 {
+			t.Errorf("%s balance %d, want 1000", id, got)
+		}
+	}
+	if evt := s.lastEvent(); evt.Type != eventAuctionFailed {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+	s.asUser(testMspId, "alice").mustInvoke("assetExchange", "alice", "a1", "dave")
+}
+
+func TestUserDestroyWithOpenBids(t *testing.T) {
+	s := newAuctionStub(t)
+	auctionId := s.openAuction()
+
+	s.bid(auctionId, "bob", 500)
+	s.bid(auctionId, "carol", 700)
+	s.now = s.now.Add(time.Hour)
+	s.reveal(auctionId, "bob", 500)
+
+	// 冻结的出价结束时要退回，拍卖结束前竞买人不能销户
+	for _, id := range []string{"bob", "carol"} {
+		msg := s.asUser(testMspId, id).mustFail("userDestroy", id, "dave")
+		if !strings.Contains(msg, "open bids in auctions ["+auctionId+"]") {
+			t.Fatalf("unexpected error: %s", msg)
+		}
+	}
+	s.asUser(testMspId, "dave").mustInvoke("userDestroy", "dave", "carol")
+
+	s.asAdmin().mustInvoke("auctionCancel", auctionId)
+	if got := s.balance("bob"); got != 1000 {
+		t.Fatalf("bob balance %d, want 1000", got)
+	}
+	s.asUser(testMspId, "bob").mustInvoke("userDestroy", "bob", "carol")
+	s.asUser(testMspId, "carol").mustInvoke("userDestroy", "carol", "alice")
+	if got := s.balance("alice"); got != 3000 {
+		t.Fatalf("alice balance %d, want 3000", got)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+// 资产证明文件的存证：借款合同、法院判决、评估报告等文件存放在链下，链上只记录文件的引用
+// 引用包括文件的 SHA-256、媒体类型、大小、存储地址和上传者，组合键 document~资产id~哈希
+// 同一文件对同一资产只能存证一次，记录只追加不修改；资产转让后原有的存证仍然保留
+// verifyAssetDocument 校验给定的哈希是否已在该资产上存证，用于核对持有的文件是否被篡改
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const documentObjectType = "document"
+
+// AssetDocument 资产证明文件的引用
+type AssetDocument struct {
+	AssetId         string    `json:"asset_id"`
+	Hash            string    `json:"hash"` // 文件内容的 SHA-256，十六进制小写
+	Name            string    `json:"name,omitempty"`
+	MediaType       string    `json:"media_type"` // 如 application/pdf
+	Size            int64     `json:"size"`       // 字节数
+	Uri             string    `json:"uri"`        // 链下存储地址
+	UploaderId      string    `json:"uploader_id"`
+	UploaderMspId   string    `json:"uploader_msp_id"`
+	UploaderSubject string    `json:"uploader_subject"`
+	TxId            string    `json:"tx_id"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// DocumentVerification 文件哈希的校验结果
+type DocumentVerification struct {
+	AssetId  string         `json:"asset_id"`
+	Hash     string         `json:"hash"`
+	Verified bool           `json:"verified"`
+	Document *AssetDocument `json:"document,omitempty"`
+}
+
+func constructDocumentKey(stub shim.ChaincodeStubInterface, assetId, hash string) (string, error) {
+	key, err := stub.CreateCompositeKey(documentObjectType, []string{assetId, hash})
+	if err != nil {
+		return "", fmt.Errorf("create key error: %s", err)
+	}
+
+	return key, nil
+}
+
+// 校验并规范化文件哈希，统一为小写
+func parseDocumentHash(hash string) (string, error) {
+	hash = strings.ToLower(hash)
+	if hashBytes, err := hex.DecodeString(hash); err != nil || len(hashBytes) != sha256.Size {
+		return "", fmt.Errorf("invalid document hash, expect hex encoded sha256")
+	}
+
+	return hash, nil
+}
+
+func getAssetDocument(stub shim.ChaincodeStubInterface, assetId, hash string) (*AssetDocument, error) {
+	key, err := constructDocumentKey(stub, assetId, hash)
+	if err != nil {
+		return nil, err
+	}
+	docBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("get document error: %s", err)
+	}
+	if len(docBytes) == 0 {
+		return nil, nil
+	}
+
+	doc := new(AssetDocument)
+	if err := json.Unmarshal(docBytes, doc); err != nil {
+		return nil, fmt.Errorf("unmarshal document error: %s", err)
+	}
+
+	return doc, nil
+}
+
+// 资产拥有者为资产附加证明文件的引用
+func assetAttachDocument(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：拥有者 id、资产 id、文件哈希、文件名、媒体类型、大小、存储地址
+	if len(args) != 7 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	ownerId := args[0]
+	assetId := args[1]
+	name := args[3]
+	mediaType := args[4]
+	uri := args[6]
+	if ownerId == "" || assetId == "" || uri == "" {
+		return shim.Error("invalid args")
+	}
+	hash, err := parseDocumentHash(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !strings.Contains(mediaType, "/") {
+		return shim.Error(fmt.Sprintf("invalid media type: %s", mediaType))
+	}
+	size, err := strconv.ParseInt(args[5], 10, 64)
+	if err != nil || size <= 0 {
+		return shim.Error(fmt.Sprintf("invalid size: %s", args[5]))
+	}
+
+	// 3：验证数据是否存在
+	// 只有资产拥有者本人或管理员可以存证
+	if _, err := getUserWithAccess(stub, ownerId); err != nil {
+		return shim.Error(err.Error())
+	}
+	asset, err := getAsset(stub, assetId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if asset.Owner != ownerId {
+		return shim.Error("asset owner not match")
+	}
+	existing, err := getAssetDocument(stub, assetId, hash)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if existing != nil {
+		return shim.Error(fmt.Sprintf("document %s already anchored on asset %s", hash, assetId))
+	}
+	mspId, subject, err := getCallerIdentity(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 4： 状态写入
+	docBytes, err := json.Marshal(&AssetDocument{
+		AssetId:         assetId,
+		Hash:            hash,
+		Name:            name,
+		MediaType:       mediaType,
+		Size:            size,
+		Uri:             uri,
+		UploaderId:      ownerId,
+		UploaderMspId:   mspId,
+		UploaderSubject: subject,
+		TxId:            stub.GetTxID(),
+		Timestamp:       now,
+	})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal document error: %s", err))
+	}
+	key, err := constructDocumentKey(stub, assetId, hash)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := stub.PutState(key, docBytes); err != nil {
+		return shim.Error(fmt.Sprintf("save document error: %s", err))
+	}
+
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:    eventDocumentAnchored,
+		AssetId: assetId,
+		From:    ownerId,
+		RefId:   hash,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(docBytes)
+}
+
+// 资产的证明文件列表
+func queryAssetDocuments(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	assetId := args[0]
+	if assetId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	if _, err := getAsset(stub, assetId); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	result, err := stub.GetStateByPartialCompositeKey(documentObjectType, []string{assetId})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("query documents error: %s", err))
+	}
+	defer result.Close()
+
+	docs := make([]*AssetDocument, 0)
+	for result.HasNext() {
+		docVal, err := result.Next()
+		if err != nil {
+			return shim.Error(fmt.Sprintf("query error: %s", err))
+		}
+
+		doc := new(AssetDocument)
+		if err := json.Unmarshal(docVal.GetValue(), doc); err != nil {
+			return shim.Error(fmt.Sprintf("unmarshal error: %s", err))
+		}
+		docs = append(docs, doc)
+	}
+
+	docsBytes, err := json.Marshal(docs)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(docsBytes)
+}
+
+// 校验文件哈希是否已在资产上存证，未存证时 verified 为 false，不返回错误
+func verifyAssetDocument(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：资产 id、文件哈希
+	if len(args) != 2 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	assetId := args[0]
+	if assetId == "" {
+		return shim.Error("invalid args")
+	}
+	hash, err := parseDocumentHash(args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 3：验证数据是否存在
+	if _, err := getAsset(stub, assetId); err != nil {
+		return shim.Error(err.Error())
+	}
+	doc, err := getAssetDocument(stub, assetId, hash)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	resultBytes, err := json.Marshal(&DocumentVerification{
+		AssetId:  assetId,
+		Hash:     hash,
+		Verified: doc != nil,
+		Document: doc,
+	})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(resultBytes)
+}
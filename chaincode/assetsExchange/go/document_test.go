@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testDocHash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+func TestParseDocumentHash(t *testing.T) {
+	tests := []struct {
+		name    string
+		hash    string
+		want    string
+		wantErr bool
+	}{
+		{"lower", testDocHash, testDocHash, false},
+		{"upper", strings.ToUpper(testDocHash), testDocHash, false},
+		{"empty", "", "", true},
+		{"short", testDocHash[:62], "", true},
+		{"long", testDocHash + "00", "", true},
+		{"odd length", testDocHash[:63], "", true},
+		{"not hex", "zz" + testDocHash[2:], "", true},
+		{"prefixed", "0x" + testDocHash[2:], "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseDocumentHash(tt.hash)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got %s", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %s %v, want %s", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestAssetAttachDocument(t *testing.T) {
+	s := newTestStub(t)
+	s.defineClass()
+	s.registerUser(testMspId, "alice")
+	s.registerUser(testMspId, "bob")
+	s.enrollAsset("a1", "alice")
+
+	verify := func(hash string) *DocumentVerification {
+		result := new(DocumentVerification)
+		if err := json.Unmarshal(s.mustInvoke("verifyAssetDocument", "a1", hash), result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	if result := verify(testDocHash); result.Verified || result.Document != nil {
+		t.Fatalf("unexpected verification %+v", result)
+	}
+
+	// 非拥有者不能存证
+	msg := s.asUser(testMspId, "bob").mustFail("assetAttachDocument", "bob", "a1", testDocHash, "contract.pdf", "application/pdf", "1024", "/documents/"+testDocHash)
+	if !strings.Contains(msg, "asset owner not match") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+	msg = s.asUser(testMspId, "bob").mustFail("assetAttachDocument", "alice", "a1", testDocHash, "contract.pdf", "application/pdf", "1024", "/documents/"+testDocHash)
+	if !strings.Contains(msg, "permission denied") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+	s.asUser(testMspId, "alice").mustFail("assetAttachDocument", "alice", "a1", testDocHash, "contract.pdf", "pdf", "1024", "/documents/"+testDocHash)
+	s.asUser(testMspId, "alice").mustFail("assetAttachDocument", "alice", "a1", testDocHash, "contract.pdf", "application/pdf", "0", "/documents/"+testDocHash)
+
+	// 大写的哈希规范化为小写后存证
+	s.asUser(testMspId, "alice").mustInvoke("assetAttachDocument", "alice", "a1", strings.ToUpper(testDocHash), "contract.pdf", "application/pdf", "1024", "/documents/"+testDocHash)
+	if evt := s.lastEvent(); evt.Type != eventDocumentAnchored || evt.RefId != testDocHash {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+	msg = s.asUser(testMspId, "alice").mustFail("assetAttachDocument", "alice", "a1", testDocHash, "copy.pdf", "application/pdf", "1024", "/documents/"+testDocHash)
+	if !strings.Contains(msg, "already anchored") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+
+	// 资产转让后原有的存证仍然保留
+	s.asUser(testMspId, "alice").mustInvoke("assetExchange", "alice", "a1", "bob")
+	result := verify(strings.ToUpper(testDocHash))
+	if !result.Verified || result.Document == nil {
+		t.Fatalf("unexpected verification %+v", result)
+	}
+	doc := result.Document
+	if doc.Hash != testDocHash || doc.UploaderId != "alice" || doc.UploaderSubject != "CN=alice" || doc.Size != 1024 {
+		t.Fatalf("unexpected document %+v", doc)
+	}
+
+	var docs []*AssetDocument
+	if err := json.Unmarshal(s.mustInvoke("queryAssetDocuments", "a1"), &docs); err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].Name != "contract.pdf" {
+		t.Fatalf("unexpected documents %+v", docs)
+	}
+}
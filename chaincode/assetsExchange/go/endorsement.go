@@ -0,0 +1,133 @@
+package main
+
+// 键级背书策略（state-based endorsement）：资产键 asset_<id> 的修改需要拥有者所在组织的节点背书
+// 表示归属的键同样设置：拥有者索引 owner~拥有者id~资产id 为拥有者组织，份额 share~资产id~持有人id 为持有人组织，
+// 代币余额 balance_<用户id> 为用户组织，否则其它组织的节点可以单独改写索引、份额和余额
+// 链码级背书策略只要求任意一个节点背书，没有键级策略时 Org1 的节点单独就能改写 Org2、Org3 用户的资产
+// 登记资产时设置为拥有者组织，转让给其它组织的用户时改为受让者组织
+// 键级策略在验证修改该键的交易时生效：转让、冻结等修改资产的交易需要当前拥有者组织背书，
+// 客户端需要把交易提案发给各组织的节点
+// 拥有者没有绑定组织（管理员代开户的用户）时清除键级策略，沿用链码级背书策略
+// 本功能上线前登记的资产由管理员执行 migrateAssetEndorsement 补设，代币余额在下一次变动时设置
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/statebased"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// 设置资产键的背书策略为 mspId 组织的任一节点，mspId 为空时清除
+func setAssetEndorsement(stub shim.ChaincodeStubInterface, assetId, mspId string) error {
+	return setKeyEndorsement(stub, constructAssetKey(assetId), mspId)
+}
+
+// 设置键的背书策略为 mspId 组织的任一节点，mspId 为空时清除
+func setKeyEndorsement(stub shim.ChaincodeStubInterface, key, mspId string) error {
+	var policy []byte
+	if mspId != "" {
+		ep, err := statebased.NewStateEP(nil)
+		if err != nil {
+			return fmt.Errorf("create endorsement policy error: %s", err)
+		}
+		if err := ep.AddOrgs(statebased.RoleTypePeer, mspId); err != nil {
+			return fmt.Errorf("add endorsement org error: %s", err)
+		}
+		if policy, err = ep.Policy(); err != nil {
+			return fmt.Errorf("marshal endorsement policy error: %s", err)
+		}
+	}
+
+	if err := stub.SetStateValidationParameter(key, policy); err != nil {
+		return fmt.Errorf("set endorsement policy error: %s", err)
+	}
+
+	return nil
+}
+
+// 一次性迁移：为已有资产的资产键、拥有者索引和份额设置拥有者（持有人）组织的背书策略
+// 参数为资产 id 列表，不传时迁移全部资产。资产很多时可分批传入，避免单个交易的读写集过大
+// 资产尚无键级策略时，迁移交易只需满足链码级背书策略
+func migrateAssetEndorsement(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if !isAdmin(stub) {
+		return shim.Error("permission denied: admin only")
+	}
+
+	assetIds := args
+	if len(assetIds) == 0 {
+		result, err := stub.GetStateByRange(constructAssetKey(""), assetKeyRangeEnd)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("query assets error: %s", err))
+		}
+		defer result.Close()
+
+		for result.HasNext() {
+			assetVal, err := result.Next()
+			if err != nil {
+				return shim.Error(fmt.Sprintf("query error: %s", err))
+			}
+			assetIds = append(assetIds, assetVal.GetKey()[len(constructAssetKey("")):])
+		}
+	}
+
+	// 同一交易内同一用户只读取一次
+	ownerMspIds := make(map[string]string)
+	getMspId := func(userId string) (string, error) {
+		mspId, ok := ownerMspIds[userId]
+		if !ok {
+			owner, err := getUser(stub, userId)
+			if err != nil {
+				return "", err
+			}
+			mspId = owner.MspId
+			ownerMspIds[userId] = mspId
+		}
+		return mspId, nil
+	}
+	for _, assetId := range assetIds {
+		asset, err := getAsset(stub, assetId)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s: %s", assetId, err))
+		}
+		mspId, err := getMspId(asset.Owner)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s: %s", assetId, err))
+		}
+		if err := setAssetEndorsement(stub, assetId, mspId); err != nil {
+			return shim.Error(err.Error())
+		}
+
+		// 拥有者索引和份额，份额化资产每个持有人一个索引
+		holders := []*AssetShare{{HolderId: asset.Owner}}
+		if asset.Units != 0 {
+			if holders, err = getAssetShares(stub, assetId); err != nil {
+				return shim.Error(err.Error())
+			}
+		}
+		for _, holder := range holders {
+			holderMspId, err := getMspId(holder.HolderId)
+			if err != nil {
+				return shim.Error(fmt.Sprintf("%s: %s", assetId, err))
+			}
+			indexKey, err := stub.CreateCompositeKey(ownerIndex, []string{holder.HolderId, assetId})
+			if err != nil {
+				return shim.Error(fmt.Sprintf("create key error: %s", err))
+			}
+			if err := setKeyEndorsement(stub, indexKey, holderMspId); err != nil {
+				return shim.Error(err.Error())
+			}
+			if asset.Units != 0 {
+				shareKey, err := constructShareKey(stub, assetId, holder.HolderId)
+				if err != nil {
+					return shim.Error(err.Error())
+				}
+				if err := setKeyEndorsement(stub, shareKey, holderMspId); err != nil {
+					return shim.Error(err.Error())
+				}
+			}
+		}
+	}
+
+	return shim.Success([]byte(fmt.Sprintf("%d assets migrated", len(assetIds))))
+}
@@ -0,0 +1,144 @@
+package main
+
+// 链码事件：状态变更成功后发出，下游系统订阅事件即可，不需要轮询账本
+// Fabric 每个交易只保留最后一次 SetEvent，因此每个方法只发出一个事件
+//
+// 事件名即 Type，负载为 JSON：
+//   UserRegistered    {"type","user_id","tx_id","timestamp"}
+//   UserDestroyed     {"type","user_id","to","asset_ids","amount","tx_id","timestamp"}
+//                     to 为承接人，asset_ids 为转给承接人的资产，amount 为转给承接人的代币余额
+//   AssetEnrolled     {"type","asset_id","to","amount","tx_id","timestamp"}      to 为登记的拥有者，份额化资产 amount 为份额总数
+//   AssetsBundled / AssetsUnbundled
+//                     {"type","asset_id","from","asset_ids","tx_id","timestamp"}  asset_id 为资产池，from 为拥有者，asset_ids 为池中的资产
+//   UnitsTransferred  {"type","asset_id","from","to","amount","tx_id","timestamp"}  amount 为转让的份额数
+//   AssetTransferred  {"type","asset_id","from","to","ref_id","tx_id","timestamp"}   接受转让要约时 ref_id 为要约 id，多签转让时为待签转让 id
+//   AssetSwapped      {"type","asset_id","from","to","counter_asset_id","tx_id","timestamp"}
+//                     asset_id 从 from 转给 to，counter_asset_id 从 to 转给 from
+//   TransferOffered / TransferRejected / TransferCancelled
+//                     {"type","asset_id","from","to","ref_id","tx_id","timestamp"}  ref_id 为要约 id
+//   TransferPending / TransferApproved / TransferDeclined / MultisigRejected / TransferExpired
+//                     {"type","asset_id","from","to","ref_id","tx_id","timestamp"}  ref_id 为待签转让 id
+//                     签署人同意或拒绝时发出 TransferApproved / TransferDeclined，拒绝后门限不可能达到时发出 MultisigRejected
+//                     同意数达到门限时资产转让，发出 AssetTransferred，ref_id 为待签转让 id
+//                     multisigExpire 把过期的待签转让写入账本时发出 TransferExpired
+//   UserSignersChanged {"type","user_id","amount","tx_id","timestamp"}  amount 为新的门限，0 表示取消多签
+//   SwapProposed / SwapRejected / SwapCancelled
+//                     {"type","asset_id","from","to","counter_asset_id","ref_id","tx_id","timestamp"}  ref_id 为互换 id
+//   AssetFrozen / AssetUnfrozen  {"type","asset_id","from","reason","tx_id","timestamp"}  from 为资产拥有者，reason 为冻结原因代码
+//   UserFrozen / UserUnfrozen    {"type","user_id","reason","tx_id","timestamp"}
+//   AssetSold         {"type","asset_id","from","to","amount","ref_id","tx_id","timestamp"}
+//                     按要约价格购买资产，amount 为成交价格，ref_id 为要约 id
+//                     挂牌购买时 ref_id 为挂牌 id，拍卖成交时 ref_id 为拍卖 id
+//   ListingCreated / ListingUpdated / ListingCancelled
+//                     {"type","asset_id","from","amount","ref_id","tx_id","timestamp"}  from 为卖方，amount 为要价，ref_id 为挂牌 id
+//   AuctionOpened / AuctionCancelled / AuctionFailed
+//                     {"type","asset_id","from","amount","ref_id","tx_id","timestamp"}  from 为拥有者，ref_id 为拍卖 id
+//                     发起时 amount 为保留价，AuctionFailed 为没有有效出价而流拍
+//   BidSubmitted / BidRevealed
+//                     {"type","asset_id","from","to","amount","ref_id","tx_id","timestamp"}  to 为竞买人，ref_id 为拍卖 id
+//                     BidSubmitted 没有 amount，BidRevealed 的 amount 为公开的出价
+//   TokenMinted       {"type","to","amount","tx_id","timestamp"}
+//   TokenTransferred  {"type","from","to","amount","tx_id","timestamp"}
+//   LienRegistered / LienAccepted / LienReleased / LienApproved
+//                     {"type","asset_id","from","to","ref_id","tx_id","timestamp"}  from 为质权人，ref_id 为质权 id
+//                     登记和质权人确认时 to 为资产拥有者，同意转让时 to 为同意的受让者
+//   UserProfileUpdated {"type","user_id","reason","tx_id","timestamp"}  reason 为修改后的 KYC 状态
+//   AssetUpdated      {"type","asset_id","from","reason","tx_id","timestamp"}  from 为资产拥有者，reason 为修改原因
+//   DocumentAnchored  {"type","asset_id","from","ref_id","tx_id","timestamp"}  from 为上传者，ref_id 为文件的 SHA-256
+//   RoleGranted / RoleRevoked
+//                     {"type","user_id","reason","tx_id","timestamp"}  user_id 为 MSPID/Subject，reason 为角色
+//   AclChanged        {"type","ref_id","reason","tx_id","timestamp"}  ref_id 为方法名，reason 为允许的角色列表
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// 事件类型
+const (
+	eventUserRegistered     = "UserRegistered"
+	eventUserDestroyed      = "UserDestroyed"
+	eventAssetEnrolled      = "AssetEnrolled"
+	eventAssetTransferred   = "AssetTransferred"
+	eventAssetSwapped       = "AssetSwapped"
+	eventUnitsTransferred   = "UnitsTransferred"
+	eventAssetsBundled      = "AssetsBundled"
+	eventAssetsUnbundled    = "AssetsUnbundled"
+	eventTransferOffered    = "TransferOffered"
+	eventTransferRejected   = "TransferRejected"
+	eventTransferCancelled  = "TransferCancelled"
+	eventTransferPending    = "TransferPending"
+	eventTransferApproved   = "TransferApproved"
+	eventTransferDeclined   = "TransferDeclined"
+	eventMultisigRejected   = "MultisigRejected"
+	eventTransferExpired    = "TransferExpired"
+	eventUserSignersChanged = "UserSignersChanged"
+	eventUserProfileUpdated = "UserProfileUpdated"
+	eventSwapProposed       = "SwapProposed"
+	eventSwapRejected       = "SwapRejected"
+	eventSwapCancelled      = "SwapCancelled"
+	eventAssetFrozen        = "AssetFrozen"
+	eventAssetUnfrozen      = "AssetUnfrozen"
+	eventUserFrozen         = "UserFrozen"
+	eventUserUnfrozen       = "UserUnfrozen"
+	eventAssetSold          = "AssetSold"
+	eventListingCreated     = "ListingCreated"
+	eventListingUpdated     = "ListingUpdated"
+	eventListingCancelled   = "ListingCancelled"
+	eventAuctionOpened      = "AuctionOpened"
+	eventAuctionCancelled   = "AuctionCancelled"
+	eventAuctionFailed      = "AuctionFailed"
+	eventBidSubmitted       = "BidSubmitted"
+	eventBidRevealed        = "BidRevealed"
+	eventTokenMinted        = "TokenMinted"
+	eventTokenTransferred   = "TokenTransferred"
+	eventLienRegistered     = "LienRegistered"
+	eventLienAccepted       = "LienAccepted"
+	eventLienReleased       = "LienReleased"
+	eventLienApproved       = "LienApproved"
+	eventAssetUpdated       = "AssetUpdated"
+	eventDocumentAnchored   = "DocumentAnchored"
+	eventRoleGranted        = "RoleGranted"
+	eventRoleRevoked        = "RoleRevoked"
+	eventAclChanged         = "AclChanged"
+)
+
+// ChaincodeEvent 链码事件的负载，不同类型的事件只填写相关的字段
+type ChaincodeEvent struct {
+	Type           string    `json:"type"`
+	UserId         string    `json:"user_id,omitempty"`
+	AssetId        string    `json:"asset_id,omitempty"`
+	From           string    `json:"from,omitempty"`
+	To             string    `json:"to,omitempty"`
+	CounterAssetId string    `json:"counter_asset_id,omitempty"`
+	AssetIds       []string  `json:"asset_ids,omitempty"`
+	RefId          string    `json:"ref_id,omitempty"` // 关联的要约、互换等业务对象 id
+	Reason         string    `json:"reason,omitempty"` // 冻结原因代码
+	Amount         int64     `json:"amount,omitempty"` // 代币数量、成交价格或份额数
+	TxId           string    `json:"tx_id"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// 发出链码事件，交易 id 和时间戳在这里填充
+func emitEvent(stub shim.ChaincodeStubInterface, evt *ChaincodeEvent) error {
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+
+	evt.TxId = stub.GetTxID()
+	evt.Timestamp = txTime
+
+	evtBytes, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("marshal event error: %s", err)
+	}
+	if err := stub.SetEvent(evt.Type, evtBytes); err != nil {
+		return fmt.Errorf("set event error: %s", err)
+	}
+
+	return nil
+}
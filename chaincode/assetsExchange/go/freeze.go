@@ -0,0 +1,380 @@
+package main
+
+// 资产冻结（监管保全）：监管方可以冻结单项资产或整个用户，冻结期间资产不能转让、互换，用户不能销户
+// 冻结可以设置到期时间，到期后自动失效，不需要再提交解冻交易
+// 资产的冻结和解冻写入资产变更记录；用户的冻结和解冻写入用户资料变更记录，可通过 queryUserProfileHistory 查询
+// 解冻记录中保留被解除的冻结信息，冻结时的备注不变，解冻的备注、时间和操作者另外记录
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	// 资产或用户被冻结时链码返回的状态码，与 HTTP 423 Locked 相同，REST 层据此返回 423
+	statusFrozen = 423
+)
+
+// 冻结原因代码
+var freezeReasons = map[string]bool{
+	"court_order":   true, // 司法查封
+	"aml":           true, // 反洗钱调查
+	"sanctions":     true, // 制裁名单
+	"dispute":       true, // 权属争议
+	"investigation": true, // 监管调查
+	"other":         true, // 其它，需在备注中说明
+}
+
+// Freeze 冻结信息
+type Freeze struct {
+	Reason        string     `json:"reason"`           // 原因代码
+	Note          string     `json:"note,omitempty"`   // 备注
+	Expiry        *time.Time `json:"expiry,omitempty"` // 到期时间，为空表示直到解冻
+	FrozenAt      time.Time  `json:"frozen_at"`
+	FrozenByMspId string     `json:"frozen_by_msp_id"`
+	FrozenBy      string     `json:"frozen_by"` // 操作者证书的 Subject
+	TxId          string     `json:"tx_id"`
+	// 解冻信息，只在解冻记录中填写
+	UnfreezeNote    string     `json:"unfreeze_note,omitempty"`
+	UnfrozenAt      *time.Time `json:"unfrozen_at,omitempty"`
+	UnfrozenByMspId string     `json:"unfrozen_by_msp_id,omitempty"`
+	UnfrozenBy      string     `json:"unfrozen_by,omitempty"`
+}
+
+// 在 now 时刻冻结是否有效
+func (f *Freeze) active(now time.Time) bool {
+	return f != nil && (f.Expiry == nil || now.Before(*f.Expiry))
+}
+
+// 冻结导致的错误，errorResponse 把它转换为 423 状态码
+type frozenError struct {
+	msg string
+}
+
+func (e *frozenError) Error() string {
+	return e.msg
+}
+
+// 把错误转换为链码响应，冻结错误使用 statusFrozen，其它错误与 shim.Error 相同
+func errorResponse(err error) pb.Response {
+	if _, ok := err.(*frozenError); ok {
+		return pb.Response{
+			Status:  statusFrozen,
+			Message: err.Error(),
+		}
+	}
+
+	return shim.Error(err.Error())
+}
+
+// 校验资产和拥有者都没有被冻结
+func checkNotFrozen(stub shim.ChaincodeStubInterface, asset *Asset, owner *User) error {
+	now, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+	if asset.Freeze.active(now) {
+		return &frozenError{fmt.Sprintf("asset %s frozen: %s", asset.Id, asset.Freeze.Reason)}
+	}
+
+	return checkUserNotFrozen(stub, owner)
+}
+
+// 校验用户没有被冻结，被冻结的用户不能转出资产和代币
+func checkUserNotFrozen(stub shim.ChaincodeStubInterface, user *User) error {
+	now, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+	if user.Freeze.active(now) {
+		return &frozenError{fmt.Sprintf("user %s frozen: %s", user.Id, user.Freeze.Reason)}
+	}
+
+	return nil
+}
+
+// 解析冻结参数：原因代码、到期时间（可选，RFC3339）、备注（可选）
+func newFreeze(stub shim.ChaincodeStubInterface, args []string) (*Freeze, error) {
+	reason := args[0]
+	if !freezeReasons[reason] {
+		return nil, fmt.Errorf("unknown freeze reason: %s", reason)
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+	mspId, subject, err := getCallerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	freeze := &Freeze{
+		Reason:        reason,
+		FrozenAt:      now,
+		FrozenByMspId: mspId,
+		FrozenBy:      subject,
+		TxId:          stub.GetTxID(),
+	}
+	if len(args) >= 2 && args[1] != "" {
+		expiry, err := time.Parse(time.RFC3339, args[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid expiry: %s", err)
+		}
+		if !expiry.After(now) {
+			return nil, fmt.Errorf("expiry must be in the future")
+		}
+		expiry = expiry.UTC()
+		freeze.Expiry = &expiry
+	}
+	if len(args) == 3 {
+		freeze.Note = args[2]
+	}
+	if reason == "other" && freeze.Note == "" {
+		return nil, fmt.Errorf("note is required for reason other")
+	}
+
+	return freeze, nil
+}
+
+// 解冻时在冻结信息上记录解冻的备注、时间和操作者，返回的冻结信息写入解冻记录
+func unfreezeRecord(stub shim.ChaincodeStubInterface, freeze *Freeze, note string) (*Freeze, error) {
+	now, err := getTxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+	mspId, subject, err := getCallerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	record := *freeze
+	record.UnfreezeNote = note
+	record.UnfrozenAt = &now
+	record.UnfrozenByMspId = mspId
+	record.UnfrozenBy = subject
+
+	return &record, nil
+}
+
+// 资产冻结，只有监管方可以操作
+func assetFreeze(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：资产 id、原因代码、到期时间（可选）、备注（可选）
+	if len(args) < 2 || len(args) > 4 {
+		return shim.Error("not enough args")
+	}
+	if !isRegulator(stub) {
+		return shim.Error("permission denied: regulator only")
+	}
+
+	// 2：验证参数的正确性
+	assetId := args[0]
+	if assetId == "" {
+		return shim.Error("invalid args")
+	}
+	freeze, err := newFreeze(stub, args[1:])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 3：验证数据是否存在
+	asset, err := getAsset(stub, assetId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if asset.Freeze.active(freeze.FrozenAt) {
+		return shim.Error(fmt.Sprintf("asset %s already frozen", assetId))
+	}
+
+	// 4： 状态写入
+	asset.Freeze = freeze
+	if err := putAsset(stub, asset); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putAssetHistory(stub, &AssetHistory{
+		AssetId:        assetId,
+		OriginOwnerId:  asset.Owner,
+		CurrentOwnerId: asset.Owner,
+		Action:         historyFreeze,
+		Freeze:         freeze,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:    eventAssetFrozen,
+		AssetId: assetId,
+		From:    asset.Owner,
+		Reason:  freeze.Reason,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 资产解冻，只有监管方可以操作，已经到期的冻结也可以解冻以清除冻结信息
+func assetUnfreeze(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：资产 id、备注（可选）
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error("not enough args")
+	}
+	if !isRegulator(stub) {
+		return shim.Error("permission denied: regulator only")
+	}
+
+	// 2：验证参数的正确性
+	assetId := args[0]
+	if assetId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	asset, err := getAsset(stub, assetId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if asset.Freeze == nil {
+		return shim.Error(fmt.Sprintf("asset %s not frozen", assetId))
+	}
+
+	note := ""
+	if len(args) == 2 {
+		note = args[1]
+	}
+	freeze, err := unfreezeRecord(stub, asset.Freeze, note)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 4： 状态写入，变更记录中保留被解除的冻结信息
+	asset.Freeze = nil
+	if err := putAsset(stub, asset); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putAssetHistory(stub, &AssetHistory{
+		AssetId:        assetId,
+		OriginOwnerId:  asset.Owner,
+		CurrentOwnerId: asset.Owner,
+		Action:         historyUnfreeze,
+		Freeze:         freeze,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:    eventAssetUnfrozen,
+		AssetId: assetId,
+		From:    asset.Owner,
+		Reason:  freeze.Reason,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 用户冻结，名下所有资产和代币都不能转出，用户不能销户，只有监管方可以操作
+func userFreeze(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：用户 id、原因代码、到期时间（可选）、备注（可选）
+	if len(args) < 2 || len(args) > 4 {
+		return shim.Error("not enough args")
+	}
+	if !isRegulator(stub) {
+		return shim.Error("permission denied: regulator only")
+	}
+
+	// 2：验证参数的正确性
+	userId := args[0]
+	if userId == "" {
+		return shim.Error("invalid args")
+	}
+	freeze, err := newFreeze(stub, args[1:])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 3：验证数据是否存在
+	user, err := getActiveUser(stub, userId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if user.Freeze.active(freeze.FrozenAt) {
+		return shim.Error(fmt.Sprintf("user %s already frozen", userId))
+	}
+
+	// 4： 状态写入
+	user.Freeze = freeze
+	if err := putUser(stub, user); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putFreezeHistory(stub, user, profileFreeze, freeze); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:   eventUserFrozen,
+		UserId: userId,
+		Reason: freeze.Reason,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 用户解冻，只有监管方可以操作
+func userUnfreeze(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：用户 id、备注（可选）
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error("not enough args")
+	}
+	if !isRegulator(stub) {
+		return shim.Error("permission denied: regulator only")
+	}
+
+	// 2：验证参数的正确性
+	userId := args[0]
+	if userId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	user, err := getUser(stub, userId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if user.Freeze == nil {
+		return shim.Error(fmt.Sprintf("user %s not frozen", userId))
+	}
+	note := ""
+	if len(args) == 2 {
+		note = args[1]
+	}
+	freeze, err := unfreezeRecord(stub, user.Freeze, note)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 4： 状态写入，变更记录中保留被解除的冻结信息
+	user.Freeze = nil
+	if err := putUser(stub, user); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putFreezeHistory(stub, user, profileUnfreeze, freeze); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:   eventUserUnfrozen,
+		UserId: userId,
+		Reason: freeze.Reason,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
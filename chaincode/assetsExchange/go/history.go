@@ -0,0 +1,160 @@
+package main
+
+// 旧版本资产变更记录的迁移
+// 旧版本的记录以组合键 history~资产id~原拥有者~现拥有者 存储，没有序号和时间，同一对拥有者之间再次转让会覆盖原记录
+// migrateAssetHistory 通过节点的历史数据库读取这些键的每个版本，按提交时间排在资产已有的 assetHistory 记录之前，
+// 重新编号后写入 assetHistory~资产id~序号，并删除旧键。迁移前 queryAssetHistory 查不到旧版本的记录
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	// 旧版本资产变更记录的组合键类型
+	legacyHistoryObjectType = "history"
+)
+
+// 读取资产的旧版本变更记录，按提交时间排序，同一个键的每个版本都是一条记录，返回记录和旧键
+func getLegacyAssetHistory(stub shim.ChaincodeStubInterface, assetId string) ([]*AssetHistory, []string, error) {
+	result, err := stub.GetStateByPartialCompositeKey(legacyHistoryObjectType, []string{assetId})
+	if err != nil {
+		return nil, nil, fmt.Errorf("query legacy history error: %s", err)
+	}
+	defer result.Close()
+
+	histories := make([]*AssetHistory, 0)
+	keys := make([]string, 0)
+	for result.HasNext() {
+		historyVal, err := result.Next()
+		if err != nil {
+			return nil, nil, fmt.Errorf("query error: %s", err)
+		}
+		keys = append(keys, historyVal.GetKey())
+
+		versions, err := getKeyHistory(stub, historyVal.GetKey())
+		if err != nil {
+			return nil, nil, err
+		}
+		// 节点未开启历史数据库时只能得到当前值，没有交易 id 和时间
+		if len(versions) == 0 {
+			versions = append(versions, &LedgerVersion{Value: historyVal.GetValue()})
+		}
+		for _, version := range versions {
+			if version.IsDelete || len(version.Value) == 0 {
+				continue
+			}
+			history := new(AssetHistory)
+			if err := json.Unmarshal(version.Value, history); err != nil {
+				return nil, nil, fmt.Errorf("unmarshal legacy history error: %s", err)
+			}
+			history.Action = history.action()
+			history.TxId = version.TxId
+			history.Timestamp = version.Timestamp
+			histories = append(histories, history)
+		}
+	}
+
+	// 登记记录排在最前，其余按提交时间排序
+	sort.SliceStable(histories, func(i, j int) bool {
+		if (histories[i].Action == historyEnroll) != (histories[j].Action == historyEnroll) {
+			return histories[i].Action == historyEnroll
+		}
+		return histories[i].Timestamp.Before(histories[j].Timestamp)
+	})
+
+	return histories, keys, nil
+}
+
+// 一次性迁移：把旧版本的资产变更记录迁移到 assetHistory，排在已有记录之前并重新编号
+// 参数为资产 id 列表，不传时迁移全部资产。资产很多时可分批传入，避免单个交易的读写集过大
+// 已经迁移过的资产没有旧记录，重复执行没有影响
+func migrateAssetHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if !isAdmin(stub) {
+		return shim.Error("permission denied: admin only")
+	}
+
+	assetIds := args
+	if len(assetIds) == 0 {
+		result, err := stub.GetStateByRange(constructAssetKey(""), assetKeyRangeEnd)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("query assets error: %s", err))
+		}
+		defer result.Close()
+
+		for result.HasNext() {
+			assetVal, err := result.Next()
+			if err != nil {
+				return shim.Error(fmt.Sprintf("query error: %s", err))
+			}
+			assetIds = append(assetIds, assetVal.GetKey()[len(constructAssetKey("")):])
+		}
+	}
+
+	migrated := 0
+	for _, assetId := range assetIds {
+		legacy, legacyKeys, err := getLegacyAssetHistory(stub, assetId)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s: %s", assetId, err))
+		}
+		if len(legacyKeys) == 0 {
+			continue
+		}
+
+		// 升级后写入的记录，按序号排在旧记录之后
+		result, err := stub.GetStateByPartialCompositeKey(historyObjectType, []string{assetId})
+		if err != nil {
+			return shim.Error(fmt.Sprintf("query history error: %s", err))
+		}
+		histories := legacy
+		for result.HasNext() {
+			historyVal, err := result.Next()
+			if err != nil {
+				result.Close()
+				return shim.Error(fmt.Sprintf("query error: %s", err))
+			}
+			history := new(AssetHistory)
+			if err := json.Unmarshal(historyVal.GetValue(), history); err != nil {
+				result.Close()
+				return shim.Error(fmt.Sprintf("unmarshal error: %s", err))
+			}
+			histories = append(histories, history)
+		}
+		result.Close()
+
+		// 重新编号写入，序号只会增加，原有记录的键都会被覆盖，不会留下空洞
+		for i, history := range histories {
+			history.Seq = uint64(i + 1)
+			historyBytes, err := json.Marshal(history)
+			if err != nil {
+				return shim.Error(fmt.Sprintf("marshal assert history error: %s", err))
+			}
+			historyKey, err := stub.CreateCompositeKey(historyObjectType, []string{
+				assetId,
+				fmt.Sprintf(historySeqFormat, history.Seq),
+			})
+			if err != nil {
+				return shim.Error(fmt.Sprintf("create key error: %s", err))
+			}
+			if err := stub.PutState(historyKey, historyBytes); err != nil {
+				return shim.Error(fmt.Sprintf("save assert history error: %s", err))
+			}
+		}
+		if err := stub.PutState(constructHistorySeqKey(assetId), []byte(strconv.Itoa(len(histories)))); err != nil {
+			return shim.Error(fmt.Sprintf("save history seq error: %s", err))
+		}
+		for _, key := range legacyKeys {
+			if err := stub.DelState(key); err != nil {
+				return shim.Error(fmt.Sprintf("delete legacy history error: %s", err))
+			}
+		}
+		migrated++
+	}
+
+	return shim.Success([]byte(fmt.Sprintf("%d assets migrated", migrated)))
+}
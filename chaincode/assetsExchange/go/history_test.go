@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+)
+
+// 写入旧版本的资产变更记录 history~资产id~原拥有者~现拥有者，每个元素为该键的一个版本
+func putLegacyHistory(t *testing.T, s *testStub, origin, current string, at ...time.Time) {
+	key, err := s.CreateCompositeKey(legacyHistoryObjectType, []string{"a1", origin, current})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := json.Marshal(map[string]string{
+		"asset_id":         "a1",
+		"origin_owner_id":  origin,
+		"current_owner_id": current,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.MockTransactionStart("legacy")
+	if err := s.PutState(key, value); err != nil {
+		t.Fatal(err)
+	}
+	s.MockTransactionEnd("legacy")
+	for _, ts := range at {
+		s.keyHistory[key] = append(s.keyHistory[key], &queryresult.KeyModification{
+			TxId:      "legacy-" + ts.Format("150405"),
+			Value:     value,
+			Timestamp: &timestamp.Timestamp{Seconds: ts.Unix()},
+		})
+	}
+}
+
+func TestMigrateAssetHistory(t *testing.T) {
+	s := newTestStub(t)
+	s.registerUser(testMspId, "alice")
+	s.registerUser(testMspId, "bob")
+
+	// 旧版本登记给 alice，之后 alice→bob、bob→alice、alice→bob，第二次 alice→bob 覆盖了第一次的键
+	t0 := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	putLegacyHistory(t, s, originOwner, "alice", t0)
+	putLegacyHistory(t, s, "alice", "bob", t0.Add(time.Hour), t0.Add(3*time.Hour))
+	putLegacyHistory(t, s, "bob", "alice", t0.Add(2*time.Hour))
+	s.MockTransactionStart("legacy")
+	if err := putAsset(s, &Asset{DocType: assetDocType, Name: "a1", Id: "a1", Owner: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	s.MockTransactionEnd("legacy")
+
+	// 升级后的转让写入新格式的记录
+	s.asUser(testMspId, "bob").mustInvoke("assetExchange", "bob", "a1", "alice")
+	if n := len(s.assetHistory("a1")); n != 1 {
+		t.Fatalf("got %d histories before migration, want 1", n)
+	}
+
+	s.asUser(testMspId, "alice").mustFail("migrateAssetHistory")
+	if msg := string(s.asAdmin().mustInvoke("migrateAssetHistory")); msg != "1 assets migrated" {
+		t.Fatalf("unexpected result: %s", msg)
+	}
+
+	histories := s.assetHistory("a1")
+	want := []struct {
+		action, from, to string
+		at               time.Time
+	}{
+		{historyEnroll, originOwner, "alice", t0},
+		{historyExchange, "alice", "bob", t0.Add(time.Hour)},
+		{historyExchange, "bob", "alice", t0.Add(2 * time.Hour)},
+		{historyExchange, "alice", "bob", t0.Add(3 * time.Hour)},
+		{historyExchange, "bob", "alice", time.Time{}},
+	}
+	if len(histories) != len(want) {
+		t.Fatalf("got %d histories, want %d", len(histories), len(want))
+	}
+	for i, w := range want {
+		h := histories[i]
+		if h.Seq != uint64(i+1) || h.Action != w.action || h.OriginOwnerId != w.from || h.CurrentOwnerId != w.to {
+			t.Fatalf("history %d: got seq %d %s %s→%s", i, h.Seq, h.Action, h.OriginOwnerId, h.CurrentOwnerId)
+		}
+		if !w.at.IsZero() && !h.Timestamp.Equal(w.at) {
+			t.Fatalf("history %d: got timestamp %s, want %s", i, h.Timestamp, w.at)
+		}
+	}
+
+	// 旧键已删除，重复执行没有影响，之后的记录接着编号
+	if msg := string(s.asAdmin().mustInvoke("migrateAssetHistory", "a1")); msg != "0 assets migrated" {
+		t.Fatalf("unexpected result: %s", msg)
+	}
+	s.asUser(testMspId, "alice").mustInvoke("assetExchange", "alice", "a1", "bob")
+	histories = s.assetHistory("a1")
+	if last := histories[len(histories)-1]; len(histories) != 6 || last.Seq != 6 {
+		t.Fatalf("got %d histories, last seq %d", len(histories), last.Seq)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+// cid 包（client identity）用于读取交易提交者的身份信息：MSP ID、证书、属性
+// https://godoc.org/github.com/hyperledger/fabric/core/chaincode/shim/ext/cid
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+)
+
+const (
+	// 证书中带有 admin=true 属性的身份视为管理员，由 fabric-ca 签发时写入
+	adminAttr = "admin"
+	// 证书中带有 regulator=true 属性的身份视为监管方，可以冻结资产和用户
+	regulatorAttr = "regulator"
+	// 证书中带有 issuer=true 属性的身份视为代币发行方，可以铸造代币
+	issuerAttr = "issuer"
+)
+
+// 获取交易提交者的身份：所属组织的 MSP ID + 证书的 Subject
+func getCallerIdentity(stub shim.ChaincodeStubInterface) (string, string, error) {
+	mspId, err := cid.GetMSPID(stub)
+	if err != nil {
+		return "", "", fmt.Errorf("get msp id error: %s", err)
+	}
+
+	cert, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		return "", "", fmt.Errorf("get certificate error: %s", err)
+	}
+	if cert == nil {
+		return "", "", fmt.Errorf("certificate not found")
+	}
+
+	return mspId, cert.Subject.String(), nil
+}
+
+// 提交者的证书是否带有 attr=true 属性
+func hasAttr(stub shim.ChaincodeStubInterface, attr string) bool {
+	return cid.AssertAttributeValue(stub, attr, "true") == nil
+}
+
+// 提交者是否持有管理员属性，或者在角色登记中持有 admin 角色
+func isAdmin(stub shim.ChaincodeStubInterface) bool {
+	return hasAttr(stub, adminAttr) || callerHasRole(stub, roleAdmin)
+}
+
+// 提交者是否持有监管方属性，或者在角色登记中持有 regulator 角色
+func isRegulator(stub shim.ChaincodeStubInterface) bool {
+	return hasAttr(stub, regulatorAttr) || callerHasRole(stub, roleRegulator)
+}
+
+// 提交者是否持有代币发行方属性，或者在角色登记中持有 issuer 角色
+func isIssuer(stub shim.ChaincodeStubInterface) bool {
+	return hasAttr(stub, issuerAttr) || callerHasRole(stub, roleIssuer)
+}
+
+// 校验提交者是该用户本人，或者持有管理员属性
+func checkUserAccess(stub shim.ChaincodeStubInterface, user *User) error {
+	if isAdmin(stub) {
+		return nil
+	}
+
+	mspId, subject, err := getCallerIdentity(stub)
+	if err != nil {
+		return err
+	}
+
+	// 开户时未绑定身份的用户，只有管理员可以操作
+	if user.MspId == "" || user.Subject == "" {
+		return fmt.Errorf("permission denied: user %s has no bound identity", user.Id)
+	}
+	if user.MspId != mspId || user.Subject != subject {
+		return fmt.Errorf("permission denied: caller is not user %s", user.Id)
+	}
+
+	return nil
+}
+
+// 读取未销户的用户并校验调用者是该用户本人或管理员
+func getUserWithAccess(stub shim.ChaincodeStubInterface, userId string) (*User, error) {
+	user, err := getActiveUser(stub, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkUserAccess(stub, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
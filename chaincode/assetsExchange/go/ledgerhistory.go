@@ -0,0 +1,103 @@
+package main
+
+// 账本历史：通过节点的历史数据库读取某个键的所有版本，包括属性修改和删除
+// 与 queryAssetHistory 不同，这里不依赖链码自己写入的变更记录，需要节点开启 core.ledger.history.enableHistoryDatabase（默认开启）
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// LedgerVersion 键的一个历史版本
+type LedgerVersion struct {
+	TxId      string          `json:"tx_id"`
+	Timestamp time.Time       `json:"timestamp"`
+	IsDelete  bool            `json:"is_delete"`
+	Value     json.RawMessage `json:"value"` // 该版本的值，删除时为 null
+}
+
+// 读取键的所有历史版本，按提交顺序从旧到新
+func getKeyHistory(stub shim.ChaincodeStubInterface, key string) ([]*LedgerVersion, error) {
+	result, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("query history error: %s", err)
+	}
+	defer result.Close()
+
+	versions := make([]*LedgerVersion, 0)
+	for result.HasNext() {
+		modification, err := result.Next()
+		if err != nil {
+			return nil, fmt.Errorf("query error: %s", err)
+		}
+
+		version := &LedgerVersion{
+			TxId:     modification.GetTxId(),
+			IsDelete: modification.GetIsDelete(),
+		}
+		if ts := modification.GetTimestamp(); ts != nil {
+			version.Timestamp = time.Unix(ts.GetSeconds(), int64(ts.GetNanos())).UTC()
+		}
+		if !version.IsDelete && len(modification.GetValue()) != 0 {
+			version.Value = json.RawMessage(modification.GetValue())
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+// 资产的账本历史查询
+func queryAssetLedgerHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	assetId := args[0]
+	if assetId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：查询数据，资产被删除后历史仍然可以查询
+	return ledgerHistoryResponse(stub, constructAssetKey(assetId))
+}
+
+// 用户的账本历史查询
+func queryUserLedgerHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	userId := args[0]
+	if userId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：查询数据，销户后历史仍然可以查询
+	return ledgerHistoryResponse(stub, constructUserKey(userId))
+}
+
+func ledgerHistoryResponse(stub shim.ChaincodeStubInterface, key string) pb.Response {
+	versions, err := getKeyHistory(stub, key)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(versions) == 0 {
+		return shim.Error("history not found")
+	}
+
+	versionsBytes, err := json.Marshal(versions)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(versionsBytes)
+}
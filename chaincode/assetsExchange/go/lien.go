@@ -0,0 +1,431 @@
+package main
+
+// 资产质押/留置：不良资产常被用作担保，质权登记后资产仍由原拥有者持有
+// 质权由资产拥有者登记，质权人通过 lienAccept 确认后才生效，未确认的质权占用顺位但不限制转让
+// 有有效质权的资产转让前，每个质权人都要通过 lienApprove 同意转给指定的受让者，同意在转让时消耗
+// 质权随资产一起转移，直到质权人解除或到期
+// 质权以组合键 lien~资产id~质权id 存储，解除后保留记录，状态改为 released
+// 担保金额与代币一样以整数的最小单位计，见 token.go
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	lienObjectType = "lien"
+
+	// 质权状态
+	lienPending  = "pending"
+	lienActive   = "active"
+	lienReleased = "released"
+)
+
+// Lien 资产上的一项质权
+type Lien struct {
+	Id         string     `json:"id"` // 登记交易的 txID
+	AssetId    string     `json:"asset_id"`
+	HolderId   string     `json:"holder_id"`             // 质权人
+	Amount     int64      `json:"amount"`                // 担保金额，代币的最小单位
+	Priority   int        `json:"priority"`              // 受偿顺位，1 为第一顺位，同一资产未解除的质权顺位不重复
+	Expiry     *time.Time `json:"expiry,omitempty"`      // 到期时间，为空表示直到解除
+	State      string     `json:"state"`                 // pending / active / released
+	ApprovedTo string     `json:"approved_to,omitempty"` // 质权人同意的受让者，转让后清空
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// 在 now 时刻质权是否未解除且未到期，包括待质权人确认的质权
+func (l *Lien) open(now time.Time) bool {
+	return (l.State == lienPending || l.State == lienActive) && (l.Expiry == nil || now.Before(*l.Expiry))
+}
+
+// 在 now 时刻质权是否有效：质权人已确认，未解除且未到期
+func (l *Lien) active(now time.Time) bool {
+	return l.State == lienActive && l.open(now)
+}
+
+func constructLienKey(stub shim.ChaincodeStubInterface, assetId, lienId string) (string, error) {
+	key, err := stub.CreateCompositeKey(lienObjectType, []string{assetId, lienId})
+	if err != nil {
+		return "", fmt.Errorf("create key error: %s", err)
+	}
+
+	return key, nil
+}
+
+func getLien(stub shim.ChaincodeStubInterface, assetId, lienId string) (*Lien, error) {
+	key, err := constructLienKey(stub, assetId, lienId)
+	if err != nil {
+		return nil, err
+	}
+	lienBytes, err := stub.GetState(key)
+	if err != nil || len(lienBytes) == 0 {
+		return nil, fmt.Errorf("lien not found")
+	}
+
+	lien := new(Lien)
+	if err := json.Unmarshal(lienBytes, lien); err != nil {
+		return nil, fmt.Errorf("unmarshal lien error: %s", err)
+	}
+
+	return lien, nil
+}
+
+func putLien(stub shim.ChaincodeStubInterface, lien *Lien) error {
+	key, err := constructLienKey(stub, lien.AssetId, lien.Id)
+	if err != nil {
+		return err
+	}
+	lienBytes, err := json.Marshal(lien)
+	if err != nil {
+		return fmt.Errorf("marshal lien error: %s", err)
+	}
+	if err := stub.PutState(key, lienBytes); err != nil {
+		return fmt.Errorf("save lien error: %s", err)
+	}
+
+	return nil
+}
+
+// 读取资产上的质权，openOnly 时只返回未解除且未到期的质权（含待确认的），按顺位排序
+func getAssetLiens(stub shim.ChaincodeStubInterface, assetId string, openOnly bool) ([]*Lien, error) {
+	now, err := getTxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := stub.GetStateByPartialCompositeKey(lienObjectType, []string{assetId})
+	if err != nil {
+		return nil, fmt.Errorf("query liens error: %s", err)
+	}
+	defer result.Close()
+
+	liens := make([]*Lien, 0)
+	for result.HasNext() {
+		lienVal, err := result.Next()
+		if err != nil {
+			return nil, fmt.Errorf("query error: %s", err)
+		}
+
+		lien := new(Lien)
+		if err := json.Unmarshal(lienVal.GetValue(), lien); err != nil {
+			return nil, fmt.Errorf("unmarshal lien error: %s", err)
+		}
+		if openOnly && !lien.open(now) {
+			continue
+		}
+		liens = append(liens, lien)
+	}
+
+	sort.SliceStable(liens, func(i, j int) bool {
+		return liens[i].Priority < liens[j].Priority
+	})
+
+	return liens, nil
+}
+
+// 校验每个有效质权的质权人都同意转给 currentOwnerId，只读取不写入，返回这些有效质权
+// 待确认的质权不限制转让
+func checkLienApprovals(stub shim.ChaincodeStubInterface, assetId, currentOwnerId string) ([]*Lien, error) {
+	liens, err := getAssetLiens(stub, assetId, true)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]*Lien, 0, len(liens))
+	for _, lien := range liens {
+		if lien.State != lienActive {
+			continue
+		}
+		if lien.ApprovedTo != currentOwnerId {
+			return nil, fmt.Errorf("asset %s has active lien %s, holder %s has not approved transfer to %s", assetId, lien.Id, lien.HolderId, currentOwnerId)
+		}
+		active = append(active, lien)
+	}
+
+	return active, nil
+}
+
+// 转让前校验每个有效质权的质权人都同意转给 currentOwnerId，并消耗这些同意
+func consumeLienApprovals(stub shim.ChaincodeStubInterface, assetId, currentOwnerId string) error {
+	liens, err := checkLienApprovals(stub, assetId, currentOwnerId)
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+	for _, lien := range liens {
+		lien.ApprovedTo = ""
+		lien.UpdatedAt = now
+		if err := putLien(stub, lien); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func emitLienEvent(stub shim.ChaincodeStubInterface, eventType string, lien *Lien, to string) error {
+	return emitEvent(stub, &ChaincodeEvent{
+		Type:    eventType,
+		AssetId: lien.AssetId,
+		From:    lien.HolderId,
+		To:      to,
+		RefId:   lien.Id,
+	})
+}
+
+// 质权登记，由资产拥有者本人或管理员登记，登记后待质权人确认
+func lienRegister(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：资产 id、质权人 id、担保金额、顺位、到期时间（可选）
+	if len(args) != 4 && len(args) != 5 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	assetId := args[0]
+	holderId := args[1]
+	if assetId == "" || holderId == "" {
+		return shim.Error("invalid args")
+	}
+	amount, err := parseTokenAmount(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	priority, err := strconv.Atoi(args[3])
+	if err != nil || priority <= 0 {
+		return shim.Error(fmt.Sprintf("invalid priority: %s", args[3]))
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	var expiry *time.Time
+	if len(args) == 5 && args[4] != "" {
+		t, err := time.Parse(time.RFC3339, args[4])
+		if err != nil {
+			return shim.Error(fmt.Sprintf("invalid expiry: %s", err))
+		}
+		if !t.After(now) {
+			return shim.Error("expiry must be in the future")
+		}
+		t = t.UTC()
+		expiry = &t
+	}
+
+	// 3：验证数据是否存在
+	asset, err := getAsset(stub, assetId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	// 份额化资产由多个持有人共有，不能由登记者单独质押
+	if err := checkWholeAsset(asset); err != nil {
+		return shim.Error(err.Error())
+	}
+	if asset.Owner == holderId {
+		return shim.Error("asset owner cannot hold a lien on own asset")
+	}
+	if _, err := getUserWithAccess(stub, asset.Owner); err != nil {
+		return shim.Error(err.Error())
+	}
+	if _, err := getActiveUser(stub, holderId); err != nil {
+		return shim.Error(err.Error())
+	}
+	liens, err := getAssetLiens(stub, assetId, true)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	for _, l := range liens {
+		if l.Priority == priority {
+			return shim.Error(fmt.Sprintf("priority %d already taken by lien %s", priority, l.Id))
+		}
+	}
+
+	// 4： 状态写入
+	lien := &Lien{
+		Id:        stub.GetTxID(),
+		AssetId:   assetId,
+		HolderId:  holderId,
+		Amount:    amount,
+		Priority:  priority,
+		Expiry:    expiry,
+		State:     lienPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := putLien(stub, lien); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitLienEvent(stub, eventLienRegistered, lien, asset.Owner); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 返回质权 id，确认、解除和同意转让时使用
+	return shim.Success([]byte(lien.Id))
+}
+
+// 读取未解除且未到期的质权并校验调用者是质权人本人或管理员
+func loadHolderLien(stub shim.ChaincodeStubInterface, assetId, lienId string) (*Lien, time.Time, error) {
+	if assetId == "" || lienId == "" {
+		return nil, time.Time{}, fmt.Errorf("invalid args")
+	}
+
+	lien, err := getLien(stub, assetId, lienId)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if !lien.open(now) {
+		return nil, time.Time{}, fmt.Errorf("lien %s not active", lienId)
+	}
+
+	holder, err := getUser(stub, lien.HolderId)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if err := checkUserAccess(stub, holder); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return lien, now, nil
+}
+
+// 质权人确认质权，确认后质权生效
+func lienAccept(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：资产 id、质权 id
+	if len(args) != 2 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性 3：验证数据是否存在
+	lien, now, err := loadHolderLien(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if lien.State != lienPending {
+		return shim.Error(fmt.Sprintf("lien %s already accepted", lien.Id))
+	}
+	asset, err := getAsset(stub, lien.AssetId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 4： 状态写入
+	lien.State = lienActive
+	lien.UpdatedAt = now
+	if err := putLien(stub, lien); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitLienEvent(stub, eventLienAccepted, lien, asset.Owner); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 质权解除，由质权人本人或管理员操作，质权人也可以用它拒绝待确认的质权
+func lienRelease(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：资产 id、质权 id
+	if len(args) != 2 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性 3：验证数据是否存在
+	lien, now, err := loadHolderLien(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 4： 状态写入
+	lien.State = lienReleased
+	lien.ApprovedTo = ""
+	lien.UpdatedAt = now
+	if err := putLien(stub, lien); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitLienEvent(stub, eventLienReleased, lien, ""); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 质权人同意把资产转给指定的受让者，受让者为空时撤回同意
+func lienApprove(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：资产 id、质权 id、受让者 id
+	if len(args) != 3 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性 3：验证数据是否存在
+	lien, now, err := loadHolderLien(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if lien.State != lienActive {
+		return shim.Error(fmt.Sprintf("lien %s not accepted by holder", lien.Id))
+	}
+	recipientId := args[2]
+	if recipientId != "" {
+		if _, err := getActiveUser(stub, recipientId); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// 4： 状态写入
+	lien.ApprovedTo = recipientId
+	lien.UpdatedAt = now
+	if err := putLien(stub, lien); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitLienEvent(stub, eventLienApproved, lien, recipientId); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 资产的质权查询，默认返回有效和待确认的质权，第二个参数为 all 时包括已解除和已到期的质权
+func queryAssetLiens(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	assetId := args[0]
+	if assetId == "" {
+		return shim.Error("invalid args")
+	}
+	openOnly := len(args) == 1 || args[1] != "all"
+
+	// 3：验证数据是否存在
+	if _, err := getAsset(stub, assetId); err != nil {
+		return shim.Error(err.Error())
+	}
+	liens, err := getAssetLiens(stub, assetId, openOnly)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	liensBytes, err := json.Marshal(liens)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(liensBytes)
+}
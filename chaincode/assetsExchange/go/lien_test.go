@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLienRegisterAmount(t *testing.T) {
+	s := newTestStub(t)
+	s.defineClass()
+	s.registerUser(testMspId, "alice")
+	s.registerUser(testMspId, "bob")
+	s.enrollAsset("a1", "alice")
+
+	// 担保金额与代币一样是正整数的最小单位
+	for _, amount := range []string{"", "0", "-1", "100.5", "1e3", "NaN", "9223372036854775808"} {
+		msg := s.asUser(testMspId, "alice").mustFail("lienRegister", "a1", "bob", amount, "1")
+		if !strings.Contains(msg, "invalid amount") {
+			t.Errorf("%q: unexpected error: %s", amount, msg)
+		}
+	}
+	s.asUser(testMspId, "alice").mustInvoke("lienRegister", "a1", "bob", "9223372036854775807", "1")
+
+	var liens []*Lien
+	if err := json.Unmarshal(s.mustInvoke("queryAssetLiens", "a1"), &liens); err != nil {
+		t.Fatal(err)
+	}
+	if len(liens) != 1 || liens[0].Amount != 9223372036854775807 || liens[0].State != lienPending {
+		t.Fatalf("unexpected liens %+v", liens)
+	}
+
+	// 质权人确认前质权不生效，不限制转让
+	s.registerUser(testMspId, "carol")
+	s.asUser(testMspId, "alice").mustInvoke("assetExchange", "alice", "a1", "carol")
+}
+
+func TestLienBlocksTransfer(t *testing.T) {
+	s := newTestStub(t)
+	s.defineClass()
+	s.registerUser(testMspId, "alice")
+	s.registerUser(testMspId, "bob")
+	s.registerUser(testMspId, "carol")
+	s.enrollAsset("a1", "alice")
+
+	// 只有拥有者本人或管理员可以登记，拥有者不能是质权人
+	s.asUser(testMspId, "bob").mustFail("lienRegister", "a1", "bob", "500", "1")
+	s.asUser(testMspId, "alice").mustFail("lienRegister", "a1", "alice", "500", "1")
+	lienId := string(s.asUser(testMspId, "alice").mustInvoke("lienRegister", "a1", "bob", "500", "1"))
+	// 待确认的质权占用顺位
+	msg := s.asUser(testMspId, "alice").mustFail("lienRegister", "a1", "carol", "100", "1")
+	if !strings.Contains(msg, "priority 1 already taken") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+
+	// 只有质权人可以确认，确认前不能表态
+	msg = s.asUser(testMspId, "bob").mustFail("lienApprove", "a1", lienId, "carol")
+	if !strings.Contains(msg, "not accepted by holder") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+	msg = s.asUser(testMspId, "alice").mustFail("lienAccept", "a1", lienId)
+	if !strings.Contains(msg, "permission denied") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+	s.asUser(testMspId, "bob").mustInvoke("lienAccept", "a1", lienId)
+	if evt := s.lastEvent(); evt.Type != eventLienAccepted || evt.From != "bob" || evt.To != "alice" || evt.RefId != lienId {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+	s.asUser(testMspId, "bob").mustFail("lienAccept", "a1", lienId)
+
+	msg = s.asUser(testMspId, "alice").mustFail("assetExchange", "alice", "a1", "carol")
+	if !strings.Contains(msg, "has not approved transfer to carol") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+
+	// 同意只对指定的受让者有效，且只能由质权人表态
+	s.asUser(testMspId, "alice").mustFail("lienApprove", "a1", lienId, "carol")
+	s.asUser(testMspId, "bob").mustInvoke("lienApprove", "a1", lienId, "carol")
+	s.asUser(testMspId, "alice").mustFail("assetExchange", "alice", "a1", "bob")
+	s.asUser(testMspId, "alice").mustInvoke("assetExchange", "alice", "a1", "carol")
+
+	// 质权随资产转移，同意在转让时消耗
+	var liens []*Lien
+	if err := json.Unmarshal(s.mustInvoke("queryAssetLiens", "a1"), &liens); err != nil {
+		t.Fatal(err)
+	}
+	if len(liens) != 1 || liens[0].State != lienActive || liens[0].ApprovedTo != "" {
+		t.Fatalf("unexpected liens %+v", liens)
+	}
+	s.asUser(testMspId, "carol").mustFail("assetExchange", "carol", "a1", "alice")
+
+	s.asUser(testMspId, "bob").mustInvoke("lienRelease", "a1", lienId)
+	s.asUser(testMspId, "bob").mustFail("lienRelease", "a1", lienId)
+	s.asUser(testMspId, "carol").mustInvoke("assetExchange", "carol", "a1", "alice")
+}
+
+func TestLienExpiry(t *testing.T) {
+	s := newTestStub(t)
+	s.defineClass()
+	s.registerUser(testMspId, "alice")
+	s.registerUser(testMspId, "bob")
+	s.enrollAsset("a1", "alice")
+
+	s.asUser(testMspId, "alice").mustFail("lienRegister", "a1", "bob", "500", "1", s.now.Format(time.RFC3339))
+	lienId := string(s.asUser(testMspId, "alice").mustInvoke("lienRegister", "a1", "bob", "500", "1", s.now.Add(time.Hour).Format(time.RFC3339)))
+	s.asUser(testMspId, "bob").mustInvoke("lienAccept", "a1", lienId)
+	s.asUser(testMspId, "alice").mustFail("assetExchange", "alice", "a1", "bob")
+
+	// 到期的质权不再阻止转让
+	s.now = s.now.Add(time.Hour)
+	s.asUser(testMspId, "alice").mustInvoke("assetExchange", "alice", "a1", "bob")
+}
@@ -0,0 +1,520 @@
+package main
+
+// 资产挂牌：拥有者以要价和有效期挂牌出售资产，买方查询在售挂牌并直接购买
+// 购买时代币付款和资产交割在同一交易中完成，与 buyAsset 相同
+// 每项资产同时只能有一个在售挂牌，由 listing~assetId 组合键维护；资产以其它方式转让时，在售挂牌自动撤下
+// 挂牌查询使用 CouchDB 富查询，索引见 META-INF/statedb/couchdb/indexes/indexListing.json
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	listingDocType = "listing"
+	listingIndex   = "listing~assetId"
+
+	// 挂牌状态
+	listingOpen      = "open"
+	listingSold      = "sold"
+	listingCancelled = "cancelled"
+	listingWithdrawn = "withdrawn" // 资产以其它方式转让，挂牌自动撤下
+)
+
+// Listing 资产挂牌
+type Listing struct {
+	DocType    string    `json:"doc_type"`
+	Id         string    `json:"id"` // 挂牌交易的 txID
+	AssetId    string    `json:"asset_id"`
+	ClassId    string    `json:"class_id"` // 资产类别，挂牌时从资产复制，用于查询
+	SellerId   string    `json:"seller_id"`
+	Price      int64     `json:"price"` // 要价，单位为代币的最小单位
+	ValidFrom  time.Time `json:"valid_from"`
+	ValidUntil time.Time `json:"valid_until"`
+	State      string    `json:"state"`              // open / sold / cancelled / withdrawn
+	BuyerId    string    `json:"buyer_id,omitempty"` // 成交后的买方
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ListingQuery 挂牌查询条件，只查询在售且在有效期内的挂牌
+type ListingQuery struct {
+	ClassId  string `json:"class_id"`
+	SellerId string `json:"seller_id"`
+	MinPrice int64  `json:"min_price"` // 为 0 时不限
+	MaxPrice int64  `json:"max_price"` // 为 0 时不限
+}
+
+// 在 now 时刻挂牌是否可以购买
+func (l *Listing) purchasable(now time.Time) bool {
+	return l.State == listingOpen && !now.Before(l.ValidFrom) && now.Before(l.ValidUntil)
+}
+
+// 以 listing_ 开头的，认为是挂牌
+func constructListingKey(listingId string) string {
+	return fmt.Sprintf("listing_%s", listingId)
+}
+
+func getListing(stub shim.ChaincodeStubInterface, listingId string) (*Listing, error) {
+	listingBytes, err := stub.GetState(constructListingKey(listingId))
+	if err != nil || len(listingBytes) == 0 {
+		return nil, fmt.Errorf("listing not found")
+	}
+
+	listing := new(Listing)
+	if err := json.Unmarshal(listingBytes, listing); err != nil {
+		return nil, fmt.Errorf("unmarshal listing error: %s", err)
+	}
+
+	return listing, nil
+}
+
+func putListing(stub shim.ChaincodeStubInterface, listing *Listing) error {
+	listingBytes, err := json.Marshal(listing)
+	if err != nil {
+		return fmt.Errorf("marshal listing error: %s", err)
+	}
+	if err := stub.PutState(constructListingKey(listing.Id), listingBytes); err != nil {
+		return fmt.Errorf("save listing error: %s", err)
+	}
+
+	return nil
+}
+
+// 资产的在售挂牌 id，没有时为空
+func getOpenListingId(stub shim.ChaincodeStubInterface, assetId string) (string, error) {
+	indexKey, err := stub.CreateCompositeKey(listingIndex, []string{assetId})
+	if err != nil {
+		return "", fmt.Errorf("create key error: %s", err)
+	}
+	listingId, err := stub.GetState(indexKey)
+	if err != nil {
+		return "", fmt.Errorf("get listing index error: %s", err)
+	}
+
+	return string(listingId), nil
+}
+
+func putOpenListingId(stub shim.ChaincodeStubInterface, assetId, listingId string) error {
+	indexKey, err := stub.CreateCompositeKey(listingIndex, []string{assetId})
+	if err != nil {
+		return fmt.Errorf("create key error: %s", err)
+	}
+	if listingId == "" {
+		if err := stub.DelState(indexKey); err != nil {
+			return fmt.Errorf("delete listing index error: %s", err)
+		}
+		return nil
+	}
+	if err := stub.PutState(indexKey, []byte(listingId)); err != nil {
+		return fmt.Errorf("save listing index error: %s", err)
+	}
+
+	return nil
+}
+
+// 关闭资产的在售挂牌，state 为关闭后的状态
+func closeListing(stub shim.ChaincodeStubInterface, listing *Listing, state string) error {
+	now, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+
+	listing.State = state
+	listing.UpdatedAt = now
+	if err := putListing(stub, listing); err != nil {
+		return err
+	}
+
+	return putOpenListingId(stub, listing.AssetId, "")
+}
+
+// 资产转让时撤下其在售挂牌，由 transferAssetForPrice 调用
+// 挂牌购买时同样会经过这里，购买方法随后把挂牌状态改为 sold，以最后一次写入为准
+func withdrawAssetListing(stub shim.ChaincodeStubInterface, assetId string) error {
+	listingId, err := getOpenListingId(stub, assetId)
+	if err != nil || listingId == "" {
+		return err
+	}
+	listing, err := getListing(stub, listingId)
+	if err != nil {
+		return err
+	}
+
+	return closeListing(stub, listing, listingWithdrawn)
+}
+
+// 解析挂牌的要价和有效期，validFrom 为空时从当前时间开始
+func parseListingTerms(now time.Time, priceStr, validFromStr, validUntilStr string) (int64, time.Time, time.Time, error) {
+	price, err := parseTokenAmount(priceStr)
+	if err != nil {
+		return 0, time.Time{}, time.Time{}, err
+	}
+
+	validFrom := now
+	if validFromStr != "" {
+		if validFrom, err = time.Parse(time.RFC3339, validFromStr); err != nil {
+			return 0, time.Time{}, time.Time{}, fmt.Errorf("invalid valid_from: %s", err)
+		}
+	}
+	validUntil, err := time.Parse(time.RFC3339, validUntilStr)
+	if err != nil {
+		return 0, time.Time{}, time.Time{}, fmt.Errorf("invalid valid_until: %s", err)
+	}
+	if !validUntil.After(now) || !validUntil.After(validFrom) {
+		return 0, time.Time{}, time.Time{}, fmt.Errorf("valid_until must be in the future and after valid_from")
+	}
+
+	return price, validFrom.UTC(), validUntil.UTC(), nil
+}
+
+func emitListingEvent(stub shim.ChaincodeStubInterface, eventType string, listing *Listing) error {
+	return emitEvent(stub, &ChaincodeEvent{
+		Type:    eventType,
+		AssetId: listing.AssetId,
+		From:    listing.SellerId,
+		Amount:  listing.Price,
+		RefId:   listing.Id,
+	})
+}
+
+// 挂牌出售，只有资产拥有者本人或管理员可以挂牌
+func listingCreate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：卖方 id、资产 id、要价、有效期开始（可为空）、有效期结束
+	if len(args) != 5 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	sellerId := args[0]
+	assetId := args[1]
+	if sellerId == "" || assetId == "" {
+		return shim.Error("invalid args")
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	price, validFrom, validUntil, err := parseListingTerms(now, args[2], args[3], args[4])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 3：验证数据是否存在
+	seller, err := getUserWithAccess(stub, sellerId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkNotMultisig(seller); err != nil {
+		return shim.Error(err.Error())
+	}
+	asset, err := getAsset(stub, assetId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if asset.Owner != sellerId {
+		return shim.Error("asset owner not match")
+	}
+	if err := checkWholeAsset(asset); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkNotFrozen(stub, asset, seller); err != nil {
+		return errorResponse(err)
+	}
+	if err := checkNotAuctioned(stub, assetId, ""); err != nil {
+		return shim.Error(err.Error())
+	}
+	if listingId, err := getOpenListingId(stub, assetId); err != nil {
+		return shim.Error(err.Error())
+	} else if listingId != "" {
+		return shim.Error(fmt.Sprintf("asset %s already listed in %s", assetId, listingId))
+	}
+
+	// 4： 状态写入
+	listing := &Listing{
+		DocType:    listingDocType,
+		Id:         stub.GetTxID(),
+		AssetId:    assetId,
+		ClassId:    asset.ClassId,
+		SellerId:   sellerId,
+		Price:      price,
+		ValidFrom:  validFrom,
+		ValidUntil: validUntil,
+		State:      listingOpen,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := putListing(stub, listing); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putOpenListingId(stub, assetId, listing.Id); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitListingEvent(stub, eventListingCreated, listing); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 返回挂牌 id
+	return shim.Success([]byte(listing.Id))
+}
+
+// 读取在售挂牌并校验调用者是卖方本人或管理员
+func loadSellerListing(stub shim.ChaincodeStubInterface, listingId string) (*Listing, error) {
+	if listingId == "" {
+		return nil, fmt.Errorf("invalid args")
+	}
+
+	listing, err := getListing(stub, listingId)
+	if err != nil {
+		return nil, err
+	}
+	if listing.State != listingOpen {
+		return nil, fmt.Errorf("listing is %s", listing.State)
+	}
+	if _, err := getUserWithAccess(stub, listing.SellerId); err != nil {
+		return nil, err
+	}
+
+	return listing, nil
+}
+
+// 修改挂牌的要价和有效期
+func listingUpdate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：挂牌 id、要价、有效期开始（可为空）、有效期结束
+	if len(args) != 4 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性 3：验证数据是否存在
+	listing, err := loadSellerListing(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	price, validFrom, validUntil, err := parseListingTerms(now, args[1], args[2], args[3])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 4： 状态写入
+	listing.Price = price
+	listing.ValidFrom = validFrom
+	listing.ValidUntil = validUntil
+	listing.UpdatedAt = now
+	if err := putListing(stub, listing); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitListingEvent(stub, eventListingUpdated, listing); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 撤销挂牌
+func listingCancel(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性 3：验证数据是否存在
+	listing, err := loadSellerListing(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 4： 状态写入
+	if err := closeListing(stub, listing, listingCancelled); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitListingEvent(stub, eventListingCancelled, listing); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 按挂牌价格购买，代币从买方转给卖方，资产从卖方转给买方，挂牌关闭
+func listingPurchase(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：挂牌 id、买方 id
+	if len(args) != 2 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	listingId := args[0]
+	buyerId := args[1]
+	if listingId == "" || buyerId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	listing, err := getListing(stub, listingId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !listing.purchasable(now) {
+		return shim.Error(fmt.Sprintf("listing %s not purchasable", listingId))
+	}
+	if listing.SellerId == buyerId {
+		return shim.Error("cannot buy own listing")
+	}
+	// 只有买方本人或管理员可以购买
+	buyer, err := getUserWithAccess(stub, buyerId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkUserNotFrozen(stub, buyer); err != nil {
+		return errorResponse(err)
+	}
+
+	// 4： 状态写入，付款和交割任何一步失败，整个交易都不会生效
+	if err := moveTokens(stub, buyerId, listing.SellerId, listing.Price); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := transferAssetForPrice(stub, listing.SellerId, listing.AssetId, buyerId, listing.Price); err != nil {
+		return errorResponse(err)
+	}
+	listing.BuyerId = buyerId
+	if err := closeListing(stub, listing, listingSold); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:    eventAssetSold,
+		AssetId: listing.AssetId,
+		From:    listing.SellerId,
+		To:      buyerId,
+		Amount:  listing.Price,
+		RefId:   listing.Id,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 挂牌查询
+func queryListing(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	listingId := args[0]
+	if listingId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	listingBytes, err := stub.GetState(constructListingKey(listingId))
+	if err != nil || len(listingBytes) == 0 {
+		return shim.Error("listing not found")
+	}
+
+	return shim.Success(listingBytes)
+}
+
+// 在售挂牌查询，按类别、卖方、价格区间过滤，分页返回
+// 有效期的过滤是在取出一页之后进行的，过滤后一页的记录数可能少于分页大小
+func queryListings(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数，bookmark 可以不传
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	query := new(ListingQuery)
+	if args[0] != "" {
+		if err := json.Unmarshal([]byte(args[0]), query); err != nil {
+			return shim.Error(fmt.Sprintf("invalid query: %s", err))
+		}
+	}
+	pageSize, err := parsePageSize(args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	bookmark := ""
+	if len(args) == 3 {
+		bookmark = args[2]
+	}
+
+	selector := map[string]interface{}{
+		"doc_type": listingDocType,
+		"state":    listingOpen,
+	}
+	if query.ClassId != "" {
+		selector["class_id"] = query.ClassId
+	}
+	if query.SellerId != "" {
+		selector["seller_id"] = query.SellerId
+	}
+	priceCond := make(map[string]interface{})
+	if query.MinPrice > 0 {
+		priceCond["$gte"] = query.MinPrice
+	}
+	if query.MaxPrice > 0 {
+		priceCond["$lte"] = query.MaxPrice
+	}
+	if len(priceCond) != 0 {
+		selector["price"] = priceCond
+	}
+	queryBytes, err := json.Marshal(map[string]interface{}{
+		"selector": selector,
+	})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal query error: %s", err))
+	}
+
+	// 3：查询数据
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	result, metadata, err := stub.GetQueryResultWithPagination(string(queryBytes), pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("query listings error: %s", err))
+	}
+	defer result.Close()
+
+	listings := make([]*Listing, 0)
+	for result.HasNext() {
+		listingVal, err := result.Next()
+		if err != nil {
+			return shim.Error(fmt.Sprintf("query error: %s", err))
+		}
+
+		listing := new(Listing)
+		if err := json.Unmarshal(listingVal.GetValue(), listing); err != nil {
+			return shim.Error(fmt.Sprintf("unmarshal error: %s", err))
+		}
+		if !listing.purchasable(now) {
+			continue
+		}
+		listings = append(listings, listing)
+	}
+
+	pageBytes, err := json.Marshal(&PageResult{
+		Records:  listings,
+		Count:    metadata.GetFetchedRecordsCount(),
+		Bookmark: metadata.GetBookmark(),
+	})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(pageBytes)
+}
@@ -0,0 +1,385 @@
+package main
+
+// 测试用的链码桩：在 shim.MockStub 之上补充调用者身份、transient map、交易时间、链码事件和分页查询
+// shim.MockStub 的 GetCreator、GetTransient 返回空，分页查询返回空迭代器，DelPrivateData 没有实现，这里都由 testStub 提供
+// CouchDB 富查询无法模拟，testStub 只记录查询语句，用于核对链码生成的 selector
+// 调用者证书由测试生成，属性按 fabric-ca 的格式写在扩展 1.2.3.4.5.6.7.8.1 中，cid 包据此读取 admin 等属性
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	"github.com/hyperledger/fabric/protos/msp"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	testMspId  = "Org1MSP"
+	testMspId2 = "Org2MSP"
+	testClass  = "loan"
+
+	// 实例化时授予 asAdmin 身份的角色
+	testAdminGrant = `[{"msp_id":"Org1MSP","subject":"CN=admin","roles":["admin"]}]`
+)
+
+// fabric-ca 签发证书时写入属性的扩展
+var attrsOid = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+type testStub struct {
+	*shim.MockStub
+	t         *testing.T
+	args      []string
+	creator   []byte
+	transient map[string][]byte
+	now       time.Time
+	txSeq     int
+	events    []*pb.ChaincodeEvent
+	// 每个交易只保留最后一次 SetEvent
+	txEvent *pb.ChaincodeEvent
+	// GetHistoryForKey 返回的键历史，没有设置时返回空
+	keyHistory map[string][]*queryresult.KeyModification
+	// 富查询的语句，MockStub 不支持 CouchDB 查询，只记录语句并返回空结果
+	queries []*richQuery
+}
+
+type richQuery struct {
+	query    string
+	pageSize int32
+	bookmark string
+}
+
+// 新建链码桩并以管理员身份实例化链码，管理员持有 admin 属性
+func newTestStub(t *testing.T) *testStub {
+	s := &testStub{
+		MockStub:   shim.NewMockStub("assetsExchange", new(AssertsManageCC)),
+		t:          t,
+		now:        time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		keyHistory: make(map[string][]*queryresult.KeyModification),
+	}
+	s.asAdmin()
+	if resp := s.init(testAdminGrant); resp.Status != shim.OK {
+		t.Fatalf("init: %s", resp.Message)
+	}
+
+	return s
+}
+
+// 生成调用者身份：MSP ID + 自签名证书，证书 Subject 为 CN=cn
+func newTestCreator(t *testing.T, mspId, cn string, attrs map[string]string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if len(attrs) != 0 {
+		attrsBytes, err := json.Marshal(map[string]interface{}{"attrs": attrs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: attrsOid, Value: attrsBytes}}
+	}
+	certDer, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspId,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return creator
+}
+
+func (s *testStub) as(mspId, cn string, attrs map[string]string) *testStub {
+	s.creator = newTestCreator(s.t, mspId, cn, attrs)
+	return s
+}
+
+func (s *testStub) asAdmin() *testStub {
+	return s.as(testMspId, "admin", map[string]string{adminAttr: "true"})
+}
+
+func (s *testStub) asRegulator() *testStub {
+	return s.as(testMspId, "regulator", map[string]string{regulatorAttr: "true"})
+}
+
+// 以用户本人的身份调用，用户由 registerUser 开户，绑定到 CN=用户id
+func (s *testStub) asUser(mspId, userId string) *testStub {
+	return s.as(mspId, userId, nil)
+}
+
+// 执行一个交易，交易时间每次前进一秒
+func (s *testStub) invoke(fcn string, args ...string) pb.Response {
+	s.args = append([]string{fcn}, args...)
+	s.txSeq++
+	s.now = s.now.Add(time.Second)
+	txId := fmt.Sprintf("tx%d", s.txSeq)
+
+	s.txEvent = nil
+	s.MockTransactionStart(txId)
+	resp := new(AssertsManageCC).Invoke(s)
+	s.MockTransactionEnd(txId)
+	s.transient = nil
+	if resp.Status == shim.OK && s.txEvent != nil {
+		s.events = append(s.events, s.txEvent)
+	}
+
+	return resp
+}
+
+func (s *testStub) init(args ...string) pb.Response {
+	s.args = append([]string{"init"}, args...)
+	s.txSeq++
+	txId := fmt.Sprintf("tx%d", s.txSeq)
+
+	s.MockTransactionStart(txId)
+	defer s.MockTransactionEnd(txId)
+	return new(AssertsManageCC).Init(s)
+}
+
+// 执行交易并要求成功
+func (s *testStub) mustInvoke(fcn string, args ...string) []byte {
+	s.t.Helper()
+	resp := s.invoke(fcn, args...)
+	if resp.Status != shim.OK {
+		s.t.Fatalf("%s %v: %s", fcn, args, resp.Message)
+	}
+
+	return resp.Payload
+}
+
+// 执行交易并要求失败，返回错误信息
+func (s *testStub) mustFail(fcn string, args ...string) string {
+	s.t.Helper()
+	resp := s.invoke(fcn, args...)
+	if resp.Status == shim.OK {
+		s.t.Fatalf("%s %v: expect error", fcn, args)
+	}
+
+	return resp.Message
+}
+
+// 最后一个成功交易发出的事件
+func (s *testStub) lastEvent() *ChaincodeEvent {
+	s.t.Helper()
+	if len(s.events) == 0 {
+		s.t.Fatal("no event emitted")
+	}
+	evt := new(ChaincodeEvent)
+	if err := json.Unmarshal(s.events[len(s.events)-1].Payload, evt); err != nil {
+		s.t.Fatal(err)
+	}
+
+	return evt
+}
+
+// 以管理员身份为 CN=userId 的身份开户，并完成 KYC 核验
+func (s *testStub) registerUser(mspId, userId string) {
+	s.t.Helper()
+	s.asAdmin()
+	s.mustInvoke("userRegister", userId, userId, mspId, "CN="+userId)
+	docHash := sha256.Sum256([]byte("kyc " + userId))
+	s.mustInvoke("updateUserProfile", userId, "", kycVerified, hex.EncodeToString(docHash[:]), "")
+}
+
+// 以管理员身份定义测试用的资产类别
+func (s *testStub) defineClass() {
+	s.t.Helper()
+	s.asAdmin()
+	s.mustInvoke("assetClassDefine", testClass, "Loan", `[{"name":"principal","type":"number","required":true}]`)
+}
+
+// 以管理员身份为用户登记资产，份额总数可以不传
+func (s *testStub) enrollAsset(assetId, ownerId string, units ...string) {
+	s.t.Helper()
+	s.asAdmin()
+	args := []string{assetId, assetId, testClass, `{"principal":100}`, ownerId}
+	s.mustInvoke("assetEnroll", append(args, units...)...)
+}
+
+// 以发行方身份为用户铸造代币
+func (s *testStub) mint(userId string, amount int64) {
+	s.t.Helper()
+	s.as(testMspId, "issuer", map[string]string{issuerAttr: "true"})
+	s.mustInvoke("tokenMint", userId, strconv.FormatInt(amount, 10))
+}
+
+func (s *testStub) balance(userId string) int64 {
+	s.t.Helper()
+	balance, err := getInt64State(s, constructBalanceKey(userId))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	return balance
+}
+
+func (s *testStub) getAsset(assetId string) *Asset {
+	s.t.Helper()
+	asset, err := getAsset(s, assetId)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	return asset
+}
+
+func (s *testStub) getUser(userId string) *User {
+	s.t.Helper()
+	user, err := getUser(s, userId)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	return user
+}
+
+// 资产的全部变更记录
+func (s *testStub) assetHistory(assetId string) []*AssetHistory {
+	s.t.Helper()
+	page := &PageResult{Records: &[]*AssetHistory{}}
+	if err := json.Unmarshal(s.mustInvoke("queryAssetHistory", assetId, "all", "200"), page); err != nil {
+		s.t.Fatal(err)
+	}
+
+	return *page.Records.(*[]*AssetHistory)
+}
+
+func (s *testStub) GetFunctionAndParameters() (string, []string) {
+	if len(s.args) == 0 {
+		return "", []string{}
+	}
+	return s.args[0], s.args[1:]
+}
+
+func (s *testStub) GetStringArgs() []string {
+	return s.args
+}
+
+func (s *testStub) GetArgs() [][]byte {
+	args := make([][]byte, 0, len(s.args))
+	for _, arg := range s.args {
+		args = append(args, []byte(arg))
+	}
+	return args
+}
+
+func (s *testStub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+func (s *testStub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+func (s *testStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return &timestamp.Timestamp{Seconds: s.now.Unix(), Nanos: int32(s.now.Nanosecond())}, nil
+}
+
+func (s *testStub) SetEvent(name string, payload []byte) error {
+	s.txEvent = &pb.ChaincodeEvent{TxId: s.TxID, EventName: name, Payload: payload}
+	return nil
+}
+
+func (s *testStub) DelPrivateData(collection, key string) error {
+	delete(s.PvtState[collection], key)
+	return nil
+}
+
+func (s *testStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &historyIterator{mods: s.keyHistory[key]}, nil
+}
+
+func (s *testStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	result, err := s.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return paginate(result, pageSize, bookmark)
+}
+
+func (s *testStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	result, err := s.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	return paginate(result, pageSize, bookmark)
+}
+
+func (s *testStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	s.queries = append(s.queries, &richQuery{query: query, pageSize: pageSize, bookmark: bookmark})
+	return &kvIterator{}, &pb.QueryResponseMetadata{}, nil
+}
+
+// 从 bookmark 开始取一页，bookmark 为下一页第一个键，与 LevelDB 的行为一致
+func paginate(result shim.StateQueryIteratorInterface, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	defer result.Close()
+
+	page := &kvIterator{}
+	next := ""
+	for result.HasNext() {
+		kv, err := result.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if bookmark != "" && kv.Key < bookmark {
+			continue
+		}
+		if int32(len(page.kvs)) == pageSize {
+			next = kv.Key
+			break
+		}
+		page.kvs = append(page.kvs, kv)
+	}
+
+	return page, &pb.QueryResponseMetadata{FetchedRecordsCount: int32(len(page.kvs)), Bookmark: next}, nil
+}
+
+type kvIterator struct {
+	kvs []*queryresult.KV
+}
+
+func (it *kvIterator) HasNext() bool { return len(it.kvs) != 0 }
+func (it *kvIterator) Close() error  { return nil }
+func (it *kvIterator) Next() (*queryresult.KV, error) {
+	kv := it.kvs[0]
+	it.kvs = it.kvs[1:]
+	return kv, nil
+}
+
+type historyIterator struct {
+	mods []*queryresult.KeyModification
+}
+
+func (it *historyIterator) HasNext() bool { return len(it.mods) != 0 }
+func (it *historyIterator) Close() error  { return nil }
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	mod := it.mods[0]
+	it.mods = it.mods[1:]
+	return mod, nil
+}
@@ -0,0 +1,550 @@
+package main
+
+// 多签转让：机构用户可以设置授权签署人集合和门限，N 个签署人中至少 M 个同意后资产才能转出
+// 设置了签署人的用户调用 assetExchange 不会立即转让，而是创建待签转让并返回其 id
+// 其它转出途径（要约、互换、挂牌、拍卖、份额转让、销户承接）对多签用户一律拒绝，见 checkNotMultisig
+// 签署人用各自的证书身份（MSP ID + Subject）同意或拒绝，同一身份只计一次，按表态时的签署人集合和门限计数
+// 同意数达到门限时在该交易中完成转让；拒绝数使门限不可能达到时待签转让被拒绝
+// 每次表态都追加到待签转让的 Decisions 中，同时写入资产变更记录（类型 multisig，达到门限时为带 Multisig 的转让记录）
+// 过期后不能再表态，查询时状态显示为 expired；调用 multisigExpire 把过期写入账本和资产变更记录
+// 签署人集合设置后只有管理员可以修改，避免单个身份绕过多签
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	// 待签转让的状态
+	multisigPending  = "pending"
+	multisigExecuted = "executed"
+	multisigRejected = "rejected"
+	multisigExpired  = "expired" // multisigExpire 写入账本，之前查询时根据过期时间计算
+
+	// 签署人的表态，以及资产变更记录中的发起和过期
+	decisionApprove = "approve"
+	decisionReject  = "reject"
+	decisionPropose = "propose"
+	decisionExpire  = "expire"
+
+	// 未指定过期时间时，待签转让的有效期
+	multisigDefaultTTL = 7 * 24 * time.Hour
+)
+
+// Signer 授权签署人
+type Signer struct {
+	Name    string `json:"name,omitempty"`
+	MspId   string `json:"msp_id"`
+	Subject string `json:"subject"` // 签署人证书的 Subject
+}
+
+// SignerDecision 签署人的一次表态
+type SignerDecision struct {
+	MspId     string    `json:"msp_id"`
+	Subject   string    `json:"subject"`
+	Decision  string    `json:"decision"` // approve / reject
+	TxId      string    `json:"tx_id"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// PendingTransfer 待签转让
+type PendingTransfer struct {
+	Id          string            `json:"id"` // 发起转让的交易 id
+	AssetId     string            `json:"asset_id"`
+	OwnerId     string            `json:"owner_id"`
+	RecipientId string            `json:"recipient_id"`
+	State       string            `json:"state"` // pending / executed / rejected，过期的待签转让查询时为 expired
+	Expiry      time.Time         `json:"expiry"`
+	Decisions   []*SignerDecision `json:"decisions"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// MultisigRecord 资产变更记录中的多签信息，同意数和拒绝数为该操作之后的统计
+type MultisigRecord struct {
+	PendingId   string `json:"pending_id"`
+	RecipientId string `json:"recipient_id"`
+	Decision    string `json:"decision"` // propose / approve / reject / expire
+	MspId       string `json:"msp_id"`   // 操作者的身份
+	Subject     string `json:"subject"`
+	Approvals   int    `json:"approvals"`
+	Rejections  int    `json:"rejections"`
+	Threshold   int    `json:"threshold"`
+	State       string `json:"state"` // 操作后待签转让的状态
+}
+
+// 以 multisig_ 开头的，认为是待签转让
+func constructPendingTransferKey(pendingId string) string {
+	return fmt.Sprintf("multisig_%s", pendingId)
+}
+
+func getPendingTransfer(stub shim.ChaincodeStubInterface, pendingId string) (*PendingTransfer, error) {
+	pendingBytes, err := stub.GetState(constructPendingTransferKey(pendingId))
+	if err != nil || len(pendingBytes) == 0 {
+		return nil, fmt.Errorf("pending transfer not found")
+	}
+
+	pending := new(PendingTransfer)
+	if err := json.Unmarshal(pendingBytes, pending); err != nil {
+		return nil, fmt.Errorf("unmarshal pending transfer error: %s", err)
+	}
+
+	return pending, nil
+}
+
+func putPendingTransfer(stub shim.ChaincodeStubInterface, pending *PendingTransfer) error {
+	pendingBytes, err := json.Marshal(pending)
+	if err != nil {
+		return fmt.Errorf("marshal pending transfer error: %s", err)
+	}
+	if err := stub.PutState(constructPendingTransferKey(pending.Id), pendingBytes); err != nil {
+		return fmt.Errorf("save pending transfer error: %s", err)
+	}
+
+	return nil
+}
+
+// 设置了多签的用户只能通过 assetExchange 发起待签转让，其它转出途径调用这里拒绝
+func checkNotMultisig(user *User) error {
+	if user.Threshold > 0 {
+		return fmt.Errorf("user %s requires %d signer approvals, transfer via assetExchange", user.Id, user.Threshold)
+	}
+
+	return nil
+}
+
+// 写入待签转让的资产变更记录，资产的拥有者不变
+func putMultisigHistory(stub shim.ChaincodeStubInterface, pending *PendingTransfer, record *MultisigRecord) error {
+	return putAssetHistory(stub, &AssetHistory{
+		AssetId:        pending.AssetId,
+		OriginOwnerId:  pending.OwnerId,
+		CurrentOwnerId: pending.OwnerId,
+		Action:         historyMultisig,
+		Multisig:       record,
+	})
+}
+
+func newMultisigRecord(pending *PendingTransfer, owner *User, mspId, subject, decision string) *MultisigRecord {
+	approvals, rejections := pending.count(owner)
+	return &MultisigRecord{
+		PendingId:   pending.Id,
+		RecipientId: pending.RecipientId,
+		Decision:    decision,
+		MspId:       mspId,
+		Subject:     subject,
+		Approvals:   approvals,
+		Rejections:  rejections,
+		Threshold:   owner.Threshold,
+		State:       pending.State,
+	}
+}
+
+// 身份是否为用户当前的签署人
+func (u *User) isSigner(mspId, subject string) bool {
+	for _, signer := range u.Signers {
+		if signer.MspId == mspId && signer.Subject == subject {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 按用户当前的签署人集合统计同意和拒绝数，已被移出签署人集合的身份不计
+func (p *PendingTransfer) count(owner *User) (int, int) {
+	approvals, rejections := 0, 0
+	for _, d := range p.Decisions {
+		if !owner.isSigner(d.MspId, d.Subject) {
+			continue
+		}
+		if d.Decision == decisionApprove {
+			approvals++
+		} else {
+			rejections++
+		}
+	}
+
+	return approvals, rejections
+}
+
+// 记录调用者的表态，同意数达到门限时完成转让，拒绝数使门限不可能达到时拒绝
+// 返回应发出的事件类型
+func decidePendingTransfer(stub shim.ChaincodeStubInterface, pending *PendingTransfer, owner *User, mspId, subject, decision string) (string, error) {
+	for _, d := range pending.Decisions {
+		if d.MspId == mspId && d.Subject == subject {
+			return "", fmt.Errorf("signer already decided on pending transfer %s", pending.Id)
+		}
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return "", err
+	}
+	pending.Decisions = append(pending.Decisions, &SignerDecision{
+		MspId:     mspId,
+		Subject:   subject,
+		Decision:  decision,
+		TxId:      stub.GetTxID(),
+		Timestamp: now,
+	})
+	pending.UpdatedAt = now
+
+	eventType := eventTransferApproved
+	if decision == decisionReject {
+		eventType = eventTransferDeclined
+	}
+	approvals, rejections := pending.count(owner)
+	switch {
+	case approvals >= owner.Threshold:
+		pending.State = multisigExecuted
+		eventType = eventAssetTransferred
+	case rejections > len(owner.Signers)-owner.Threshold:
+		pending.State = multisigRejected
+		eventType = eventMultisigRejected
+	}
+
+	// 同一交易内同一资产只能写入一条变更记录，达到门限时表态记在转让记录中
+	record := newMultisigRecord(pending, owner, mspId, subject, decision)
+	if pending.State == multisigExecuted {
+		// 出让者在待签期间可能已经处置了该资产，这里会再次校验所有权和冻结状态
+		if err := transferAssetWithHistory(stub, &AssetHistory{
+			AssetId:        pending.AssetId,
+			OriginOwnerId:  pending.OwnerId,
+			CurrentOwnerId: pending.RecipientId,
+			Action:         historyExchange,
+			Multisig:       record,
+		}); err != nil {
+			return "", err
+		}
+	} else if err := putMultisigHistory(stub, pending, record); err != nil {
+		return "", err
+	}
+
+	if err := putPendingTransfer(stub, pending); err != nil {
+		return "", err
+	}
+
+	return eventType, nil
+}
+
+func emitPendingTransferEvent(stub shim.ChaincodeStubInterface, eventType string, pending *PendingTransfer) error {
+	return emitEvent(stub, &ChaincodeEvent{
+		Type:    eventType,
+		AssetId: pending.AssetId,
+		From:    pending.OwnerId,
+		To:      pending.RecipientId,
+		RefId:   pending.Id,
+	})
+}
+
+// 设置了签署人的用户转让资产时由 assetExchange 调用，创建待签转让
+// 调用者可以是用户本人、管理员或签署人，签署人发起时同时记为同意
+func proposeMultisigTransfer(stub shim.ChaincodeStubInterface, owner *User, assetId, recipientId, expiryStr string) pb.Response {
+	mspId, subject, err := getCallerIdentity(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	callerIsSigner := owner.isSigner(mspId, subject)
+	if !callerIsSigner {
+		if err := checkUserAccess(stub, owner); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	expiry := now.Add(multisigDefaultTTL)
+	if expiryStr != "" {
+		if expiry, err = time.Parse(time.RFC3339, expiryStr); err != nil {
+			return shim.Error(fmt.Sprintf("invalid expiry: %s", err))
+		}
+		if !expiry.After(now) {
+			return shim.Error("expiry must be in the future")
+		}
+	}
+
+	// 提前校验，避免签署人为注定失败的转让表态，受让者的校验与执行转让时相同
+	if _, err := checkTransferRecipient(stub, owner.Id, recipientId); err != nil {
+		return shim.Error(err.Error())
+	}
+	asset, err := getAsset(stub, assetId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if asset.Owner != owner.Id {
+		return shim.Error("asset owner not match")
+	}
+	if err := checkWholeAsset(asset); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkNotFrozen(stub, asset, owner); err != nil {
+		return errorResponse(err)
+	}
+	if err := checkNotAuctioned(stub, assetId, ""); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	pending := &PendingTransfer{
+		Id:          stub.GetTxID(),
+		AssetId:     assetId,
+		OwnerId:     owner.Id,
+		RecipientId: recipientId,
+		State:       multisigPending,
+		Expiry:      expiry.UTC(),
+		Decisions:   make([]*SignerDecision, 0),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	eventType := eventTransferPending
+	if callerIsSigner {
+		if eventType, err = decidePendingTransfer(stub, pending, owner, mspId, subject, decisionApprove); err != nil {
+			return errorResponse(err)
+		}
+		if eventType == eventTransferApproved {
+			eventType = eventTransferPending
+		}
+	} else {
+		if err := putPendingTransfer(stub, pending); err != nil {
+			return shim.Error(err.Error())
+		}
+		if err := putMultisigHistory(stub, pending, newMultisigRecord(pending, owner, mspId, subject, decisionPropose)); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	if err := emitPendingTransferEvent(stub, eventType, pending); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 返回待签转让 id，签署人凭此表态
+	return shim.Success([]byte(pending.Id))
+}
+
+// 签署人同意或拒绝待签转让
+func multisigDecide(stub shim.ChaincodeStubInterface, args []string, decision string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	pendingId := args[0]
+	if pendingId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	pending, err := getPendingTransfer(stub, pendingId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if pending.State != multisigPending {
+		return shim.Error(fmt.Sprintf("pending transfer is %s", pending.State))
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !now.Before(pending.Expiry) {
+		return shim.Error("pending transfer expired")
+	}
+	owner, err := getActiveUser(stub, pending.OwnerId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if owner.Threshold == 0 {
+		return shim.Error(fmt.Sprintf("user %s no longer requires multisig, submit the transfer again", owner.Id))
+	}
+	// 只有签署人本人可以表态，管理员也不能代为表态
+	mspId, subject, err := getCallerIdentity(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !owner.isSigner(mspId, subject) {
+		return shim.Error(fmt.Sprintf("permission denied: caller is not a signer of user %s", owner.Id))
+	}
+
+	// 4： 状态写入
+	eventType, err := decidePendingTransfer(stub, pending, owner, mspId, subject, decision)
+	if err != nil {
+		return errorResponse(err)
+	}
+	if err := emitPendingTransferEvent(stub, eventType, pending); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+func multisigApprove(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	return multisigDecide(stub, args, decisionApprove)
+}
+
+func multisigReject(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	return multisigDecide(stub, args, decisionReject)
+}
+
+// 把已过期的待签转让标记为 expired，并写入资产变更记录。出让者本人、管理员或签署人可以操作
+func multisigExpire(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	pendingId := args[0]
+	if pendingId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	pending, err := getPendingTransfer(stub, pendingId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if pending.State != multisigPending {
+		return shim.Error(fmt.Sprintf("pending transfer is %s", pending.State))
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if now.Before(pending.Expiry) {
+		return shim.Error("pending transfer not expired yet")
+	}
+	owner, err := getUser(stub, pending.OwnerId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	mspId, subject, err := getCallerIdentity(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !owner.isSigner(mspId, subject) {
+		if err := checkUserAccess(stub, owner); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// 4： 状态写入
+	pending.State = multisigExpired
+	pending.UpdatedAt = now
+	if err := putPendingTransfer(stub, pending); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putMultisigHistory(stub, pending, newMultisigRecord(pending, owner, mspId, subject, decisionExpire)); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitPendingTransferEvent(stub, eventTransferExpired, pending); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 设置用户的签署人集合和门限，签署人为空、门限为 0 时取消多签
+// 尚未设置签署人时由用户本人或管理员设置，设置后只有管理员可以修改
+func userSetSigners(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：用户 id、签署人列表（JSON 数组）、门限
+	if len(args) != 3 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	userId := args[0]
+	if userId == "" {
+		return shim.Error("invalid args")
+	}
+	var signers []*Signer
+	if err := json.Unmarshal([]byte(args[1]), &signers); err != nil {
+		return shim.Error(fmt.Sprintf("invalid signers: %s", err))
+	}
+	threshold, err := strconv.Atoi(args[2])
+	if err != nil || threshold < 0 || threshold > len(signers) {
+		return shim.Error(fmt.Sprintf("invalid threshold: %s", args[2]))
+	}
+	if (threshold == 0) != (len(signers) == 0) {
+		return shim.Error("threshold must be between 1 and the number of signers")
+	}
+	seen := make(map[string]bool)
+	for _, signer := range signers {
+		if signer == nil || signer.MspId == "" || signer.Subject == "" {
+			return shim.Error("signer msp_id and subject are required")
+		}
+		key := signer.MspId + "|" + signer.Subject
+		if seen[key] {
+			return shim.Error(fmt.Sprintf("duplicate signer %s", signer.Subject))
+		}
+		seen[key] = true
+	}
+
+	// 3：验证数据是否存在
+	user, err := getActiveUser(stub, userId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if user.Threshold > 0 {
+		if !isAdmin(stub) {
+			return shim.Error("permission denied: only admin can change signers")
+		}
+	} else if err := checkUserAccess(stub, user); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 4： 状态写入
+	user.Signers = signers
+	user.Threshold = threshold
+	if err := putUser(stub, user); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:   eventUserSignersChanged,
+		UserId: userId,
+		Amount: int64(threshold),
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 待签转让查询，包括所有表态
+func queryPendingTransfer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	pendingId := args[0]
+	if pendingId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	pending, err := getPendingTransfer(stub, pendingId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if pending.State == multisigPending && !now.Before(pending.Expiry) {
+		pending.State = multisigExpired
+	}
+
+	pendingBytes, err := json.Marshal(pending)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(pendingBytes)
+}
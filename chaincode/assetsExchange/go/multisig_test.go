@@ -0,0 +1,250 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPendingTransferCount(t *testing.T) {
+	owner := &User{
+		Id: "alice",
+		Signers: []*Signer{
+			{MspId: testMspId, Subject: "CN=s1"},
+			{MspId: testMspId2, Subject: "CN=s2"},
+			{MspId: testMspId, Subject: "CN=s3"},
+		},
+		Threshold: 2,
+	}
+	decision := func(mspId, subject, d string) *SignerDecision {
+		return &SignerDecision{MspId: mspId, Subject: subject, Decision: d}
+	}
+
+	tests := []struct {
+		name           string
+		decisions      []*SignerDecision
+		wantApprovals  int
+		wantRejections int
+	}{
+		{"none", nil, 0, 0},
+		{"one approval", []*SignerDecision{decision(testMspId, "CN=s1", decisionApprove)}, 1, 0},
+		{"approve and reject", []*SignerDecision{
+			decision(testMspId, "CN=s1", decisionApprove),
+			decision(testMspId2, "CN=s2", decisionReject),
+		}, 1, 1},
+		{"all approve", []*SignerDecision{
+			decision(testMspId, "CN=s1", decisionApprove),
+			decision(testMspId2, "CN=s2", decisionApprove),
+			decision(testMspId, "CN=s3", decisionApprove),
+		}, 3, 0},
+		// 签署人以 MSP ID + Subject 识别，其它组织的同名身份不计
+		{"same subject other org", []*SignerDecision{
+			decision(testMspId, "CN=s2", decisionApprove),
+			decision(testMspId2, "CN=s1", decisionReject),
+		}, 0, 0},
+		// 已被移出签署人集合的身份不计
+		{"removed signer", []*SignerDecision{
+			decision(testMspId, "CN=s4", decisionApprove),
+			decision(testMspId, "CN=s3", decisionReject),
+		}, 0, 1},
+	}
+	for _, tt := range tests {
+		approvals, rejections := (&PendingTransfer{Decisions: tt.decisions}).count(owner)
+		if approvals != tt.wantApprovals || rejections != tt.wantRejections {
+			t.Errorf("%s: got %d approvals %d rejections, want %d %d", tt.name, approvals, rejections, tt.wantApprovals, tt.wantRejections)
+		}
+	}
+}
+
+// alice 持有 a1，签署人 s1、s2、s3 中 2 个同意才能转出
+func newMultisigStub(t *testing.T) *testStub {
+	s := newTestStub(t)
+	s.defineClass()
+	s.registerUser(testMspId, "alice")
+	s.registerUser(testMspId, "bob")
+	s.enrollAsset("a1", "alice")
+	s.asUser(testMspId, "alice").mustInvoke("userSetSigners", "alice",
+		`[{"msp_id":"Org1MSP","subject":"CN=s1"},{"msp_id":"Org2MSP","subject":"CN=s2"},{"msp_id":"Org1MSP","subject":"CN=s3"}]`, "2")
+
+	return s
+}
+
+func TestUserSetSigners(t *testing.T) {
+	s := newMultisigStub(t)
+
+	tests := []struct {
+		signers   string
+		threshold string
+		wantErr   string
+	}{
+		{`[{"msp_id":"Org1MSP","subject":"CN=s1"}]`, "2", "invalid threshold"},
+		{`[{"msp_id":"Org1MSP","subject":"CN=s1"}]`, "0", "threshold must be between"},
+		{`[]`, "1", "invalid threshold"},
+		{`[{"msp_id":"Org1MSP","subject":"CN=s1"},{"msp_id":"Org1MSP","subject":"CN=s1"}]`, "1", "duplicate signer"},
+		{`[{"msp_id":"Org1MSP"}]`, "1", "msp_id and subject are required"},
+		{`{}`, "1", "invalid signers"},
+	}
+	for _, tt := range tests {
+		msg := s.asAdmin().mustFail("userSetSigners", "bob", tt.signers, tt.threshold)
+		if !strings.Contains(msg, tt.wantErr) {
+			t.Errorf("%s %s: got %q, want %q", tt.signers, tt.threshold, msg, tt.wantErr)
+		}
+	}
+
+	// 设置后本人不能修改或取消，只有管理员可以
+	msg := s.asUser(testMspId, "alice").mustFail("userSetSigners", "alice", "[]", "0")
+	if !strings.Contains(msg, "only admin can change signers") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+	s.asAdmin().mustInvoke("userSetSigners", "alice", "[]", "0")
+	s.asUser(testMspId, "alice").mustInvoke("assetExchange", "alice", "a1", "bob")
+}
+
+func TestMultisigTransfer(t *testing.T) {
+	s := newMultisigStub(t)
+
+	// 其它转出途径一律拒绝
+	later := s.now.Add(time.Hour).Format(time.RFC3339)
+	for _, call := range [][]string{
+		{"transferOffer", "alice", "a1", "bob", later},
+		{"listingCreate", "alice", "a1", "100", "", later},
+		{"auctionOpen", "alice", "a1", "100", later, s.now.Add(2 * time.Hour).Format(time.RFC3339)},
+	} {
+		msg := s.asUser(testMspId, "alice").mustFail(call[0], call[1:]...)
+		if !strings.Contains(msg, "requires 2 signer approvals") {
+			t.Errorf("%s: got %q", call[0], msg)
+		}
+	}
+
+	pendingId := string(s.asUser(testMspId, "alice").mustInvoke("assetExchange", "alice", "a1", "bob"))
+	if owner := s.getAsset("a1").Owner; owner != "alice" {
+		t.Fatalf("asset transferred before approval to %s", owner)
+	}
+	if evt := s.lastEvent(); evt.Type != eventTransferPending || evt.RefId != pendingId {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+
+	// 出让者本人和管理员都不能代为表态
+	for _, caller := range []*testStub{s.asUser(testMspId, "alice"), s.asAdmin(), s.asUser(testMspId2, "s1")} {
+		msg := caller.mustFail("multisigApprove", pendingId)
+		if !strings.Contains(msg, "caller is not a signer") {
+			t.Errorf("unexpected error: %s", msg)
+		}
+	}
+
+	s.asUser(testMspId, "s1").mustInvoke("multisigApprove", pendingId)
+	if evt := s.lastEvent(); evt.Type != eventTransferApproved {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+	msg := s.asUser(testMspId, "s1").mustFail("multisigApprove", pendingId)
+	if !strings.Contains(msg, "already decided") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+	if owner := s.getAsset("a1").Owner; owner != "alice" {
+		t.Fatalf("asset transferred below threshold to %s", owner)
+	}
+
+	s.asUser(testMspId2, "s2").mustInvoke("multisigApprove", pendingId)
+	if owner := s.getAsset("a1").Owner; owner != "bob" {
+		t.Fatalf("asset owner %s after threshold, want bob", owner)
+	}
+	if evt := s.lastEvent(); evt.Type != eventAssetTransferred || evt.RefId != pendingId {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+
+	// 发起、第一次同意各一条 multisig 记录，达到门限的同意记在转让记录中
+	histories := s.assetHistory("a1")
+	want := []struct {
+		action, decision string
+		approvals        int
+	}{
+		{historyEnroll, "", 0},
+		{historyMultisig, decisionPropose, 0},
+		{historyMultisig, decisionApprove, 1},
+		{historyExchange, decisionApprove, 2},
+	}
+	if len(histories) != len(want) {
+		t.Fatalf("got %d histories, want %d", len(histories), len(want))
+	}
+	for i, w := range want {
+		h := histories[i]
+		if h.Action != w.action {
+			t.Fatalf("history %d is %s, want %s", i, h.Action, w.action)
+		}
+		if w.decision == "" {
+			continue
+		}
+		if h.Multisig == nil || h.Multisig.Decision != w.decision || h.Multisig.Approvals != w.approvals || h.Multisig.PendingId != pendingId {
+			t.Fatalf("history %d: unexpected multisig %+v", i, h.Multisig)
+		}
+	}
+	s.asUser(testMspId, "s3").mustFail("multisigApprove", pendingId)
+}
+
+func TestMultisigRejected(t *testing.T) {
+	s := newMultisigStub(t)
+
+	// 签署人发起时同时记为同意
+	pendingId := string(s.asUser(testMspId, "s1").mustInvoke("assetExchange", "alice", "a1", "bob"))
+	s.asUser(testMspId2, "s2").mustInvoke("multisigReject", pendingId)
+	if evt := s.lastEvent(); evt.Type != eventTransferDeclined {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+	// 3 个签署人中 2 个拒绝后门限不可能达到
+	s.asUser(testMspId, "s3").mustInvoke("multisigReject", pendingId)
+	if evt := s.lastEvent(); evt.Type != eventMultisigRejected {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+	msg := s.asUser(testMspId, "s1").mustFail("multisigApprove", pendingId)
+	if !strings.Contains(msg, "pending transfer is rejected") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+	if owner := s.getAsset("a1").Owner; owner != "alice" {
+		t.Fatalf("asset owner %s, want alice", owner)
+	}
+}
+
+func TestMultisigExpire(t *testing.T) {
+	s := newMultisigStub(t)
+
+	pendingId := string(s.asUser(testMspId, "alice").mustInvoke("assetExchange", "alice", "a1", "bob", s.now.Add(time.Hour).Format(time.RFC3339)))
+	s.asUser(testMspId, "alice").mustFail("multisigExpire", pendingId)
+	s.now = s.now.Add(time.Hour)
+
+	msg := s.asUser(testMspId, "s1").mustFail("multisigApprove", pendingId)
+	if !strings.Contains(msg, "expired") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+	s.asUser(testMspId, "bob").mustFail("multisigExpire", pendingId)
+	s.asUser(testMspId, "s3").mustInvoke("multisigExpire", pendingId)
+	if evt := s.lastEvent(); evt.Type != eventTransferExpired {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+	histories := s.assetHistory("a1")
+	if last := histories[len(histories)-1]; last.Multisig == nil || last.Multisig.Decision != decisionExpire || last.Multisig.State != multisigExpired {
+		t.Fatalf("unexpected history %+v", last.Multisig)
+	}
+}
+
+func TestMultisigProposeChecksRecipient(t *testing.T) {
+	s := newMultisigStub(t)
+	s.asUser(testMspId, "carol").mustInvoke("userRegister", "Carol", "carol")
+	s.registerUser(testMspId, "dave")
+	s.asUser(testMspId, "dave").mustInvoke("userDestroy", "dave")
+
+	tests := []struct {
+		recipient string
+		wantErr   string
+	}{
+		{"carol", "kyc status is pending"},
+		{"dave", "user dave closed"},
+		{"nobody", "not found"},
+		{"alice", "cannot transfer asset to its owner"},
+	}
+	for _, tt := range tests {
+		msg := s.asUser(testMspId, "alice").mustFail("assetExchange", "alice", "a1", tt.recipient)
+		if !strings.Contains(msg, tt.wantErr) {
+			t.Errorf("%s: got %q, want %q", tt.recipient, msg, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+// 资产拥有者索引：owner~assetId 组合键，每项资产一个键
+// 登记和转让资产时只写资产和索引，不再改写整个用户对象，持有大量资产的用户不会成为读写冲突的热点
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	ownerIndex = "owner~assetId"
+)
+
+// 组合键的值不需要内容，但 PutState 不接受空值
+var indexValue = []byte{0x00}
+
+// 写入拥有者索引，索引键的修改需要拥有者组织背书
+func putOwnerIndex(stub shim.ChaincodeStubInterface, owner *User, assetId string) error {
+	indexKey, err := stub.CreateCompositeKey(ownerIndex, []string{owner.Id, assetId})
+	if err != nil {
+		return fmt.Errorf("create key error: %s", err)
+	}
+	if err := stub.PutState(indexKey, indexValue); err != nil {
+		return fmt.Errorf("save owner index error: %s", err)
+	}
+
+	return setKeyEndorsement(stub, indexKey, owner.MspId)
+}
+
+// 删除拥有者索引
+func delOwnerIndex(stub shim.ChaincodeStubInterface, ownerId, assetId string) error {
+	indexKey, err := stub.CreateCompositeKey(ownerIndex, []string{ownerId, assetId})
+	if err != nil {
+		return fmt.Errorf("create key error: %s", err)
+	}
+	if err := stub.DelState(indexKey); err != nil {
+		return fmt.Errorf("delete owner index error: %s", err)
+	}
+
+	return nil
+}
+
+// 扫描拥有者索引，得到用户名下的资产 id 列表
+func getOwnerAssetIds(stub shim.ChaincodeStubInterface, ownerId string) ([]string, error) {
+	result, err := stub.GetStateByPartialCompositeKey(ownerIndex, []string{ownerId})
+	if err != nil {
+		return nil, fmt.Errorf("query owner index error: %s", err)
+	}
+	defer result.Close()
+
+	assetIds := make([]string, 0)
+	for result.HasNext() {
+		indexVal, err := result.Next()
+		if err != nil {
+			return nil, fmt.Errorf("query error: %s", err)
+		}
+
+		_, keys, err := stub.SplitCompositeKey(indexVal.GetKey())
+		if err != nil {
+			return nil, fmt.Errorf("split key error: %s", err)
+		}
+		assetIds = append(assetIds, keys[1])
+	}
+
+	return assetIds, nil
+}
+
+// 校验用户确实整体拥有该资产
+func checkAssetOwner(stub shim.ChaincodeStubInterface, userId, assetId string) error {
+	asset, err := getAsset(stub, assetId)
+	if err != nil {
+		return err
+	}
+	if asset.Owner != userId {
+		return fmt.Errorf("asset owner not match")
+	}
+	if err := checkWholeAsset(asset); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// 一次性迁移：把旧版本账本中 User.Assets 里的资产 id 写入 owner~assetId 索引和 Asset.Owner，并清空 User.Assets
+// 参数为要迁移的用户 id 列表，不传时迁移全部用户。用户很多时可分批传入，避免单个交易的读写集过大
+// 已经迁移过的用户 Assets 为空，重复执行没有影响
+func migrateOwnerIndex(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if !isAdmin(stub) {
+		return shim.Error("permission denied: admin only")
+	}
+
+	userIds := args
+	if len(userIds) == 0 {
+		// 组合键以 0x00 开头，不会落在 user_ 的范围内
+		result, err := stub.GetStateByRange(constructUserKey(""), userKeyRangeEnd)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("query users error: %s", err))
+		}
+		defer result.Close()
+
+		for result.HasNext() {
+			userVal, err := result.Next()
+			if err != nil {
+				return shim.Error(fmt.Sprintf("query error: %s", err))
+			}
+			userIds = append(userIds, userVal.GetKey()[len(constructUserKey("")):])
+		}
+	}
+
+	migrated := 0
+	for _, userId := range userIds {
+		user, err := getUser(stub, userId)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s: %s", userId, err))
+		}
+		if len(user.Assets) == 0 {
+			continue
+		}
+
+		for _, assetId := range user.Assets {
+			asset, err := getAsset(stub, assetId)
+			if err != nil {
+				return shim.Error(fmt.Sprintf("%s: %s", assetId, err))
+			}
+			if asset.DocType == "" {
+				asset.DocType = assetDocType
+			}
+			asset.Owner = userId
+			if err := putAsset(stub, asset); err != nil {
+				return shim.Error(err.Error())
+			}
+			if err := putOwnerIndex(stub, user, assetId); err != nil {
+				return shim.Error(err.Error())
+			}
+		}
+
+		// putUser 不写入 Assets
+		if err := putUser(stub, user); err != nil {
+			return shim.Error(err.Error())
+		}
+		migrated++
+	}
+
+	return shim.Success([]byte(fmt.Sprintf("%d users migrated", migrated)))
+}
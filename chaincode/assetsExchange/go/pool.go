@@ -0,0 +1,287 @@
+package main
+
+// 资产池：把同一用户名下的多项资产打包成一个资产池整体出售
+// 资产池本身也是一项资产，类别为 pool，Members 为池中的资产 id，池中的资产以 PoolId 指向资产池
+// 资产池经由 transferAssetForPrice 转让，转让、要约、互换、挂牌、拍卖都适用，池中的资产随之一起转让，
+// 每项资产都写入一条带 PoolId 的转让记录。池中的资产不能单独处置
+// 拆包后资产池被删除，池中的资产恢复为独立资产。资产池的变更记录保留，仍可通过 queryAssetHistory 查询，
+// 删除前的资产池在账本历史中查询。资产池 id 不能再用于登记或打包，见 checkAssetIdUnused
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	// 资产池的类别 id，保留，不能用 assetClassDefine 定义
+	poolClassId = "pool"
+
+	// 一个资产池最多包含的资产数，避免单个交易的读写集过大
+	maxPoolMembers = 200
+)
+
+// 池中的资产随资产池一起转让，在 checkAssetTransfer 的校验全部通过之后调用
+// 池中资产的拥有者、冻结和质权已在 checkAssetTransfer 中校验，这里消耗质权同意，更新拥有者、索引和保密部分，并写入带 PoolId 的转让记录
+func transferPoolMembers(stub shim.ChaincodeStubInterface, pool *Asset, owner, currentOwner *User) error {
+	for _, memberId := range pool.Members {
+		member, err := getAsset(stub, memberId)
+		if err != nil {
+			return fmt.Errorf("pool member %s: %s", memberId, err)
+		}
+		if err := consumeLienApprovals(stub, memberId, currentOwner.Id); err != nil {
+			return err
+		}
+
+		if err := moveAssetPrivate(stub, member, currentOwner.MspId); err != nil {
+			return err
+		}
+		member.Owner = currentOwner.Id
+		if err := putAsset(stub, member); err != nil {
+			return err
+		}
+		if currentOwner.MspId != owner.MspId {
+			if err := setAssetEndorsement(stub, memberId, currentOwner.MspId); err != nil {
+				return err
+			}
+		}
+		if err := delOwnerIndex(stub, owner.Id, memberId); err != nil {
+			return err
+		}
+		if err := putOwnerIndex(stub, currentOwner, memberId); err != nil {
+			return err
+		}
+		if err := putAssetHistory(stub, &AssetHistory{
+			AssetId:        memberId,
+			OriginOwnerId:  owner.Id,
+			CurrentOwnerId: currentOwner.Id,
+			Action:         historyExchange,
+			PoolId:         pool.Id,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 打包资产，池中的资产写入带 PoolId 的打包记录
+func poolBundle(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：拥有者 id、资产池 id、资产池名称、资产 id 列表（JSON 数组）
+	if len(args) != 4 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	ownerId := args[0]
+	poolId := args[1]
+	poolName := args[2]
+	if ownerId == "" || poolId == "" || poolName == "" {
+		return shim.Error("invalid args")
+	}
+	var memberIds []string
+	if err := json.Unmarshal([]byte(args[3]), &memberIds); err != nil {
+		return shim.Error(fmt.Sprintf("invalid asset ids: %s", err))
+	}
+	if len(memberIds) < 2 || len(memberIds) > maxPoolMembers {
+		return shim.Error(fmt.Sprintf("a pool holds 2 to %d assets", maxPoolMembers))
+	}
+	seen := make(map[string]bool)
+	for _, memberId := range memberIds {
+		if memberId == "" || memberId == poolId || seen[memberId] {
+			return shim.Error(fmt.Sprintf("invalid asset id: %q", memberId))
+		}
+		seen[memberId] = true
+	}
+
+	// 3：验证数据是否存在
+	owner, err := getUserWithAccess(stub, ownerId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkUserNotFrozen(stub, owner); err != nil {
+		return errorResponse(err)
+	}
+	if err := checkAssetIdUnused(stub, poolId); err != nil {
+		return shim.Error(err.Error())
+	}
+	members := make([]*Asset, 0, len(memberIds))
+	for _, memberId := range memberIds {
+		member, err := getAsset(stub, memberId)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s: %s", memberId, err))
+		}
+		if member.Owner != ownerId {
+			return shim.Error(fmt.Sprintf("%s: asset owner not match", memberId))
+		}
+		// 份额化资产、已在池中的资产不能打包，资产池不能嵌套
+		if err := checkWholeAsset(member); err != nil {
+			return shim.Error(err.Error())
+		}
+		if len(member.Members) != 0 {
+			return shim.Error(fmt.Sprintf("%s: pools cannot be nested", memberId))
+		}
+		if err := checkNotFrozen(stub, member, owner); err != nil {
+			return errorResponse(err)
+		}
+		if err := checkNotAuctioned(stub, memberId, ""); err != nil {
+			return shim.Error(err.Error())
+		}
+		members = append(members, member)
+	}
+
+	// 4： 状态写入
+	// 1. 写入资产池和拥有者索引 2. 标记池中的资产并撤下其挂牌 3. 写入变更记录
+	pool := &Asset{
+		DocType:    assetDocType,
+		Name:       poolName,
+		Id:         poolId,
+		ClassId:    poolClassId,
+		Owner:      ownerId,
+		Members:    memberIds,
+		Attributes: map[string]interface{}{},
+	}
+	if err := putAsset(stub, pool); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := setAssetEndorsement(stub, poolId, owner.MspId); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putOwnerIndex(stub, owner, poolId); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putAssetHistory(stub, &AssetHistory{
+		AssetId:        poolId,
+		OriginOwnerId:  originOwner,
+		CurrentOwnerId: ownerId,
+		Action:         historyBundle,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+	for _, member := range members {
+		if err := withdrawAssetListing(stub, member.Id); err != nil {
+			return shim.Error(err.Error())
+		}
+		member.PoolId = poolId
+		if err := putAsset(stub, member); err != nil {
+			return shim.Error(err.Error())
+		}
+		if err := putAssetHistory(stub, &AssetHistory{
+			AssetId:        member.Id,
+			OriginOwnerId:  ownerId,
+			CurrentOwnerId: ownerId,
+			Action:         historyBundle,
+			PoolId:         poolId,
+		}); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:     eventAssetsBundled,
+		AssetId:  poolId,
+		From:     ownerId,
+		AssetIds: memberIds,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 拆包资产池，资产池被删除，变更记录保留，池中的资产写入拆包记录
+func poolUnbundle(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：拥有者 id、资产池 id
+	if len(args) != 2 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	ownerId := args[0]
+	poolId := args[1]
+	if ownerId == "" || poolId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	owner, err := getUserWithAccess(stub, ownerId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	pool, err := getAsset(stub, poolId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(pool.Members) == 0 {
+		return shim.Error(fmt.Sprintf("asset %s is not a pool", poolId))
+	}
+	if pool.Owner != ownerId {
+		return shim.Error("asset owner not match")
+	}
+	if err := checkNotFrozen(stub, pool, owner); err != nil {
+		return errorResponse(err)
+	}
+	if err := checkNotAuctioned(stub, poolId, ""); err != nil {
+		return shim.Error(err.Error())
+	}
+	// 资产池上的质权以整个资产池为担保，解除前不能拆包
+	liens, err := getAssetLiens(stub, poolId, true)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(liens) != 0 {
+		return shim.Error(fmt.Sprintf("pool %s has %d open liens", poolId, len(liens)))
+	}
+
+	// 4： 状态写入
+	// 1. 恢复池中的资产 2. 撤下资产池的挂牌，删除资产池和拥有者索引 3. 写入变更记录
+	for _, memberId := range pool.Members {
+		member, err := getAsset(stub, memberId)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s: %s", memberId, err))
+		}
+		member.PoolId = ""
+		if err := putAsset(stub, member); err != nil {
+			return shim.Error(err.Error())
+		}
+		if err := putAssetHistory(stub, &AssetHistory{
+			AssetId:        memberId,
+			OriginOwnerId:  ownerId,
+			CurrentOwnerId: ownerId,
+			Action:         historyUnbundle,
+			PoolId:         poolId,
+		}); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	if err := withdrawAssetListing(stub, poolId); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putAssetHistory(stub, &AssetHistory{
+		AssetId:        poolId,
+		OriginOwnerId:  ownerId,
+		CurrentOwnerId: ownerId,
+		Action:         historyUnbundle,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := stub.DelState(constructAssetKey(poolId)); err != nil {
+		return shim.Error(fmt.Sprintf("delete pool error: %s", err))
+	}
+	if err := delOwnerIndex(stub, ownerId, poolId); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:     eventAssetsUnbundled,
+		AssetId:  poolId,
+		From:     ownerId,
+		AssetIds: pool.Members,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPoolUnbundleKeepsHistory(t *testing.T) {
+	s := newTestStub(t)
+	s.defineClass()
+	s.registerUser(testMspId, "alice")
+	s.registerUser(testMspId, "bob")
+	s.enrollAsset("a1", "alice")
+	s.enrollAsset("a2", "alice")
+
+	s.asUser(testMspId, "alice").mustInvoke("poolBundle", "alice", "p1", "Pool 1", `["a1","a2"]`)
+	s.asUser(testMspId, "alice").mustFail("assetExchange", "alice", "a1", "bob")
+	s.asUser(testMspId, "alice").mustInvoke("assetExchange", "alice", "p1", "bob")
+	if owner := s.getAsset("a2").Owner; owner != "bob" {
+		t.Fatalf("pool member owner %s, want bob", owner)
+	}
+
+	s.asUser(testMspId, "alice").mustFail("poolUnbundle", "alice", "p1")
+	s.asUser(testMspId, "bob").mustInvoke("poolUnbundle", "bob", "p1")
+	s.mustFail("queryAsset", "p1")
+	if a1 := s.getAsset("a1"); a1.PoolId != "" || a1.Owner != "bob" {
+		t.Fatalf("unexpected member %+v", a1)
+	}
+
+	// 资产记录删除后变更记录仍然可以查询
+	histories := s.assetHistory("p1")
+	actions := []string{historyBundle, historyExchange, historyUnbundle}
+	if len(histories) != len(actions) {
+		t.Fatalf("got %d pool histories, want %d", len(histories), len(actions))
+	}
+	for i, action := range actions {
+		if histories[i].Action != action || histories[i].Seq != uint64(i+1) {
+			t.Fatalf("history %d is %s seq %d, want %s", i, histories[i].Action, histories[i].Seq, action)
+		}
+	}
+	s.mustFail("queryAssetHistory", "nosuch")
+
+	// 资产池 id 不能再用于登记或打包
+	msg := s.asAdmin().mustFail("assetEnroll", "p1", "p1", testClass, `{"principal":100}`, "bob")
+	if !strings.Contains(msg, "asset id p1 already used") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+	msg = s.asUser(testMspId, "bob").mustFail("poolBundle", "bob", "p1", "Pool 1", `["a1","a2"]`)
+	if !strings.Contains(msg, "asset id p1 already used") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+	s.asUser(testMspId, "bob").mustInvoke("poolBundle", "bob", "p2", "Pool 2", `["a1","a2"]`)
+	if histories := s.assetHistory("p2"); len(histories) != 1 || histories[0].Seq != 1 {
+		t.Fatalf("unexpected new pool histories %+v", histories)
+	}
+}
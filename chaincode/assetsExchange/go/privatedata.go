@@ -0,0 +1,196 @@
+package main
+
+// 资产的保密部分：债务人身份、未偿余额、评估价值等只在买卖双方组织之间共享，不写入通道的公共账本
+// 每个组织一个私有数据集合 assetPrivate<MSPID>，定义见 collections_config.json
+// 保密部分存放在资产拥有者所在组织的集合中，跨组织转让时复制到受让者组织的集合，并从出让者组织的集合中删除
+// 公共账本上的资产只记录所在集合和保密部分的 SHA-256，交易双方可以据此核对
+// 集合只允许成员组织读取，跨组织转让的交易由各组织的节点背书，非成员组织的节点读不到出让者组织的集合，
+// 因此转让时不从集合中读取：出让者在链下把保密部分交给受让者，受让者按账本上的哈希核对后，
+// 由提交转让交易的一方通过 transient map 传入（键为 asset_private_<资产id>），链码同样按哈希核对后写入受让者组织的集合
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	// 资产登记时通过 transient map 传入保密部分，transient 数据不会写入区块
+	assetPrivateTransientKey = "asset_private"
+)
+
+// 跨组织转让时传入保密部分的 transient key，资产池转让和互换涉及多项资产，按资产 id 区分
+func constructPrivateTransientKey(assetId string) string {
+	return fmt.Sprintf("%s_%s", assetPrivateTransientKey, assetId)
+}
+
+// AssetPrivate 资产的保密部分
+type AssetPrivate struct {
+	AssetId            string                 `json:"asset_id"`
+	DebtorName         string                 `json:"debtor_name"`               // 债务人名称
+	DebtorId           string                 `json:"debtor_id"`                 // 债务人证件号或统一社会信用代码
+	OutstandingBalance json.Number            `json:"outstanding_balance"`       // 未偿余额
+	AppraisalValue     json.Number            `json:"appraisal_value,omitempty"` // 评估价值
+	AppraisalDate      string                 `json:"appraisal_date,omitempty"`  // 评估日期 2006-01-02
+	Terms              map[string]interface{} `json:"terms,omitempty"`           // 其它保密条款
+}
+
+// 组织的私有数据集合名称
+func privateCollection(mspId string) string {
+	return fmt.Sprintf("assetPrivate%s", mspId)
+}
+
+// 从 transient map 中读取保密部分，没有传入时返回 nil
+func getTransientAssetPrivate(stub shim.ChaincodeStubInterface, assetId string) (*AssetPrivate, error) {
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("get transient error: %s", err)
+	}
+	privBytes, ok := transient[assetPrivateTransientKey]
+	if !ok || len(privBytes) == 0 {
+		return nil, nil
+	}
+
+	priv := new(AssetPrivate)
+	if err := json.Unmarshal(privBytes, priv); err != nil {
+		return nil, fmt.Errorf("invalid asset private data: %s", err)
+	}
+	if priv.AssetId != "" && priv.AssetId != assetId {
+		return nil, fmt.Errorf("asset private data does not match asset %s", assetId)
+	}
+	if priv.DebtorName == "" || priv.OutstandingBalance == "" {
+		return nil, fmt.Errorf("asset private data requires debtor_name and outstanding_balance")
+	}
+	if _, err := priv.OutstandingBalance.Float64(); err != nil {
+		return nil, fmt.Errorf("invalid outstanding_balance: %s", priv.OutstandingBalance)
+	}
+	if priv.AppraisalValue != "" {
+		if _, err := priv.AppraisalValue.Float64(); err != nil {
+			return nil, fmt.Errorf("invalid appraisal_value: %s", priv.AppraisalValue)
+		}
+	}
+	priv.AssetId = assetId
+
+	return priv, nil
+}
+
+// 把保密部分写入组织的集合，并在资产上记录集合和哈希，调用方负责写回资产
+func putAssetPrivate(stub shim.ChaincodeStubInterface, asset *Asset, mspId string, priv *AssetPrivate) error {
+	privBytes, err := json.Marshal(priv)
+	if err != nil {
+		return fmt.Errorf("marshal asset private data error: %s", err)
+	}
+
+	return putAssetPrivateBytes(stub, asset, privateCollection(mspId), privBytes)
+}
+
+func putAssetPrivateBytes(stub shim.ChaincodeStubInterface, asset *Asset, collection string, privBytes []byte) error {
+	if err := stub.PutPrivateData(collection, constructAssetKey(asset.Id), privBytes); err != nil {
+		return fmt.Errorf("save asset private data error: %s", err)
+	}
+
+	hash := sha256.Sum256(privBytes)
+	asset.PrivateCollection = collection
+	asset.PrivateHash = hex.EncodeToString(hash[:])
+
+	return nil
+}
+
+// 读取资产的保密部分，只有集合成员组织的节点上才有数据
+func getAssetPrivateBytes(stub shim.ChaincodeStubInterface, asset *Asset) ([]byte, error) {
+	if asset.PrivateCollection == "" {
+		return nil, fmt.Errorf("asset has no private data")
+	}
+
+	privBytes, err := stub.GetPrivateData(asset.PrivateCollection, constructAssetKey(asset.Id))
+	if err != nil {
+		return nil, fmt.Errorf("get asset private data error: %s", err)
+	}
+	if len(privBytes) == 0 {
+		return nil, fmt.Errorf("asset private data not available on this peer, endorse with a peer of the owner organization")
+	}
+
+	return privBytes, nil
+}
+
+// 读取转让交易通过 transient map 传入的保密部分，并按资产上记录的哈希核对
+func getTransientPrivateBytes(stub shim.ChaincodeStubInterface, asset *Asset) ([]byte, error) {
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("get transient error: %s", err)
+	}
+	key := constructPrivateTransientKey(asset.Id)
+	privBytes, ok := transient[key]
+	if !ok || len(privBytes) == 0 {
+		return nil, fmt.Errorf("asset %s private data is required in transient %s for cross-organization transfer", asset.Id, key)
+	}
+	hash := sha256.Sum256(privBytes)
+	if hex.EncodeToString(hash[:]) != asset.PrivateHash {
+		return nil, fmt.Errorf("asset %s private data does not match the hash on ledger", asset.Id)
+	}
+
+	return privBytes, nil
+}
+
+// 资产转给其它组织的用户时，保密部分随之移到受让者组织的集合，调用方负责写回资产
+// 保密部分由交易的 transient map 传入，不读取出让者组织的集合，任何组织的节点都可以背书
+func moveAssetPrivate(stub shim.ChaincodeStubInterface, asset *Asset, toMspId string) error {
+	if asset.PrivateCollection == "" {
+		return nil
+	}
+	toCollection := privateCollection(toMspId)
+	if toCollection == asset.PrivateCollection {
+		return nil
+	}
+	if toMspId == "" {
+		return fmt.Errorf("recipient has no bound organization, cannot receive asset private data")
+	}
+
+	privBytes, err := getTransientPrivateBytes(stub, asset)
+	if err != nil {
+		return err
+	}
+	if err := stub.DelPrivateData(asset.PrivateCollection, constructAssetKey(asset.Id)); err != nil {
+		return fmt.Errorf("delete asset private data error: %s", err)
+	}
+
+	return putAssetPrivateBytes(stub, asset, toCollection, privBytes)
+}
+
+// 资产保密部分查询，只有资产拥有者本人或管理员可以查询，且需要向集合成员组织的节点查询
+func queryAssetPrivate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	assetId := args[0]
+	if assetId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	asset, err := getAsset(stub, assetId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	owner, err := getUser(stub, asset.Owner)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkUserAccess(stub, owner); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	privBytes, err := getAssetPrivateBytes(stub, asset)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(privBytes)
+}
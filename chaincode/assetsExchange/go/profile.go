@@ -0,0 +1,329 @@
+package main
+
+// 用户资料和 KYC（了解你的客户）状态
+// 开户时 KYC 状态为 pending，开户员或管理员核验线下材料后改为 verified，只有 verified 的用户可以接收资产：
+// 登记资产、转让（含要约、互换、挂牌、拍卖、多签、资产池）、份额转让和销户承接都会校验接收者
+// 链上只保存 KYC 材料的 SHA-256，材料本身存放在链下。本人更换材料后 KYC 状态回到 pending，需要重新核验
+// suspended 为暂停，不能接收资产，可以恢复为 verified；销户时改为 closed，之后不再变化
+// 本功能上线前开户的用户没有资料，视为 individual + pending，由开户员核验后才能接收资产
+// 每次资料变更写入一条用户资料变更记录，组合键 profileHistory~用户id~序号；用户的冻结和解冻也写入这里，记录带冻结信息
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	// 用户类型
+	userTypeIndividual = "individual"
+	userTypeEnterprise = "enterprise"
+
+	// KYC 状态
+	kycPending   = "pending"
+	kycVerified  = "verified"
+	kycSuspended = "suspended"
+	kycClosed    = "closed"
+
+	// 用户资料变更记录的类型
+	profileRegister = "register"
+	profileUpdate   = "update"
+	profileClose    = "close"
+	profileFreeze   = "freeze"
+	profileUnfreeze = "unfreeze"
+
+	profileHistoryObjectType = "profileHistory"
+)
+
+// UserProfile 用户资料
+type UserProfile struct {
+	Type        string     `json:"type"`                   // individual / enterprise
+	KycStatus   string     `json:"kyc_status"`             // pending / verified / suspended / closed
+	KycDocHash  string     `json:"kyc_doc_hash,omitempty"` // 链下 KYC 材料的 SHA-256，十六进制
+	SubmittedAt *time.Time `json:"submitted_at,omitempty"` // 最近一次提交 KYC 材料的时间
+	VerifiedAt  *time.Time `json:"verified_at,omitempty"`  // 最近一次核验通过的时间
+	VerifiedBy  string     `json:"verified_by,omitempty"`  // 核验者证书的 Subject
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// UserProfileHistory 用户资料变更记录，Profile 为变更后的资料
+type UserProfileHistory struct {
+	UserId         string       `json:"user_id"`
+	Seq            uint64       `json:"seq"`
+	Action         string       `json:"action"` // register / update / close / freeze / unfreeze
+	Profile        *UserProfile `json:"profile"`
+	Note           string       `json:"note,omitempty"`
+	Freeze         *Freeze      `json:"freeze,omitempty"` // 冻结和解冻记录的冻结信息
+	UpdatedByMspId string       `json:"updated_by_msp_id"`
+	UpdatedBy      string       `json:"updated_by"`
+	TxId           string       `json:"tx_id"`
+	Timestamp      time.Time    `json:"timestamp"`
+}
+
+// 以 profileseq_ 开头的，记录用户最新的资料变更记录序号
+func constructProfileSeqKey(userId string) string {
+	return fmt.Sprintf("profileseq_%s", userId)
+}
+
+// 用户资料，旧版本的用户没有资料，视为 individual + pending
+func (u *User) profile() *UserProfile {
+	if u.Profile != nil {
+		return u.Profile
+	}
+	return &UserProfile{Type: userTypeIndividual, KycStatus: kycPending}
+}
+
+// 接收资产的用户必须通过 KYC 核验
+func checkUserVerified(user *User) error {
+	if status := user.profile().KycStatus; status != kycVerified {
+		return fmt.Errorf("user %s kyc status is %s, only verified users can receive assets", user.Id, status)
+	}
+
+	return nil
+}
+
+// 新开户用户的资料
+func newUserProfile(stub shim.ChaincodeStubInterface, userType string) (*UserProfile, error) {
+	now, err := getTxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserProfile{Type: userType, KycStatus: kycPending, UpdatedAt: now}, nil
+}
+
+// 写入一条用户资料变更记录，与 putAssetHistory 相同，同一交易内对同一用户只能写入一条
+func putProfileHistory(stub shim.ChaincodeStubInterface, user *User, action, note string) error {
+	return putUserHistory(stub, &UserProfileHistory{
+		UserId:  user.Id,
+		Action:  action,
+		Profile: user.profile(),
+		Note:    note,
+	})
+}
+
+// 写入一条用户冻结或解冻记录
+func putFreezeHistory(stub shim.ChaincodeStubInterface, user *User, action string, freeze *Freeze) error {
+	return putUserHistory(stub, &UserProfileHistory{
+		UserId:  user.Id,
+		Action:  action,
+		Profile: user.profile(),
+		Freeze:  freeze,
+	})
+}
+
+// 序号、操作者、交易 id、时间戳在这里填充
+func putUserHistory(stub shim.ChaincodeStubInterface, history *UserProfileHistory) error {
+	seq, err := getInt64State(stub, constructProfileSeqKey(history.UserId))
+	if err != nil {
+		return err
+	}
+	seq++
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+	mspId, subject, err := getCallerIdentity(stub)
+	if err != nil {
+		return err
+	}
+
+	history.Seq = uint64(seq)
+	history.UpdatedByMspId = mspId
+	history.UpdatedBy = subject
+	history.TxId = stub.GetTxID()
+	history.Timestamp = txTime
+
+	historyBytes, err := json.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("marshal profile history error: %s", err)
+	}
+	historyKey, err := stub.CreateCompositeKey(profileHistoryObjectType, []string{
+		history.UserId,
+		fmt.Sprintf(historySeqFormat, seq),
+	})
+	if err != nil {
+		return fmt.Errorf("create key error: %s", err)
+	}
+
+	if err := stub.PutState(historyKey, historyBytes); err != nil {
+		return fmt.Errorf("save profile history error: %s", err)
+	}
+	if err := stub.PutState(constructProfileSeqKey(history.UserId), []byte(strconv.FormatInt(seq, 10))); err != nil {
+		return fmt.Errorf("save profile seq error: %s", err)
+	}
+
+	return nil
+}
+
+// 修改用户资料
+// 本人可以修改用户类型和 KYC 材料哈希；开户员、管理员还可以修改 KYC 状态，监管方可以暂停和恢复
+// 参数为空表示不修改，closed 只能由销户设置
+func updateUserProfile(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：用户 id、用户类型、KYC 状态、KYC 材料哈希、备注
+	if len(args) != 5 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	userId := args[0]
+	userType := args[1]
+	kycStatus := args[2]
+	docHash := args[3]
+	note := args[4]
+	if userId == "" || (userType == "" && kycStatus == "" && docHash == "") {
+		return shim.Error("invalid args")
+	}
+	if userType != "" && userType != userTypeIndividual && userType != userTypeEnterprise {
+		return shim.Error(fmt.Sprintf("unknown user type: %s", userType))
+	}
+	if kycStatus != "" && kycStatus != kycPending && kycStatus != kycVerified && kycStatus != kycSuspended {
+		return shim.Error(fmt.Sprintf("invalid kyc status: %s", kycStatus))
+	}
+	if docHash != "" {
+		if hashBytes, err := hex.DecodeString(docHash); err != nil || len(hashBytes) != sha256.Size {
+			return shim.Error("invalid kyc doc hash, expect hex encoded sha256")
+		}
+	}
+
+	// 3：验证数据是否存在
+	// 开户员、管理员可以修改任何用户的资料；监管方只能修改 KYC 状态；其余只能修改本人的资料
+	reviewer := isAdmin(stub) || callerHasRole(stub, roleRegistrar)
+	regulator := isRegulator(stub)
+	user, err := getActiveUser(stub, userId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !reviewer {
+		if regulator && userType == "" && docHash == "" {
+			if kycStatus != kycSuspended && kycStatus != kycVerified {
+				return shim.Error("permission denied: regulator can only suspend or restore kyc")
+			}
+		} else {
+			if kycStatus != "" {
+				return shim.Error("permission denied: kyc status can only be changed by registrar")
+			}
+			if err := checkUserAccess(stub, user); err != nil {
+				return shim.Error(err.Error())
+			}
+		}
+	}
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	_, subject, err := getCallerIdentity(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	profile := *user.profile()
+	if userType != "" {
+		profile.Type = userType
+	}
+	if docHash != "" && docHash != profile.KycDocHash {
+		profile.KycDocHash = docHash
+		profile.SubmittedAt = &now
+		// 更换材料后需要重新核验，开户员同时核验时以传入的状态为准
+		if kycStatus == "" && profile.KycStatus == kycVerified {
+			profile.KycStatus = kycPending
+		}
+	}
+	if kycStatus != "" {
+		if kycStatus == kycVerified {
+			if profile.KycDocHash == "" {
+				return shim.Error("kyc doc hash is required before verification")
+			}
+			// 监管方只能恢复被暂停的用户，不能核验新用户
+			if !reviewer && profile.KycStatus != kycSuspended {
+				return shim.Error("permission denied: only suspended users can be restored by regulator")
+			}
+			if profile.KycStatus != kycVerified {
+				profile.VerifiedAt = &now
+				profile.VerifiedBy = subject
+			}
+		}
+		profile.KycStatus = kycStatus
+	}
+
+	// 4： 状态写入
+	profile.UpdatedAt = now
+	user.Profile = &profile
+	if err := putUser(stub, user); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putProfileHistory(stub, user, profileUpdate, note); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:   eventUserProfileUpdated,
+		UserId: userId,
+		Reason: profile.KycStatus,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 用户资料变更记录查询，按时间顺序分页
+func queryUserProfileHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数，可以有1到3个：用户 id、分页大小、bookmark
+	if len(args) < 1 || len(args) > 3 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	userId := args[0]
+	if userId == "" {
+		return shim.Error("invalid args")
+	}
+	pageSize, bookmark, err := parsePageArgs(args[1:])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 3：验证数据是否存在
+	if _, err := getUser(stub, userId); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	result, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(profileHistoryObjectType, []string{userId}, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("query profile history error: %s", err))
+	}
+	defer result.Close()
+
+	histories := make([]*UserProfileHistory, 0)
+	for result.HasNext() {
+		historyVal, err := result.Next()
+		if err != nil {
+			return shim.Error(fmt.Sprintf("query error: %s", err))
+		}
+
+		history := new(UserProfileHistory)
+		if err := json.Unmarshal(historyVal.GetValue(), history); err != nil {
+			return shim.Error(fmt.Sprintf("unmarshal error: %s", err))
+		}
+		histories = append(histories, history)
+	}
+
+	historiesBytes, err := json.Marshal(&PageResult{
+		Records:  histories,
+		Count:    metadata.GetFetchedRecordsCount(),
+		Bookmark: metadata.GetBookmark(),
+	})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(historiesBytes)
+}
@@ -0,0 +1,276 @@
+package main
+
+// 资产富查询：需要使用 CouchDB 作为状态数据库（docker-compose-couch.yaml）
+// 索引定义在 META-INF/statedb/couchdb/indexes 目录下，随链码一起打包安装
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 200
+)
+
+// AssetQuery 资产查询条件，各条件之间为"且"的关系
+type AssetQuery struct {
+	Owner      string                     `json:"owner"`      // 拥有者 id
+	Name       string                     `json:"name"`       // 资产名字，精确匹配
+	ClassId    string                     `json:"class_id"`   // 资产类别
+	Attributes map[string]*AttributeRange `json:"attributes"` // 属性条件，key 为属性名
+}
+
+// AttributeRange 属性条件，number 类型传数字，date 类型传同格式的字符串
+type AttributeRange struct {
+	Eq  interface{} `json:"eq,omitempty"`
+	Gt  interface{} `json:"gt,omitempty"`
+	Gte interface{} `json:"gte,omitempty"`
+	Lt  interface{} `json:"lt,omitempty"`
+	Lte interface{} `json:"lte,omitempty"`
+}
+
+// PageResult 分页查询的结果，把 Bookmark 作为下一次查询的参数获取下一页，Count 小于分页大小说明已经是最后一页
+type PageResult struct {
+	Records  interface{} `json:"records"`
+	Count    int32       `json:"count"`
+	Bookmark string      `json:"bookmark"`
+}
+
+// 解析分页参数，pageSize 为空时使用默认值
+func parsePageSize(pageSizeStr string) (int32, error) {
+	if pageSizeStr == "" {
+		return defaultPageSize, nil
+	}
+
+	pageSize, err := strconv.ParseInt(pageSizeStr, 10, 32)
+	if err != nil || pageSize <= 0 {
+		return 0, fmt.Errorf("invalid page size: %s", pageSizeStr)
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	return int32(pageSize), nil
+}
+
+// 把查询条件转换为 CouchDB 的 selector，不直接接受调用方传入的 selector，避免任意查询
+func (q *AssetQuery) selector() (map[string]interface{}, error) {
+	selector := map[string]interface{}{
+		"doc_type": assetDocType,
+	}
+
+	if q.Name != "" {
+		selector["name"] = q.Name
+	}
+	if q.ClassId != "" {
+		selector["class_id"] = q.ClassId
+	}
+	if q.Owner != "" {
+		selector["owner"] = q.Owner
+	}
+
+	for name, r := range q.Attributes {
+		if name == "" || strings.ContainsAny(name, ".$") {
+			return nil, fmt.Errorf("invalid attribute name: %s", name)
+		}
+		if r == nil {
+			continue
+		}
+
+		cond := make(map[string]interface{})
+		if r.Eq != nil {
+			cond["$eq"] = r.Eq
+		}
+		if r.Gt != nil {
+			cond["$gt"] = r.Gt
+		}
+		if r.Gte != nil {
+			cond["$gte"] = r.Gte
+		}
+		if r.Lt != nil {
+			cond["$lt"] = r.Lt
+		}
+		if r.Lte != nil {
+			cond["$lte"] = r.Lte
+		}
+		if len(cond) == 0 {
+			continue
+		}
+		selector["attributes."+name] = cond
+	}
+
+	return selector, nil
+}
+
+// 资产富查询，分页返回
+func queryAssets(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数，bookmark 可以不传
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	query := new(AssetQuery)
+	if args[0] != "" {
+		if err := json.Unmarshal([]byte(args[0]), query); err != nil {
+			return shim.Error(fmt.Sprintf("invalid query: %s", err))
+		}
+	}
+	pageSize, err := parsePageSize(args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	bookmark := ""
+	if len(args) == 3 {
+		bookmark = args[2]
+	}
+
+	selector, err := query.selector()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	queryBytes, err := json.Marshal(map[string]interface{}{
+		"selector": selector,
+	})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal query error: %s", err))
+	}
+
+	// 3：查询数据
+	result, metadata, err := stub.GetQueryResultWithPagination(string(queryBytes), pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("query assets error: %s", err))
+	}
+	defer result.Close()
+
+	assets := make([]*Asset, 0)
+	for result.HasNext() {
+		assetVal, err := result.Next()
+		if err != nil {
+			return shim.Error(fmt.Sprintf("query error: %s", err))
+		}
+
+		asset := new(Asset)
+		if err := json.Unmarshal(assetVal.GetValue(), asset); err != nil {
+			return shim.Error(fmt.Sprintf("unmarshal error: %s", err))
+		}
+		assets = append(assets, asset)
+	}
+
+	pageBytes, err := json.Marshal(&PageResult{
+		Records:  assets,
+		Count:    metadata.GetFetchedRecordsCount(),
+		Bookmark: metadata.GetBookmark(),
+	})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(pageBytes)
+}
+
+// 解析列表查询的参数：分页大小、bookmark，都可以不传
+func parsePageArgs(args []string) (int32, string, error) {
+	if len(args) > 2 {
+		return 0, "", fmt.Errorf("too many args")
+	}
+
+	pageSizeStr, bookmark := "", ""
+	if len(args) >= 1 {
+		pageSizeStr = args[0]
+	}
+	if len(args) == 2 {
+		bookmark = args[1]
+	}
+
+	pageSize, err := parsePageSize(pageSizeStr)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return pageSize, bookmark, nil
+}
+
+// 用户列表，按用户 id 的顺序分页返回，不填充用户的资产列表
+func listUsers(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	pageSize, bookmark, err := parsePageArgs(args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	result, metadata, err := stub.GetStateByRangeWithPagination(constructUserKey(""), userKeyRangeEnd, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("query users error: %s", err))
+	}
+	defer result.Close()
+
+	users := make([]*User, 0)
+	for result.HasNext() {
+		userVal, err := result.Next()
+		if err != nil {
+			return shim.Error(fmt.Sprintf("query error: %s", err))
+		}
+
+		user := new(User)
+		if err := json.Unmarshal(userVal.GetValue(), user); err != nil {
+			return shim.Error(fmt.Sprintf("unmarshal error: %s", err))
+		}
+		users = append(users, user)
+	}
+
+	pageBytes, err := json.Marshal(&PageResult{
+		Records:  users,
+		Count:    metadata.GetFetchedRecordsCount(),
+		Bookmark: metadata.GetBookmark(),
+	})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(pageBytes)
+}
+
+// 资产列表，按资产 id 的顺序分页返回，不需要 CouchDB
+func listAssets(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	pageSize, bookmark, err := parsePageArgs(args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	result, metadata, err := stub.GetStateByRangeWithPagination(constructAssetKey(""), assetKeyRangeEnd, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("query assets error: %s", err))
+	}
+	defer result.Close()
+
+	assets := make([]*Asset, 0)
+	for result.HasNext() {
+		assetVal, err := result.Next()
+		if err != nil {
+			return shim.Error(fmt.Sprintf("query error: %s", err))
+		}
+
+		asset := new(Asset)
+		if err := json.Unmarshal(assetVal.GetValue(), asset); err != nil {
+			return shim.Error(fmt.Sprintf("unmarshal error: %s", err))
+		}
+		assets = append(assets, asset)
+	}
+
+	pageBytes, err := json.Marshal(&PageResult{
+		Records:  assets,
+		Count:    metadata.GetFetchedRecordsCount(),
+		Bookmark: metadata.GetBookmark(),
+	})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(pageBytes)
+}
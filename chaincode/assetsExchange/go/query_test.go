@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAssetQuerySelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "empty",
+			query: `{}`,
+			want:  `{"doc_type":"asset"}`,
+		},
+		{
+			name:  "fields",
+			query: `{"owner":"alice","name":"car","class_id":"vehicle"}`,
+			want:  `{"class_id":"vehicle","doc_type":"asset","name":"car","owner":"alice"}`,
+		},
+		{
+			name:  "range",
+			query: `{"class_id":"loan","attributes":{"principal":{"gte":100,"lt":200},"due":{"lte":"2021-12-31"}}}`,
+			want:  `{"attributes.due":{"$lte":"2021-12-31"},"attributes.principal":{"$gte":100,"$lt":200},"class_id":"loan","doc_type":"asset"}`,
+		},
+		{
+			name:  "eq and gt",
+			query: `{"attributes":{"grade":{"eq":"loss"},"principal":{"gt":0}}}`,
+			want:  `{"attributes.grade":{"$eq":"loss"},"attributes.principal":{"$gt":0},"doc_type":"asset"}`,
+		},
+		{
+			// 没有条件的属性忽略
+			name:  "empty condition",
+			query: `{"attributes":{"principal":{},"grade":null}}`,
+			want:  `{"doc_type":"asset"}`,
+		},
+		{
+			// 调用方不能覆盖 doc_type
+			name:  "doc type not overridable",
+			query: `{"doc_type":"user"}`,
+			want:  `{"doc_type":"asset"}`,
+		},
+		{name: "operator in name", query: `{"attributes":{"$or":{"eq":1}}}`, wantErr: "invalid attribute name: $or"},
+		{name: "nested name", query: `{"attributes":{"a.b":{"eq":1}}}`, wantErr: "invalid attribute name: a.b"},
+		{name: "empty name", query: `{"attributes":{"":{"eq":1}}}`, wantErr: "invalid attribute name"},
+	}
+	for _, tt := range tests {
+		query := new(AssetQuery)
+		if err := json.Unmarshal([]byte(tt.query), query); err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		selector, err := query.selector()
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tt.name, err)
+			continue
+		}
+		got, err := json.Marshal(selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQueryAssets(t *testing.T) {
+	s := newTestStub(t)
+
+	s.mustInvoke("queryAssets", `{"owner":"alice","attributes":{"principal":{"gte":100}}}`, "10", "bm")
+	if len(s.queries) != 1 {
+		t.Fatalf("got %d queries, want 1", len(s.queries))
+	}
+	q := s.queries[0]
+	want := `{"selector":{"attributes.principal":{"$gte":100},"doc_type":"asset","owner":"alice"}}`
+	if q.query != want || q.pageSize != 10 || q.bookmark != "bm" {
+		t.Fatalf("got query %+v", q)
+	}
+
+	page := new(PageResult)
+	if err := json.Unmarshal(s.mustInvoke("queryAssets", "", ""), page); err != nil {
+		t.Fatal(err)
+	}
+	if s.queries[1].query != `{"selector":{"doc_type":"asset"}}` || s.queries[1].pageSize != defaultPageSize {
+		t.Fatalf("got query %+v", s.queries[1])
+	}
+	if !reflect.DeepEqual(page.Records, []interface{}{}) || page.Count != 0 {
+		t.Fatalf("unexpected page %+v", page)
+	}
+
+	s.mustFail("queryAssets", `{"owner":`, "10")
+	s.mustFail("queryAssets", `{"attributes":{"$where":{"eq":1}}}`, "10")
+	if len(s.queries) != 2 {
+		t.Fatalf("invalid queries sent to the state database")
+	}
+}
+
+func TestParsePageSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int32
+		wantErr bool
+	}{
+		{"", defaultPageSize, false},
+		{"1", 1, false},
+		{"50", 50, false},
+		{"200", maxPageSize, false},
+		{"201", maxPageSize, false},
+		{"2147483647", maxPageSize, false},
+		{"0", 0, true},
+		{"-1", 0, true},
+		{"2147483648", 0, true},
+		{"1.5", 0, true},
+		{"ten", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parsePageSize(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parsePageSize(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParsePageArgs(t *testing.T) {
+	tests := []struct {
+		args         []string
+		wantSize     int32
+		wantBookmark string
+		wantErr      bool
+	}{
+		{nil, defaultPageSize, "", false},
+		{[]string{""}, defaultPageSize, "", false},
+		{[]string{"5"}, 5, "", false},
+		{[]string{"5", "user_b"}, 5, "user_b", false},
+		{[]string{"", "user_b"}, defaultPageSize, "user_b", false},
+		{[]string{"x", "user_b"}, 0, "", true},
+		{[]string{"5", "user_b", "extra"}, 0, "", true},
+	}
+	for _, tt := range tests {
+		size, bookmark, err := parsePageArgs(tt.args)
+		if (err != nil) != tt.wantErr || size != tt.wantSize || bookmark != tt.wantBookmark {
+			t.Errorf("parsePageArgs(%q) = %d, %q, %v", tt.args, size, bookmark, err)
+		}
+	}
+}
+
+// 按 bookmark 翻页取出全部记录
+func pageAll(t *testing.T, s *testStub, fcn string, args ...string) ([]string, int) {
+	ids := make([]string, 0)
+	pages := 0
+	bookmark := ""
+	for {
+		page := &PageResult{Records: &[]map[string]interface{}{}}
+		if err := json.Unmarshal(s.mustInvoke(fcn, append(args, bookmark)...), page); err != nil {
+			t.Fatal(err)
+		}
+		records := *page.Records.(*[]map[string]interface{})
+		// 按类型过滤的记录数可能少于取出的记录数
+		if len(records) > int(page.Count) {
+			t.Fatalf("%s: count %d, got %d records", fcn, page.Count, len(records))
+		}
+		for _, r := range records {
+			if id, ok := r["id"].(string); ok {
+				ids = append(ids, id)
+			} else {
+				ids = append(ids, fmt.Sprint(r["seq"]))
+			}
+		}
+		pages++
+		if page.Bookmark == "" {
+			return ids, pages
+		}
+		bookmark = page.Bookmark
+	}
+}
+
+func TestPagination(t *testing.T) {
+	s := newTestStub(t)
+	s.defineClass()
+	for _, id := range []string{"u1", "u2", "u3", "u4", "u5"} {
+		s.registerUser(testMspId, id)
+	}
+	for _, id := range []string{"a1", "a2", "a3"} {
+		s.enrollAsset(id, "u1")
+	}
+	for i := 1; i < 5; i++ {
+		from, to := fmt.Sprintf("u%d", i), fmt.Sprintf("u%d", i+1)
+		s.asUser(testMspId, from).mustInvoke("assetExchange", from, "a1", to)
+	}
+
+	tests := []struct {
+		fcn       string
+		args      []string
+		want      []string
+		wantPages int
+	}{
+		{"listUsers", []string{"2"}, []string{"u1", "u2", "u3", "u4", "u5"}, 3},
+		{"listUsers", []string{"5"}, []string{"u1", "u2", "u3", "u4", "u5"}, 1},
+		{"listAssets", []string{"2"}, []string{"a1", "a2", "a3"}, 2},
+		{"queryAssetHistory", []string{"a1", "all", "2"}, []string{"1", "2", "3", "4", "5"}, 3},
+		{"queryAssetHistory", []string{"a1", historyExchange, "2"}, []string{"2", "3", "4", "5"}, 3},
+	}
+	for _, tt := range tests {
+		got, pages := pageAll(t, s, tt.fcn, tt.args...)
+		if !reflect.DeepEqual(got, tt.want) || pages != tt.wantPages {
+			t.Errorf("%s %v: got %v in %d pages, want %v in %d pages", tt.fcn, tt.args, got, pages, tt.want, tt.wantPages)
+		}
+	}
+
+	s.mustFail("listUsers", "0")
+	s.mustFail("queryAssetHistory", "a1", "all", "-1")
+}
@@ -0,0 +1,664 @@
+package main
+
+// 角色登记和访问控制列表（ACL）：角色和每个方法允许的角色都保存在账本上，修改权限不需要重新部署链码
+// 角色授予证书身份（MSP ID + Subject），以组合键 role~MSPID~Subject 存储
+//   admin      管理员，管理角色和 ACL，与证书属性 admin=true 等价
+//   registrar  开户员，可以为其它身份开户（userRegister 的第 3、4 个参数）
+//   regulator  监管方，与证书属性 regulator=true 等价
+//   issuer     代币发行方，与证书属性 issuer=true 等价
+//   auditor    审计方，trader 交易员，默认不限制任何方法，通过 setAcl 收紧只读查询和交易方法
+// ACL 以 acl_方法名 存储，值为允许的角色列表，空数组表示不限制角色；Invoke 分发前校验调用者至少持有其中一个角色，没有 ACL 的方法一律拒绝
+// ACL 只是第一道检查，方法内部对用户本人、资产拥有者等的校验仍然有效
+// 链码实例化和升级时 Init 写入缺少的默认 ACL，参数带入角色授予和 ACL，实例化时必须授予 admin 角色，见 initRoles
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	roleObjectType = "role"
+
+	roleAdmin     = "admin"
+	roleRegistrar = "registrar"
+	roleRegulator = "regulator"
+	roleAuditor   = "auditor"
+	roleTrader    = "trader"
+	roleIssuer    = "issuer"
+)
+
+var knownRoles = map[string]bool{
+	roleAdmin:     true,
+	roleRegistrar: true,
+	roleRegulator: true,
+	roleAuditor:   true,
+	roleTrader:    true,
+	roleIssuer:    true,
+}
+
+// 默认 ACL，Init 时写入账本中还没有的项，之后以账本上的为准
+// 链码分发的每个方法都必须列出，没有 ACL 的方法一律拒绝，新增的方法不会绕过 ACL
+// 空数组表示不限制角色，由方法内部校验调用者是用户本人、资产拥有者、质权人、签署人等
+var defaultAcl = map[string][]string{
+	// 管理员
+	"assetClassDefine":        {roleAdmin},
+	"migrateOwnerIndex":       {roleAdmin},
+	"migrateAssetEndorsement": {roleAdmin},
+	"migrateAssetHistory":     {roleAdmin},
+	// 监管方
+	"assetFreeze":   {roleRegulator},
+	"assetUnfreeze": {roleRegulator},
+	"userFreeze":    {roleRegulator},
+	"userUnfreeze":  {roleRegulator},
+	// 代币发行方
+	"tokenMint": {roleIssuer},
+
+	// 用户：开户为其它身份开户时方法内部要求 registrar，修改资料时要求 registrar 或用户本人
+	"userRegister":            {},
+	"updateUserProfile":       {},
+	"userDestroy":             {},
+	"userSetSigners":          {},
+	"queryUser":               {},
+	"queryUserProfileHistory": {},
+	"queryUserLedgerHistory":  {},
+	"listUsers":               {},
+
+	// 资产
+	"assetEnroll":             {},
+	"assetUpdate":             {},
+	"assetExchange":           {},
+	"unitsTransfer":           {},
+	"poolBundle":              {},
+	"poolUnbundle":            {},
+	"assetAttachDocument":     {},
+	"queryAsset":              {},
+	"queryAssets":             {},
+	"queryAssetPrivate":       {},
+	"queryAssetHistory":       {},
+	"queryAssetLedgerHistory": {},
+	"queryAssetDocuments":     {},
+	"verifyAssetDocument":     {},
+	"queryAssetClass":         {},
+	"listAssets":              {},
+
+	// 质权
+	"lienRegister":    {},
+	"lienAccept":      {},
+	"lienRelease":     {},
+	"lienApprove":     {},
+	"queryAssetLiens": {},
+
+	// 转让要约、多签、互换
+	"transferOffer":        {},
+	"transferAccept":       {},
+	"transferReject":       {},
+	"transferCancel":       {},
+	"queryTransferOffer":   {},
+	"buyAsset":             {},
+	"multisigApprove":      {},
+	"multisigReject":       {},
+	"multisigExpire":       {},
+	"queryPendingTransfer": {},
+	"swapPropose":          {},
+	"swapAccept":           {},
+	"swapCancel":           {},
+	"querySwap":            {},
+
+	// 代币、挂牌、拍卖
+	"tokenTransfer":   {},
+	"balanceOf":       {},
+	"listingCreate":   {},
+	"listingUpdate":   {},
+	"listingCancel":   {},
+	"listingPurchase": {},
+	"queryListing":    {},
+	"queryListings":   {},
+	"auctionOpen":     {},
+	"auctionBid":      {},
+	"auctionReveal":   {},
+	"auctionSettle":   {},
+	"auctionCancel":   {},
+	"queryAuction":    {},
+
+	// 角色查询
+	"queryRoles": {},
+	"queryAcl":   {},
+}
+
+// 管理角色和 ACL 的方法只允许管理员调用，不受 ACL 控制，避免把管理员锁在外面
+var aclExempt = map[string]bool{
+	"grantRole":  true,
+	"revokeRole": true,
+	"setAcl":     true,
+}
+
+// RoleGrant 一个身份持有的角色
+type RoleGrant struct {
+	MspId     string    `json:"msp_id"`
+	Subject   string    `json:"subject"`
+	Roles     []string  `json:"roles"`
+	UpdatedAt time.Time `json:"updated_at"`
+	TxId      string    `json:"tx_id"`
+}
+
+// InitArgs 中的角色授予
+type roleInit struct {
+	MspId   string   `json:"msp_id"`
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles"`
+}
+
+func constructRoleKey(stub shim.ChaincodeStubInterface, mspId, subject string) (string, error) {
+	key, err := stub.CreateCompositeKey(roleObjectType, []string{mspId, subject})
+	if err != nil {
+		return "", fmt.Errorf("create key error: %s", err)
+	}
+
+	return key, nil
+}
+
+// 以 acl_ 开头的，认为是方法的 ACL
+func constructAclKey(fcn string) string {
+	return fmt.Sprintf("acl_%s", fcn)
+}
+
+// 读取身份持有的角色，没有时 Roles 为空
+func getRoleGrant(stub shim.ChaincodeStubInterface, mspId, subject string) (*RoleGrant, error) {
+	key, err := constructRoleKey(stub, mspId, subject)
+	if err != nil {
+		return nil, err
+	}
+	grantBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("get roles error: %s", err)
+	}
+
+	grant := &RoleGrant{MspId: mspId, Subject: subject, Roles: make([]string, 0)}
+	if len(grantBytes) == 0 {
+		return grant, nil
+	}
+	if err := json.Unmarshal(grantBytes, grant); err != nil {
+		return nil, fmt.Errorf("unmarshal roles error: %s", err)
+	}
+
+	return grant, nil
+}
+
+// 保存身份持有的角色，没有角色时删除
+func putRoleGrant(stub shim.ChaincodeStubInterface, grant *RoleGrant) error {
+	key, err := constructRoleKey(stub, grant.MspId, grant.Subject)
+	if err != nil {
+		return err
+	}
+	if len(grant.Roles) == 0 {
+		if err := stub.DelState(key); err != nil {
+			return fmt.Errorf("delete roles error: %s", err)
+		}
+		return nil
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+	sort.Strings(grant.Roles)
+	grant.UpdatedAt = now
+	grant.TxId = stub.GetTxID()
+	grantBytes, err := json.Marshal(grant)
+	if err != nil {
+		return fmt.Errorf("marshal roles error: %s", err)
+	}
+	if err := stub.PutState(key, grantBytes); err != nil {
+		return fmt.Errorf("save roles error: %s", err)
+	}
+
+	return nil
+}
+
+// 向身份授予角色，已持有时不重复
+func addRoles(grant *RoleGrant, roles ...string) error {
+	for _, role := range roles {
+		if !knownRoles[role] {
+			return fmt.Errorf("unknown role: %s", role)
+		}
+		if !hasRole(grant.Roles, role) {
+			grant.Roles = append(grant.Roles, role)
+		}
+	}
+
+	return nil
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 调用者持有的角色：账本上登记的角色，加上证书属性 admin、regulator、issuer 对应的角色
+func getCallerRoles(stub shim.ChaincodeStubInterface) ([]string, error) {
+	mspId, subject, err := getCallerIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	grant, err := getRoleGrant(stub, mspId, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := grant.Roles
+	if hasAttr(stub, adminAttr) && !hasRole(roles, roleAdmin) {
+		roles = append(roles, roleAdmin)
+	}
+	if hasAttr(stub, regulatorAttr) && !hasRole(roles, roleRegulator) {
+		roles = append(roles, roleRegulator)
+	}
+	if hasAttr(stub, issuerAttr) && !hasRole(roles, roleIssuer) {
+		roles = append(roles, roleIssuer)
+	}
+
+	return roles, nil
+}
+
+// 调用者是否持有角色，读取失败视为没有
+func callerHasRole(stub shim.ChaincodeStubInterface, role string) bool {
+	roles, err := getCallerRoles(stub)
+	return err == nil && hasRole(roles, role)
+}
+
+// 读取方法的 ACL，没有 ACL 时返回 nil，设置为不限制角色时返回空数组
+func getAcl(stub shim.ChaincodeStubInterface, fcn string) ([]string, error) {
+	aclBytes, err := stub.GetState(constructAclKey(fcn))
+	if err != nil {
+		return nil, fmt.Errorf("get acl error: %s", err)
+	}
+	if len(aclBytes) == 0 {
+		return nil, nil
+	}
+
+	var roles []string
+	if err := json.Unmarshal(aclBytes, &roles); err != nil {
+		return nil, fmt.Errorf("unmarshal acl error: %s", err)
+	}
+
+	return roles, nil
+}
+
+// 保存方法的 ACL，角色列表为空时保存空数组，即不限制角色
+// 不能删除键：initRoles 会为没有 ACL 的方法写入默认 ACL，删除后下一次升级会恢复默认值
+func putAcl(stub shim.ChaincodeStubInterface, fcn string, roles []string) error {
+	if aclExempt[fcn] {
+		return fmt.Errorf("acl of %s cannot be changed", fcn)
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+	for _, role := range roles {
+		if !knownRoles[role] {
+			return fmt.Errorf("unknown role: %s", role)
+		}
+	}
+
+	aclBytes, err := json.Marshal(roles)
+	if err != nil {
+		return fmt.Errorf("marshal acl error: %s", err)
+	}
+	if err := stub.PutState(constructAclKey(fcn), aclBytes); err != nil {
+		return fmt.Errorf("save acl error: %s", err)
+	}
+
+	return nil
+}
+
+// Invoke 分发前的 ACL 校验
+func checkAcl(stub shim.ChaincodeStubInterface, fcn string) error {
+	if aclExempt[fcn] {
+		return nil
+	}
+	allowed, err := getAcl(stub, fcn)
+	if err != nil {
+		return err
+	}
+	if allowed == nil {
+		return fmt.Errorf("permission denied: no acl for %s", fcn)
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	roles, err := getCallerRoles(stub)
+	if err != nil {
+		return err
+	}
+	for _, role := range allowed {
+		if hasRole(roles, role) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("permission denied: %s requires one of roles %v", fcn, allowed)
+}
+
+// 链码实例化和升级时执行：写入缺少的默认 ACL，再应用参数中的角色授予和 ACL
+// 已有 ACL（包括通过 setAcl 设置为空数组的）的方法不会被默认值覆盖，新版本增加的默认 ACL 在升级时写入
+// 参数：角色授予 JSON 数组 [{"msp_id","subject","roles"}]、ACL JSON 对象 {"方法名":["角色"]}
+// 提交实例化或升级交易的身份（通道管理员）不会被授予任何角色，实例化时必须在角色授予中指定 admin，
+// 升级时账本上已有 admin 角色的，两个参数都可以不传
+func initRoles(stub shim.ChaincodeStubInterface, args []string) error {
+	if len(args) > 2 {
+		return fmt.Errorf("too many args")
+	}
+
+	var grants []*roleInit
+	if len(args) >= 1 && args[0] != "" {
+		if err := json.Unmarshal([]byte(args[0]), &grants); err != nil {
+			return fmt.Errorf("invalid role grants: %s", err)
+		}
+	}
+	acl := make(map[string][]string)
+	if len(args) == 2 && args[1] != "" {
+		if err := json.Unmarshal([]byte(args[1]), &acl); err != nil {
+			return fmt.Errorf("invalid acl: %s", err)
+		}
+	}
+
+	// 同一身份在参数中出现多次时合并，同一交易内每个键只写一次
+	merged := make(map[string]*RoleGrant)
+	order := make([]string, 0, len(grants))
+	for _, g := range grants {
+		if g == nil || g.MspId == "" || g.Subject == "" {
+			return fmt.Errorf("role grant msp_id and subject are required")
+		}
+		key := g.MspId + "|" + g.Subject
+		grant, ok := merged[key]
+		if !ok {
+			var err error
+			if grant, err = getRoleGrant(stub, g.MspId, g.Subject); err != nil {
+				return err
+			}
+			merged[key] = grant
+			order = append(order, key)
+		}
+		if err := addRoles(grant, g.Roles...); err != nil {
+			return err
+		}
+	}
+
+	// 不默认授予提交交易的身份，账本上必须至少有一个 admin 角色，否则角色和 ACL 无人可以管理
+	hasAdmin := false
+	for _, key := range order {
+		hasAdmin = hasAdmin || hasRole(merged[key].Roles, roleAdmin)
+	}
+	if !hasAdmin {
+		ok, err := hasAdminGrant(stub)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("role grants required: no identity holds the admin role")
+		}
+	}
+
+	// 默认 ACL 按方法名排序写入，参数中已指定的方法以参数为准
+	fcns := make([]string, 0, len(defaultAcl))
+	for fcn := range defaultAcl {
+		fcns = append(fcns, fcn)
+	}
+	sort.Strings(fcns)
+	for _, fcn := range fcns {
+		if _, ok := acl[fcn]; ok {
+			continue
+		}
+		existing, err := getAcl(stub, fcn)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			if err := putAcl(stub, fcn, defaultAcl[fcn]); err != nil {
+				return err
+			}
+		}
+	}
+	fcns = fcns[:0]
+	for fcn := range acl {
+		fcns = append(fcns, fcn)
+	}
+	sort.Strings(fcns)
+	for _, fcn := range fcns {
+		if err := putAcl(stub, fcn, acl[fcn]); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range order {
+		if err := putRoleGrant(stub, merged[key]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 账本上是否有身份持有 admin 角色
+func hasAdminGrant(stub shim.ChaincodeStubInterface) (bool, error) {
+	result, err := stub.GetStateByPartialCompositeKey(roleObjectType, []string{})
+	if err != nil {
+		return false, fmt.Errorf("query roles error: %s", err)
+	}
+	defer result.Close()
+
+	for result.HasNext() {
+		grantVal, err := result.Next()
+		if err != nil {
+			return false, fmt.Errorf("query error: %s", err)
+		}
+
+		grant := new(RoleGrant)
+		if err := json.Unmarshal(grantVal.GetValue(), grant); err != nil {
+			return false, fmt.Errorf("unmarshal roles error: %s", err)
+		}
+		if hasRole(grant.Roles, roleAdmin) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// 读取角色管理方法的参数：MSP ID、证书 Subject、角色，并校验调用者是管理员
+func loadRoleArgs(stub shim.ChaincodeStubInterface, args []string) (*RoleGrant, string, error) {
+	// 1：检查参数的个数
+	if len(args) != 3 {
+		return nil, "", fmt.Errorf("not enough args")
+	}
+	if !isAdmin(stub) {
+		return nil, "", fmt.Errorf("permission denied: admin only")
+	}
+
+	// 2：验证参数的正确性
+	mspId := args[0]
+	subject := args[1]
+	role := args[2]
+	if mspId == "" || subject == "" {
+		return nil, "", fmt.Errorf("invalid args")
+	}
+	if !knownRoles[role] {
+		return nil, "", fmt.Errorf("unknown role: %s", role)
+	}
+
+	// 3：验证数据是否存在
+	grant, err := getRoleGrant(stub, mspId, subject)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return grant, role, nil
+}
+
+func emitRoleEvent(stub shim.ChaincodeStubInterface, eventType string, grant *RoleGrant, role string) error {
+	return emitEvent(stub, &ChaincodeEvent{
+		Type:   eventType,
+		UserId: grant.MspId + "/" + grant.Subject,
+		Reason: role,
+	})
+}
+
+// 授予角色，只有管理员可以操作
+func grantRole(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	grant, role, err := loadRoleArgs(stub, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if hasRole(grant.Roles, role) {
+		return shim.Error(fmt.Sprintf("role %s already granted", role))
+	}
+
+	// 4： 状态写入
+	grant.Roles = append(grant.Roles, role)
+	if err := putRoleGrant(stub, grant); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitRoleEvent(stub, eventRoleGranted, grant, role); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 撤销角色，只有管理员可以操作。证书属性对应的角色不能撤销，需要吊销证书
+func revokeRole(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	grant, role, err := loadRoleArgs(stub, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !hasRole(grant.Roles, role) {
+		return shim.Error(fmt.Sprintf("role %s not granted", role))
+	}
+
+	// 4： 状态写入
+	roles := make([]string, 0, len(grant.Roles))
+	for _, r := range grant.Roles {
+		if r != role {
+			roles = append(roles, r)
+		}
+	}
+	grant.Roles = roles
+	if err := putRoleGrant(stub, grant); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitRoleEvent(stub, eventRoleRevoked, grant, role); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 设置方法的 ACL，角色列表为空数组时不限制角色，只有管理员可以操作
+func setAcl(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：方法名、角色列表（JSON 数组）
+	if len(args) != 2 {
+		return shim.Error("not enough args")
+	}
+	if !isAdmin(stub) {
+		return shim.Error("permission denied: admin only")
+	}
+
+	// 2：验证参数的正确性
+	fcn := args[0]
+	if fcn == "" {
+		return shim.Error("invalid args")
+	}
+	var roles []string
+	if err := json.Unmarshal([]byte(args[1]), &roles); err != nil {
+		return shim.Error(fmt.Sprintf("invalid roles: %s", err))
+	}
+
+	// 4： 状态写入
+	if err := putAcl(stub, fcn, roles); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:   eventAclChanged,
+		RefId:  fcn,
+		Reason: fmt.Sprintf("%v", roles),
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 角色查询，不传参数时查询调用者自己的角色（包括证书属性对应的角色）
+func queryRoles(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：MSP ID、证书 Subject
+	if len(args) != 0 && len(args) != 2 {
+		return shim.Error("not enough args")
+	}
+
+	var grant *RoleGrant
+	if len(args) == 0 {
+		mspId, subject, err := getCallerIdentity(stub)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		roles, err := getCallerRoles(stub)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		grant = &RoleGrant{MspId: mspId, Subject: subject, Roles: roles}
+	} else {
+		// 2：验证参数的正确性
+		if args[0] == "" || args[1] == "" {
+			return shim.Error("invalid args")
+		}
+		var err error
+		if grant, err = getRoleGrant(stub, args[0], args[1]); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	grantBytes, err := json.Marshal(grant)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(grantBytes)
+}
+
+// ACL 查询，返回方法允许的角色列表，不限制角色时为空数组
+func queryAcl(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	fcn := args[0]
+	if fcn == "" {
+		return shim.Error("invalid args")
+	}
+
+	roles, err := getAcl(stub, fcn)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if roles == nil {
+		roles = make([]string, 0)
+	}
+
+	rolesBytes, err := json.Marshal(roles)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(rolesBytes)
+}
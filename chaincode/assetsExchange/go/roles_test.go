@@ -0,0 +1,132 @@
+package main
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func TestInitRequiresAdminGrant(t *testing.T) {
+	s := &testStub{
+		MockStub: shim.NewMockStub("assetsExchange", new(AssertsManageCC)),
+		t:        t,
+	}
+	// 提交实例化交易的是通道管理员，没有证书属性
+	s.as(testMspId, "Admin@org1.example.com", nil)
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"no args", nil, "role grants required"},
+		{"empty grants", []string{"[]"}, "role grants required"},
+		{"no admin role", []string{`[{"msp_id":"Org1MSP","subject":"CN=bob","roles":["registrar"]}]`}, "role grants required"},
+		{"no subject", []string{`[{"msp_id":"Org1MSP","roles":["admin"]}]`}, "msp_id and subject are required"},
+		{"unknown role", []string{`[{"msp_id":"Org1MSP","subject":"CN=admin","roles":["root"]}]`}, "unknown role"},
+	}
+	for _, tt := range tests {
+		resp := s.init(tt.args...)
+		if resp.Status == shim.OK || !strings.Contains(resp.Message, tt.wantErr) {
+			t.Errorf("%s: got %d %q, want %q", tt.name, resp.Status, resp.Message, tt.wantErr)
+		}
+	}
+
+	if resp := s.init(testAdminGrant); resp.Status != shim.OK {
+		t.Fatalf("init: %s", resp.Message)
+	}
+	// 实例化的身份没有被授予 admin
+	msg := s.mustFail("grantRole", testMspId, "CN=bob", roleRegistrar)
+	if !strings.Contains(msg, "admin only") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+	s.as(testMspId, "admin", nil).mustInvoke("grantRole", testMspId, "CN=bob", roleRegistrar)
+
+	// 升级时账本上已有 admin，不需要再传角色授予
+	if resp := s.as(testMspId, "Admin@org1.example.com", nil).init(); resp.Status != shim.OK {
+		t.Fatalf("upgrade: %s", resp.Message)
+	}
+}
+
+// Invoke 分发的每个方法都要有默认 ACL，否则升级后该方法一律被拒绝
+func TestDefaultAclCoversDispatch(t *testing.T) {
+	src, err := ioutil.ReadFile("assetsexchange.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(src)
+	body = body[strings.Index(body, ") Invoke(stub"):]
+	cases := regexp.MustCompile(`case "(\w+)":`).FindAllStringSubmatch(body, -1)
+	if len(cases) == 0 {
+		t.Fatal("no dispatched functions found")
+	}
+
+	dispatched := make(map[string]bool)
+	for _, c := range cases {
+		fcn := c[1]
+		dispatched[fcn] = true
+		if _, ok := defaultAcl[fcn]; !ok && !aclExempt[fcn] {
+			t.Errorf("%s has no default acl", fcn)
+		}
+	}
+	for fcn := range defaultAcl {
+		if !dispatched[fcn] {
+			t.Errorf("default acl for unknown function %s", fcn)
+		}
+	}
+}
+
+func TestAcl(t *testing.T) {
+	s := newTestStub(t)
+	s.registerUser(testMspId, "alice")
+
+	// 没有 ACL 的方法一律拒绝
+	msg := s.asAdmin().mustFail("noSuchFunction")
+	if !strings.Contains(msg, "no acl for noSuchFunction") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+
+	tests := []struct {
+		fcn  string
+		args []string
+		role string
+	}{
+		{"assetFreeze", []string{"a1", "court_order"}, roleRegulator},
+		{"userFreeze", []string{"alice", "court_order"}, roleRegulator},
+		{"tokenMint", []string{"alice", "100"}, roleIssuer},
+		{"assetClassDefine", []string{"c", "C", "[]"}, roleAdmin},
+		{"migrateAssetHistory", nil, roleAdmin},
+	}
+	for _, tt := range tests {
+		msg := s.asUser(testMspId, "alice").mustFail(tt.fcn, tt.args...)
+		if !strings.Contains(msg, "requires one of roles ["+tt.role+"]") {
+			t.Errorf("%s: unexpected error %s", tt.fcn, msg)
+		}
+	}
+
+	// issuer 角色与证书属性等价
+	s.asAdmin().mustInvoke("grantRole", testMspId, "CN=minter", roleIssuer)
+	s.as(testMspId, "minter", nil).mustInvoke("tokenMint", "alice", "100")
+	if balance := s.balance("alice"); balance != 100 {
+		t.Fatalf("balance %d, want 100", balance)
+	}
+
+	// 收紧查询方法，升级时不会被默认值覆盖；设置为空数组后同样保留
+	s.asAdmin().mustInvoke("setAcl", "queryUser", `["auditor"]`)
+	s.asUser(testMspId, "alice").mustFail("queryUser", "alice")
+	if resp := s.asAdmin().init(); resp.Status != shim.OK {
+		t.Fatalf("upgrade: %s", resp.Message)
+	}
+	s.asUser(testMspId, "alice").mustFail("queryUser", "alice")
+	s.asAdmin().mustInvoke("setAcl", "queryUser", `[]`)
+	if resp := s.asAdmin().init(); resp.Status != shim.OK {
+		t.Fatalf("upgrade: %s", resp.Message)
+	}
+	s.asUser(testMspId, "alice").mustInvoke("queryUser", "alice")
+
+	s.asUser(testMspId, "alice").mustFail("setAcl", "queryUser", `["auditor"]`)
+	s.asAdmin().mustFail("setAcl", "grantRole", `[]`)
+}
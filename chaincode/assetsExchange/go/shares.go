@@ -0,0 +1,250 @@
+package main
+
+// 份额化持有：大额贷款资产包由多个投资者按份额共同持有
+// 登记资产时指定份额总数后，资产按份额持有：登记者持有全部份额，份额可以部分转让，每次转让写入资产变更记录
+// 份额以组合键 share~资产id~持有人id 存储，值为份额数，份额为 0 时删除
+// 持有份额的用户同时写入 owner~assetId 索引，queryUser 的资产列表包括其持有份额的资产
+// 份额化资产的 Owner 为登记者，不代表所有权；整体转让、挂牌、拍卖、要约、互换和质押都会被拒绝
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	shareObjectType = "share"
+)
+
+// AssetShare 份额持有情况，queryAsset 返回的股权结构表中的一行
+type AssetShare struct {
+	HolderId string `json:"holder_id"`
+	Units    int64  `json:"units"`
+}
+
+// 份额化资产和池中的资产不能整体处置
+func checkWholeAsset(asset *Asset) error {
+	if asset.Units > 0 {
+		return fmt.Errorf("asset %s is held in units, transfer units instead", asset.Id)
+	}
+	if asset.PoolId != "" {
+		return fmt.Errorf("asset %s is bundled in pool %s, transfer the pool instead", asset.Id, asset.PoolId)
+	}
+
+	return nil
+}
+
+func constructShareKey(stub shim.ChaincodeStubInterface, assetId, holderId string) (string, error) {
+	key, err := stub.CreateCompositeKey(shareObjectType, []string{assetId, holderId})
+	if err != nil {
+		return "", fmt.Errorf("create key error: %s", err)
+	}
+
+	return key, nil
+}
+
+// 读取用户持有的份额，没有持有时为 0
+func getShareUnits(stub shim.ChaincodeStubInterface, assetId, holderId string) (int64, error) {
+	key, err := constructShareKey(stub, assetId, holderId)
+	if err != nil {
+		return 0, err
+	}
+
+	return getInt64State(stub, key)
+}
+
+// 保存用户持有的份额，份额为 0 时删除，份额键的修改需要持有人组织背书。拥有者索引由调用方维护
+func putShareUnits(stub shim.ChaincodeStubInterface, assetId string, holder *User, units int64) error {
+	key, err := constructShareKey(stub, assetId, holder.Id)
+	if err != nil {
+		return err
+	}
+	if units == 0 {
+		if err := stub.DelState(key); err != nil {
+			return fmt.Errorf("delete share error: %s", err)
+		}
+		return nil
+	}
+
+	if err := putInt64State(stub, key, units); err != nil {
+		return err
+	}
+
+	return setKeyEndorsement(stub, key, holder.MspId)
+}
+
+// 读取资产的股权结构表，按持有人 id 排序
+func getAssetShares(stub shim.ChaincodeStubInterface, assetId string) ([]*AssetShare, error) {
+	result, err := stub.GetStateByPartialCompositeKey(shareObjectType, []string{assetId})
+	if err != nil {
+		return nil, fmt.Errorf("query shares error: %s", err)
+	}
+	defer result.Close()
+
+	shares := make([]*AssetShare, 0)
+	for result.HasNext() {
+		shareVal, err := result.Next()
+		if err != nil {
+			return nil, fmt.Errorf("query error: %s", err)
+		}
+
+		_, keys, err := stub.SplitCompositeKey(shareVal.GetKey())
+		if err != nil {
+			return nil, fmt.Errorf("split key error: %s", err)
+		}
+		units, err := strconv.ParseInt(string(shareVal.GetValue()), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse share error: %s", err)
+		}
+		shares = append(shares, &AssetShare{
+			HolderId: keys[1],
+			Units:    units,
+		})
+	}
+
+	return shares, nil
+}
+
+// 份额从 fromId 转给 toId，维护拥有者索引并写入资产变更记录。不校验调用者身份，由调用方负责
+// 同一交易内对同一资产只能调用一次
+func moveUnits(stub shim.ChaincodeStubInterface, asset *Asset, fromId, toId string, units int64) error {
+	if fromId == toId {
+		return fmt.Errorf("cannot transfer units to self")
+	}
+	if asset.Units == 0 {
+		return fmt.Errorf("asset %s is not held in units", asset.Id)
+	}
+
+	// 接收者，已销户或未通过 KYC 核验的用户不能接收份额
+	recipient, err := getActiveUser(stub, toId)
+	if err != nil {
+		return err
+	}
+	if err := checkUserVerified(recipient); err != nil {
+		return err
+	}
+	// 资产或出让者被冻结时不能转出
+	from, err := getUser(stub, fromId)
+	if err != nil {
+		return err
+	}
+	if err := checkNotFrozen(stub, asset, from); err != nil {
+		return err
+	}
+	// 多签只支持整体转让，多签用户的份额不能转出
+	if err := checkNotMultisig(from); err != nil {
+		return err
+	}
+
+	fromUnits, err := getShareUnits(stub, asset.Id, fromId)
+	if err != nil {
+		return err
+	}
+	if fromUnits < units {
+		return fmt.Errorf("insufficient units: %s holds %d of asset %s, needs %d", fromId, fromUnits, asset.Id, units)
+	}
+	toUnits, err := getShareUnits(stub, asset.Id, toId)
+	if err != nil {
+		return err
+	}
+	// 份额合计不超过份额总数，这里只是防御性检查
+	if toUnits > math.MaxInt64-units {
+		return fmt.Errorf("units overflow")
+	}
+
+	// 1. 更新双方份额 2. 维护拥有者索引 3. 资产变更记录
+	if err := putShareUnits(stub, asset.Id, from, fromUnits-units); err != nil {
+		return err
+	}
+	if err := putShareUnits(stub, asset.Id, recipient, toUnits+units); err != nil {
+		return err
+	}
+	if fromUnits == units {
+		if err := delOwnerIndex(stub, fromId, asset.Id); err != nil {
+			return err
+		}
+	}
+	if toUnits == 0 {
+		if err := putOwnerIndex(stub, recipient, asset.Id); err != nil {
+			return err
+		}
+	}
+
+	return putAssetHistory(stub, &AssetHistory{
+		AssetId:        asset.Id,
+		OriginOwnerId:  fromId,
+		CurrentOwnerId: toId,
+		Action:         historyExchange,
+		Units:          units,
+	})
+}
+
+// 用户名下的资产全部转给 toId：整体持有的资产转让资产，份额化资产转让其持有的全部份额
+// 池中的资产随资产池一起转让，这里跳过
+func transferHoldings(stub shim.ChaincodeStubInterface, fromId, assetId, toId string) error {
+	asset, err := getAsset(stub, assetId)
+	if err != nil {
+		return err
+	}
+	if asset.PoolId != "" {
+		return nil
+	}
+	if asset.Units == 0 {
+		return transferAsset(stub, fromId, assetId, toId)
+	}
+
+	units, err := getShareUnits(stub, assetId, fromId)
+	if err != nil {
+		return err
+	}
+	return moveUnits(stub, asset, fromId, toId, units)
+}
+
+// 份额转让，只有出让者本人或管理员可以操作
+func unitsTransfer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：出让者 id、资产 id、受让者 id、份额数
+	if len(args) != 4 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	fromId := args[0]
+	assetId := args[1]
+	toId := args[2]
+	if fromId == "" || assetId == "" || toId == "" {
+		return shim.Error("invalid args")
+	}
+	units, err := strconv.ParseInt(args[3], 10, 64)
+	if err != nil || units <= 0 {
+		return shim.Error(fmt.Sprintf("invalid units: %s", args[3]))
+	}
+
+	// 3：验证数据是否存在
+	if _, err := getUserWithAccess(stub, fromId); err != nil {
+		return shim.Error(err.Error())
+	}
+	asset, err := getAsset(stub, assetId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 4： 状态写入
+	if err := moveUnits(stub, asset, fromId, toId, units); err != nil {
+		return errorResponse(err)
+	}
+
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:    eventUnitsTransferred,
+		AssetId: assetId,
+		From:    fromId,
+		To:      toId,
+		Amount:  units,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
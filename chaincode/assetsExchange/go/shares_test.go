@@ -0,0 +1,152 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCheckWholeAsset(t *testing.T) {
+	tests := []struct {
+		name    string
+		asset   *Asset
+		wantErr string
+	}{
+		{"whole", &Asset{Id: "a1"}, ""},
+		{"pool", &Asset{Id: "p1", Members: []string{"a1"}}, ""},
+		{"units", &Asset{Id: "a1", Units: 100}, "asset a1 is held in units"},
+		{"pool member", &Asset{Id: "a1", PoolId: "p1"}, "asset a1 is bundled in pool p1"},
+	}
+	for _, tt := range tests {
+		err := checkWholeAsset(tt.asset)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// 资产的股权结构表，持有人 id 到份额数
+func (s *testStub) shares(assetId string) map[string]int64 {
+	s.t.Helper()
+	shares, err := getAssetShares(s, assetId)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	holdings := make(map[string]int64)
+	for _, share := range shares {
+		holdings[share.HolderId] = share.Units
+	}
+
+	return holdings
+}
+
+// 持有该资产（整体或份额）的用户
+func (s *testStub) holders(assetId string, userIds ...string) map[string]int64 {
+	s.t.Helper()
+	holders := make(map[string]int64)
+	for _, userId := range userIds {
+		assetIds, err := getOwnerAssetIds(s, userId)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		for _, id := range assetIds {
+			if id == assetId {
+				holders[userId] = s.shares(assetId)[userId]
+			}
+		}
+	}
+
+	return holders
+}
+
+func TestUnitsTransfer(t *testing.T) {
+	s := newTestStub(t)
+	s.defineClass()
+	users := []string{"alice", "bob", "carol"}
+	for _, id := range users {
+		s.registerUser(testMspId, id)
+	}
+	s.enrollAsset("a1", "alice", "100")
+
+	// 依次执行，want 为执行后的股权结构表
+	steps := []struct {
+		from, to string
+		units    string
+		wantErr  string
+		want     map[string]int64
+	}{
+		{"alice", "bob", "30", "", map[string]int64{"alice": 70, "bob": 30}},
+		{"alice", "carol", "70", "", map[string]int64{"bob": 30, "carol": 70}},
+		{"bob", "carol", "31", "insufficient units: bob holds 30", nil},
+		{"bob", "bob", "1", "cannot transfer units to self", nil},
+		{"bob", "alice", "0", "invalid units", nil},
+		{"bob", "alice", "-5", "invalid units", nil},
+		{"bob", "alice", strconv.FormatInt(math.MaxInt64, 10), "insufficient units", nil},
+		{"bob", "nobody", "1", "user not found", nil},
+		{"carol", "bob", "70", "", map[string]int64{"bob": 100}},
+		{"bob", "alice", "1", "", map[string]int64{"alice": 1, "bob": 99}},
+	}
+	for i, step := range steps {
+		resp := s.asAdmin().invoke("unitsTransfer", step.from, "a1", step.to, step.units)
+		if step.wantErr != "" {
+			if resp.Status == 200 || !strings.Contains(resp.Message, step.wantErr) {
+				t.Fatalf("step %d: got %d %q, want %q", i, resp.Status, resp.Message, step.wantErr)
+			}
+			continue
+		}
+		if resp.Status != 200 {
+			t.Fatalf("step %d: %s", i, resp.Message)
+		}
+		if got := s.shares("a1"); !reflect.DeepEqual(got, step.want) {
+			t.Fatalf("step %d: shares %v, want %v", i, got, step.want)
+		}
+		// 拥有者索引与股权结构表一致
+		if got := s.holders("a1", users...); !reflect.DeepEqual(got, step.want) {
+			t.Fatalf("step %d: owner index %v, want %v", i, got, step.want)
+		}
+	}
+
+	// 份额合计始终等于份额总数
+	total := int64(0)
+	for _, units := range s.shares("a1") {
+		total += units
+	}
+	if asset := s.getAsset("a1"); total != asset.Units {
+		t.Fatalf("shares sum to %d, asset has %d units", total, asset.Units)
+	}
+	histories := s.assetHistory("a1")
+	if last := histories[len(histories)-1]; last.Units != 1 || last.OriginOwnerId != "bob" || last.CurrentOwnerId != "alice" {
+		t.Fatalf("unexpected history %+v", last)
+	}
+
+	// 份额化资产不能整体转让，出让者本人之外不能转让份额
+	msg := s.asUser(testMspId, "alice").mustFail("assetExchange", "alice", "a1", "bob")
+	if !strings.Contains(msg, "held in units") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+	s.asUser(testMspId, "alice").mustFail("unitsTransfer", "bob", "a1", "alice", "1")
+	s.asUser(testMspId, "bob").mustInvoke("unitsTransfer", "bob", "a1", "alice", "1")
+}
+
+func TestMoveUnitsWholeAsset(t *testing.T) {
+	s := newTestStub(t)
+	s.defineClass()
+	s.registerUser(testMspId, "alice")
+	s.registerUser(testMspId, "bob")
+	s.enrollAsset("a1", "alice")
+
+	s.MockTransactionStart("move")
+	defer s.MockTransactionEnd("move")
+	err := moveUnits(s, s.getAsset("a1"), "alice", "bob", 1)
+	if err == nil || !strings.Contains(err.Error(), "not held in units") {
+		t.Fatalf("got %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+// 资产互换：双方各拿出一项资产，两次所有权变更和两条变更记录在同一交易中完成，要么全部成功要么全部失败
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// AssetSwap 资产互换提议，状态沿用转让要约的 pending / accepted / rejected / cancelled
+type AssetSwap struct {
+	Id                  string    `json:"id"`                    // 互换 id，即发起提议的交易 id
+	ProposerId          string    `json:"proposer_id"`           // 发起方
+	ProposerAssetId     string    `json:"proposer_asset_id"`     // 发起方拿出的资产
+	CounterpartyId      string    `json:"counterparty_id"`       // 对手方
+	CounterpartyAssetId string    `json:"counterparty_asset_id"` // 对手方拿出的资产
+	State               string    `json:"state"`
+	Expiry              time.Time `json:"expiry"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// 以 swap_ 开头的，认为是资产互换
+func constructSwapKey(swapId string) string {
+	return fmt.Sprintf("swap_%s", swapId)
+}
+
+// 读取资产互换
+func getSwap(stub shim.ChaincodeStubInterface, swapId string) (*AssetSwap, error) {
+	swapBytes, err := stub.GetState(constructSwapKey(swapId))
+	if err != nil || len(swapBytes) == 0 {
+		return nil, fmt.Errorf("swap not found")
+	}
+
+	swap := new(AssetSwap)
+	if err := json.Unmarshal(swapBytes, swap); err != nil {
+		return nil, fmt.Errorf("unmarshal swap error: %s", err)
+	}
+
+	return swap, nil
+}
+
+// 保存资产互换
+func putSwap(stub shim.ChaincodeStubInterface, swap *AssetSwap) error {
+	swapBytes, err := json.Marshal(swap)
+	if err != nil {
+		return fmt.Errorf("marshal swap error: %s", err)
+	}
+	if err := stub.PutState(constructSwapKey(swap.Id), swapBytes); err != nil {
+		return fmt.Errorf("save swap error: %s", err)
+	}
+
+	return nil
+}
+
+// 发出资产互换相关的事件
+func emitSwapEvent(stub shim.ChaincodeStubInterface, evtType string, swap *AssetSwap) error {
+	return emitEvent(stub, &ChaincodeEvent{
+		Type:           evtType,
+		AssetId:        swap.ProposerAssetId,
+		From:           swap.ProposerId,
+		To:             swap.CounterpartyId,
+		CounterAssetId: swap.CounterpartyAssetId,
+		RefId:          swap.Id,
+	})
+}
+
+// 发起资产互换
+func swapPropose(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 5 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	proposerId := args[0]
+	proposerAssetId := args[1]
+	counterpartyId := args[2]
+	counterpartyAssetId := args[3]
+	if proposerId == "" || proposerAssetId == "" || counterpartyId == "" || counterpartyAssetId == "" ||
+		proposerId == counterpartyId || proposerAssetId == counterpartyAssetId {
+		return shim.Error("invalid args")
+	}
+	// 过期时间为 RFC3339 格式，如 2020-05-01T00:00:00+08:00
+	expiry, err := time.Parse(time.RFC3339, args[4])
+	if err != nil {
+		return shim.Error(fmt.Sprintf("invalid expiry: %s", err))
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !expiry.After(now) {
+		return shim.Error("expiry must be in the future")
+	}
+
+	// 3：验证数据是否存在
+	proposer, err := getUserWithAccess(stub, proposerId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	counterparty, err := getUser(stub, counterpartyId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	// 互换双方都要转出资产，多签用户不能参与
+	if err := checkNotMultisig(proposer); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkNotMultisig(counterparty); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkAssetOwner(stub, proposerId, proposerAssetId); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkAssetOwner(stub, counterpartyId, counterpartyAssetId); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkNotAuctioned(stub, proposerAssetId, ""); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkNotAuctioned(stub, counterpartyAssetId, ""); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 4： 状态写入
+	swap := &AssetSwap{
+		Id:                  stub.GetTxID(),
+		ProposerId:          proposerId,
+		ProposerAssetId:     proposerAssetId,
+		CounterpartyId:      counterpartyId,
+		CounterpartyAssetId: counterpartyAssetId,
+		State:               offerPending,
+		Expiry:              expiry.UTC(),
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+	if err := putSwap(stub, swap); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitSwapEvent(stub, eventSwapProposed, swap); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 返回互换 id，对手方凭此接受
+	return shim.Success([]byte(swap.Id))
+}
+
+// 对手方接受资产互换，双方资产在同一交易中交换
+func swapAccept(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	swap, now, err := loadPendingSwap(stub, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !now.Before(swap.Expiry) {
+		return shim.Error("swap expired")
+	}
+
+	// 只有对手方本人或管理员可以接受
+	if _, err := getUserWithAccess(stub, swap.CounterpartyId); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 提议期间任一方可能已处置了资产，transferAsset 会再次校验所有权
+	// 两项资产的所有权变更和变更记录在同一交易中，任何一步失败整个交易都不会提交
+	if err := transferAsset(stub, swap.ProposerId, swap.ProposerAssetId, swap.CounterpartyId); err != nil {
+		return errorResponse(err)
+	}
+	if err := transferAsset(stub, swap.CounterpartyId, swap.CounterpartyAssetId, swap.ProposerId); err != nil {
+		return errorResponse(err)
+	}
+
+	swap.State = offerAccepted
+	swap.UpdatedAt = now
+	if err := putSwap(stub, swap); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitSwapEvent(stub, eventAssetSwapped, swap); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 取消资产互换：发起方撤回，或者对手方拒绝
+func swapCancel(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	swap, now, err := loadPendingSwap(stub, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 发起方本人撤回记为 cancelled，对手方本人拒绝记为 rejected
+	evtType := eventSwapCancelled
+	if _, err := getUserWithAccess(stub, swap.ProposerId); err == nil {
+		swap.State = offerCancelled
+	} else if _, err := getUserWithAccess(stub, swap.CounterpartyId); err == nil {
+		swap.State = offerRejected
+		evtType = eventSwapRejected
+	} else {
+		return shim.Error(err.Error())
+	}
+
+	swap.UpdatedAt = now
+	if err := putSwap(stub, swap); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitSwapEvent(stub, evtType, swap); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 资产互换查询
+func querySwap(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	swapId := args[0]
+	if swapId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	swapBytes, err := stub.GetState(constructSwapKey(swapId))
+	if err != nil || len(swapBytes) == 0 {
+		return shim.Error("swap not found")
+	}
+
+	return shim.Success(swapBytes)
+}
+
+// 接受、取消共用的参数检查：参数只有互换 id，且互换必须处于 pending 状态
+func loadPendingSwap(stub shim.ChaincodeStubInterface, args []string) (*AssetSwap, time.Time, error) {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return nil, time.Time{}, fmt.Errorf("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	swapId := args[0]
+	if swapId == "" {
+		return nil, time.Time{}, fmt.Errorf("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	swap, err := getSwap(stub, swapId)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if swap.State != offerPending {
+		return nil, time.Time{}, fmt.Errorf("swap is %s", swap.State)
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return swap, now, nil
+}
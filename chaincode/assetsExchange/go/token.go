@@ -0,0 +1,255 @@
+package main
+
+// 结算代币：简单的同质化余额账本，用于资产买卖的链上付款（buyAsset），使链上成交与付款可以对账
+// 金额为整数，单位为代币的最小单位（如分），避免浮点数在各背书节点上产生不一致
+// 余额以 balance_用户id 存储，发行总量以 token_supply 存储，只有发行方可以铸造
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	tokenSupplyKey = "token_supply"
+)
+
+// TokenBalance 余额查询的结果
+type TokenBalance struct {
+	UserId  string `json:"user_id"`
+	Balance int64  `json:"balance"`
+}
+
+// 以 balance_ 开头的，认为是代币余额
+func constructBalanceKey(userId string) string {
+	return fmt.Sprintf("balance_%s", userId)
+}
+
+// 解析代币数量，必须是正整数
+func parseTokenAmount(amountStr string) (int64, error) {
+	amount, err := strconv.ParseInt(amountStr, 10, 64)
+	if err != nil || amount <= 0 {
+		return 0, fmt.Errorf("invalid amount: %s", amountStr)
+	}
+
+	return amount, nil
+}
+
+// 读取整数状态，不存在时为 0
+func getInt64State(stub shim.ChaincodeStubInterface, key string) (int64, error) {
+	valueBytes, err := stub.GetState(key)
+	if err != nil {
+		return 0, fmt.Errorf("get %s error: %s", key, err)
+	}
+	if len(valueBytes) == 0 {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseInt(string(valueBytes), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s error: %s", key, err)
+	}
+
+	return value, nil
+}
+
+func putInt64State(stub shim.ChaincodeStubInterface, key string, value int64) error {
+	if err := stub.PutState(key, []byte(strconv.FormatInt(value, 10))); err != nil {
+		return fmt.Errorf("save %s error: %s", key, err)
+	}
+
+	return nil
+}
+
+// 保存用户的代币余额，余额键的修改需要用户组织背书
+func putBalance(stub shim.ChaincodeStubInterface, userId string, balance int64) error {
+	user, err := getUser(stub, userId)
+	if err != nil {
+		return err
+	}
+	if err := putInt64State(stub, constructBalanceKey(userId), balance); err != nil {
+		return err
+	}
+
+	return setKeyEndorsement(stub, constructBalanceKey(userId), user.MspId)
+}
+
+// 代币从 fromId 转给 toId，不校验调用者身份和冻结状态，由调用方负责
+func moveTokens(stub shim.ChaincodeStubInterface, fromId, toId string, amount int64) error {
+	if fromId == toId {
+		return fmt.Errorf("cannot transfer tokens to self")
+	}
+
+	if err := debitTokens(stub, fromId, amount); err != nil {
+		return err
+	}
+
+	return creditTokens(stub, toId, amount)
+}
+
+// 从用户余额中扣除代币，余额不足时返回错误
+// 同一交易中对同一用户只能调用一次，Fabric 在交易内读不到本交易的写入
+func debitTokens(stub shim.ChaincodeStubInterface, userId string, amount int64) error {
+	balance, err := getInt64State(stub, constructBalanceKey(userId))
+	if err != nil {
+		return err
+	}
+	if balance < amount {
+		return fmt.Errorf("insufficient balance: %s has %d, needs %d", userId, balance, amount)
+	}
+
+	return putBalance(stub, userId, balance-amount)
+}
+
+// 向用户余额中增加代币，同一交易中对同一用户只能调用一次
+func creditTokens(stub shim.ChaincodeStubInterface, userId string, amount int64) error {
+	balance, err := getInt64State(stub, constructBalanceKey(userId))
+	if err != nil {
+		return err
+	}
+	if balance > math.MaxInt64-amount {
+		return fmt.Errorf("balance overflow")
+	}
+
+	return putBalance(stub, userId, balance+amount)
+}
+
+// 铸造代币，只有发行方可以操作
+func tokenMint(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：接收者 id、数量
+	if len(args) != 2 {
+		return shim.Error("not enough args")
+	}
+	if !isIssuer(stub) {
+		return shim.Error("permission denied: issuer only")
+	}
+
+	// 2：验证参数的正确性
+	userId := args[0]
+	if userId == "" {
+		return shim.Error("invalid args")
+	}
+	amount, err := parseTokenAmount(args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 3：验证数据是否存在
+	if _, err := getActiveUser(stub, userId); err != nil {
+		return shim.Error(err.Error())
+	}
+	supply, err := getInt64State(stub, tokenSupplyKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	balance, err := getInt64State(stub, constructBalanceKey(userId))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	// 余额不会超过发行总量，只需要检查发行总量
+	if supply > math.MaxInt64-amount {
+		return shim.Error("token supply overflow")
+	}
+
+	// 4： 状态写入
+	if err := putInt64State(stub, tokenSupplyKey, supply+amount); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putBalance(stub, userId, balance+amount); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:   eventTokenMinted,
+		To:     userId,
+		Amount: amount,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 代币转账，只有转出者本人或管理员可以操作
+func tokenTransfer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：转出者 id、接收者 id、数量
+	if len(args) != 3 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	fromId := args[0]
+	toId := args[1]
+	if fromId == "" || toId == "" || fromId == toId {
+		return shim.Error("invalid args")
+	}
+	amount, err := parseTokenAmount(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 3：验证数据是否存在
+	from, err := getUserWithAccess(stub, fromId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkUserNotFrozen(stub, from); err != nil {
+		return errorResponse(err)
+	}
+	if _, err := getActiveUser(stub, toId); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 4： 状态写入
+	if err := moveTokens(stub, fromId, toId, amount); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:   eventTokenTransferred,
+		From:   fromId,
+		To:     toId,
+		Amount: amount,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 代币余额查询
+func balanceOf(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	userId := args[0]
+	if userId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在，销户的用户仍然可以查询余额
+	if _, err := getUser(stub, userId); err != nil {
+		return shim.Error(err.Error())
+	}
+	balance, err := getInt64State(stub, constructBalanceKey(userId))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	balanceBytes, err := json.Marshal(&TokenBalance{
+		UserId:  userId,
+		Balance: balance,
+	})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(balanceBytes)
+}
@@ -0,0 +1,336 @@
+package main
+
+// 两步转让：出让者发起转让要约，受让者接受后资产才真正转移
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// 转让要约的状态
+const (
+	offerPending   = "pending"
+	offerAccepted  = "accepted"
+	offerRejected  = "rejected"
+	offerCancelled = "cancelled"
+)
+
+// TransferOffer 资产转让要约
+type TransferOffer struct {
+	Id          string    `json:"id"`              // 要约 id，即发起要约的交易 id
+	AssetId     string    `json:"asset_id"`        // 被转让的资产
+	ProposerId  string    `json:"proposer_id"`     // 出让者
+	RecipientId string    `json:"recipient_id"`    // 受让者
+	State       string    `json:"state"`           // pending / accepted / rejected / cancelled
+	Expiry      time.Time `json:"expiry"`          // 过期时间，过期后不能再接受
+	Price       int64     `json:"price,omitempty"` // 转让价格，不为 0 时受让者通过 buyAsset 付款并接受
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// 以 offer_ 开头的，认为是转让要约
+func constructOfferKey(offerId string) string {
+	return fmt.Sprintf("offer_%s", offerId)
+}
+
+// 读取转让要约
+func getOffer(stub shim.ChaincodeStubInterface, offerId string) (*TransferOffer, error) {
+	offerBytes, err := stub.GetState(constructOfferKey(offerId))
+	if err != nil || len(offerBytes) == 0 {
+		return nil, fmt.Errorf("offer not found")
+	}
+
+	offer := new(TransferOffer)
+	if err := json.Unmarshal(offerBytes, offer); err != nil {
+		return nil, fmt.Errorf("unmarshal offer error: %s", err)
+	}
+
+	return offer, nil
+}
+
+// 保存转让要约
+func putOffer(stub shim.ChaincodeStubInterface, offer *TransferOffer) error {
+	offerBytes, err := json.Marshal(offer)
+	if err != nil {
+		return fmt.Errorf("marshal offer error: %s", err)
+	}
+	if err := stub.PutState(constructOfferKey(offer.Id), offerBytes); err != nil {
+		return fmt.Errorf("save offer error: %s", err)
+	}
+
+	return nil
+}
+
+// 发出转让要约相关的事件，接受要约即资产转让，事件类型为 AssetTransferred
+func emitOfferEvent(stub shim.ChaincodeStubInterface, evtType string, offer *TransferOffer) error {
+	return emitEvent(stub, &ChaincodeEvent{
+		Type:    evtType,
+		AssetId: offer.AssetId,
+		From:    offer.ProposerId,
+		To:      offer.RecipientId,
+		RefId:   offer.Id,
+	})
+}
+
+// 发起转让要约
+func transferOffer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数，价格可以不传
+	if len(args) != 4 && len(args) != 5 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	ownerId := args[0]
+	assetId := args[1]
+	recipientId := args[2]
+	if ownerId == "" || assetId == "" || recipientId == "" || ownerId == recipientId {
+		return shim.Error("invalid args")
+	}
+	// 过期时间为 RFC3339 格式，如 2020-05-01T00:00:00+08:00
+	expiry, err := time.Parse(time.RFC3339, args[3])
+	if err != nil {
+		return shim.Error(fmt.Sprintf("invalid expiry: %s", err))
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !expiry.After(now) {
+		return shim.Error("expiry must be in the future")
+	}
+	price := int64(0)
+	if len(args) == 5 && args[4] != "" {
+		if price, err = parseTokenAmount(args[4]); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// 3：验证数据是否存在
+	owner, err := getUserWithAccess(stub, ownerId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkNotMultisig(owner); err != nil {
+		return shim.Error(err.Error())
+	}
+	if _, err := getUser(stub, recipientId); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkAssetOwner(stub, ownerId, assetId); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkNotAuctioned(stub, assetId, ""); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 4： 状态写入
+	offer := &TransferOffer{
+		Id:          stub.GetTxID(),
+		AssetId:     assetId,
+		ProposerId:  ownerId,
+		RecipientId: recipientId,
+		State:       offerPending,
+		Expiry:      expiry.UTC(),
+		Price:       price,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := putOffer(stub, offer); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitOfferEvent(stub, eventTransferOffered, offer); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 返回要约 id，受让者凭此接受或拒绝
+	return shim.Success([]byte(offer.Id))
+}
+
+// 受让者接受转让要约，资产在此时转移
+func transferAccept(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	offer, now, err := loadPendingOffer(stub, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 只有受让者本人或管理员可以接受
+	if _, err := getUserWithAccess(stub, offer.RecipientId); err != nil {
+		return shim.Error(err.Error())
+	}
+	if !now.Before(offer.Expiry) {
+		return shim.Error("offer expired")
+	}
+	if offer.Price != 0 {
+		return shim.Error("offer has a price, use buyAsset")
+	}
+
+	// 出让者在要约期间可能已经处置了该资产，transferAsset 会再次校验所有权
+	if err := transferAsset(stub, offer.ProposerId, offer.AssetId, offer.RecipientId); err != nil {
+		return errorResponse(err)
+	}
+
+	offer.State = offerAccepted
+	offer.UpdatedAt = now
+	if err := putOffer(stub, offer); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitOfferEvent(stub, eventAssetTransferred, offer); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 受让者按要约价格购买资产：代币从受让者转给出让者，资产从出让者转给受让者，在同一交易中完成
+func buyAsset(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	offer, now, err := loadPendingOffer(stub, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 只有受让者本人或管理员可以购买
+	buyer, err := getUserWithAccess(stub, offer.RecipientId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !now.Before(offer.Expiry) {
+		return shim.Error("offer expired")
+	}
+	if offer.Price == 0 {
+		return shim.Error("offer has no price, use transferAccept")
+	}
+	if err := checkUserNotFrozen(stub, buyer); err != nil {
+		return errorResponse(err)
+	}
+
+	// 付款和交割任何一步失败，整个交易都不会生效
+	if err := moveTokens(stub, offer.RecipientId, offer.ProposerId, offer.Price); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := transferAssetForPrice(stub, offer.ProposerId, offer.AssetId, offer.RecipientId, offer.Price); err != nil {
+		return errorResponse(err)
+	}
+
+	offer.State = offerAccepted
+	offer.UpdatedAt = now
+	if err := putOffer(stub, offer); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:    eventAssetSold,
+		AssetId: offer.AssetId,
+		From:    offer.ProposerId,
+		To:      offer.RecipientId,
+		Amount:  offer.Price,
+		RefId:   offer.Id,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 受让者拒绝转让要约
+func transferReject(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	offer, now, err := loadPendingOffer(stub, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 只有受让者本人或管理员可以拒绝
+	if _, err := getUserWithAccess(stub, offer.RecipientId); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	offer.State = offerRejected
+	offer.UpdatedAt = now
+	if err := putOffer(stub, offer); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitOfferEvent(stub, eventTransferRejected, offer); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 出让者撤回转让要约
+func transferCancel(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	offer, now, err := loadPendingOffer(stub, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 只有出让者本人或管理员可以撤回
+	if _, err := getUserWithAccess(stub, offer.ProposerId); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	offer.State = offerCancelled
+	offer.UpdatedAt = now
+	if err := putOffer(stub, offer); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := emitOfferEvent(stub, eventTransferCancelled, offer); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 转让要约查询
+func queryTransferOffer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	offerId := args[0]
+	if offerId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	offerBytes, err := stub.GetState(constructOfferKey(offerId))
+	if err != nil || len(offerBytes) == 0 {
+		return shim.Error("offer not found")
+	}
+
+	return shim.Success(offerBytes)
+}
+
+// 接受、拒绝、撤回共用的参数检查：参数只有要约 id，且要约必须处于 pending 状态
+func loadPendingOffer(stub shim.ChaincodeStubInterface, args []string) (*TransferOffer, time.Time, error) {
+	// 1：检查参数的个数
+	if len(args) != 1 {
+		return nil, time.Time{}, fmt.Errorf("not enough args")
+	}
+
+	// 2：验证参数的正确性
+	offerId := args[0]
+	if offerId == "" {
+		return nil, time.Time{}, fmt.Errorf("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	offer, err := getOffer(stub, offerId)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if offer.State != offerPending {
+		return nil, time.Time{}, fmt.Errorf("offer is %s", offer.State)
+	}
+
+	now, err := getTxTime(stub)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return offer, now, nil
+}
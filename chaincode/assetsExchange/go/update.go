@@ -0,0 +1,135 @@
+package main
+
+// 资产信息更正：拥有者或开户员可以修改资产的名称和属性，必须说明原因
+// 每次修改写入一条 update 类型的资产变更记录，记录修改前后的值，可通过 queryAssetHistory 按 update 类型查询
+// 按类别登记的资产修改 Attributes，按资产类别重新校验；旧版本登记的资产修改 Metadata
+// 资产池没有类别，只能修改名称；冻结中的资产不能修改
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// AssetUpdate 资产信息的修改，只填写修改过的字段
+type AssetUpdate struct {
+	Reason         string                 `json:"reason"`
+	PrevName       string                 `json:"prev_name,omitempty"`
+	Name           string                 `json:"name,omitempty"`
+	PrevAttributes map[string]interface{} `json:"prev_attributes,omitempty"`
+	Attributes     map[string]interface{} `json:"attributes,omitempty"`
+	PrevMetadata   string                 `json:"prev_metadata,omitempty"`
+	Metadata       string                 `json:"metadata,omitempty"`
+	UpdatedByMspId string                 `json:"updated_by_msp_id"`
+	UpdatedBy      string                 `json:"updated_by"` // 操作者证书的 Subject
+}
+
+// 修改资产的名称和属性
+func assetUpdate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 1：检查参数的个数：资产 id、名称、属性（JSON 对象）、修改原因
+	if len(args) != 4 {
+		return shim.Error("not enough args")
+	}
+
+	// 2：验证参数的正确性，名称和属性为空表示不修改
+	assetId := args[0]
+	name := args[1]
+	attrsJson := args[2]
+	reason := args[3]
+	if assetId == "" || reason == "" || (name == "" && attrsJson == "") {
+		return shim.Error("invalid args")
+	}
+
+	// 3：验证数据是否存在
+	asset, err := getAsset(stub, assetId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	// 只有资产拥有者本人、开户员或管理员可以修改
+	owner, err := getUser(stub, asset.Owner)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !callerHasRole(stub, roleRegistrar) {
+		if err := checkUserAccess(stub, owner); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	if err := checkNotFrozen(stub, asset, owner); err != nil {
+		return errorResponse(err)
+	}
+	mspId, subject, err := getCallerIdentity(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	update := &AssetUpdate{
+		Reason:         reason,
+		UpdatedByMspId: mspId,
+		UpdatedBy:      subject,
+	}
+	if name != "" && name != asset.Name {
+		update.PrevName = asset.Name
+		update.Name = name
+		asset.Name = name
+	}
+	if attrsJson != "" {
+		switch {
+		case asset.ClassId == poolClassId:
+			return shim.Error("pool attributes cannot be updated")
+		case asset.ClassId == "":
+			// 旧版本登记的资产没有类别，属性保存在 Metadata 中
+			if attrsJson != asset.Metadata {
+				update.PrevMetadata = asset.Metadata
+				update.Metadata = attrsJson
+				asset.Metadata = attrsJson
+			}
+		default:
+			class, err := getAssetClass(stub, asset.ClassId)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			attrs, err := class.parseAttributes(attrsJson)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			// map 序列化时按 key 排序，比较序列化结果即可判断是否有变化
+			prevBytes, _ := json.Marshal(asset.Attributes)
+			attrsBytes, _ := json.Marshal(attrs)
+			if string(prevBytes) != string(attrsBytes) {
+				update.PrevAttributes = asset.Attributes
+				update.Attributes = attrs
+				asset.Attributes = attrs
+			}
+		}
+	}
+	if update.Name == "" && update.Attributes == nil && update.Metadata == "" {
+		return shim.Error("nothing changed")
+	}
+
+	// 4： 状态写入
+	if err := putAsset(stub, asset); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putAssetHistory(stub, &AssetHistory{
+		AssetId:        assetId,
+		OriginOwnerId:  asset.Owner,
+		CurrentOwnerId: asset.Owner,
+		Action:         historyUpdate,
+		Update:         update,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if err := emitEvent(stub, &ChaincodeEvent{
+		Type:    eventAssetUpdated,
+		AssetId: assetId,
+		From:    asset.Owner,
+		Reason:  reason,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
@@ -7,6 +7,15 @@
 # This is a collection of bash functions used by different scripts
 
 ORDERER_CA=/opt/gopath/src/github.com/hyperledger/fabric/peer/crypto/ordererOrganizations/example.com/orderers/orderer.example.com/msp/tlscacerts/tlsca.example.com-cert.pem
+# 资产保密部分的私有数据集合定义，随链码目录挂载到 cli 容器中，加入 Org3 后升级链码时使用包含 Org3 的定义
+COLLECTIONS_CONFIG=/opt/gopath/src/github.com/chaincode/assetsExchange/go/collections_config.json
+COLLECTIONS_CONFIG_ORG3=/opt/gopath/src/github.com/chaincode/assetsExchange/go/collections_config_org3.json
+# 链码实例化时授予 admin 角色的身份，默认为 org1 fabric-ca 的引导管理员 admin（fabric-ca-server -b admin:adminpw），
+# app 以 admin 的 enrollment id 和 secret 调用时即为链码管理员；通道管理员 Admin@org1 提交实例化交易，但不会被授予角色
+# 证书 Subject 可以在 admin 通过 app 登录后用 GET /roles 确认
+CC_ADMIN_MSPID="Org1MSP"
+CC_ADMIN_SUBJECT="CN=admin,OU=client"
+CC_INIT_ARGS='{"Args":["init","[{\"msp_id\":\"'"${CC_ADMIN_MSPID}"'\",\"subject\":\"'"${CC_ADMIN_SUBJECT}"'\",\"roles\":[\"admin\",\"registrar\"]}]"]}'
 PEER0_ORG1_CA=/opt/gopath/src/github.com/hyperledger/fabric/peer/crypto/peerOrganizations/org1.example.com/peers/peer0.org1.example.com/tls/ca.crt
 PEER0_ORG2_CA=/opt/gopath/src/github.com/hyperledger/fabric/peer/crypto/peerOrganizations/org2.example.com/peers/peer0.org2.example.com/tls/ca.crt
 PEER0_ORG3_CA=/opt/gopath/src/github.com/hyperledger/fabric/peer/crypto/peerOrganizations/org3.example.com/peers/peer0.org3.example.com/tls/ca.crt
@@ -139,12 +148,12 @@ instantiateChaincode() {
   # the "-o" option
   if [ -z "$CORE_PEER_TLS_ENABLED" -o "$CORE_PEER_TLS_ENABLED" = "false" ]; then
     set -x
-    peer chaincode instantiate -o orderer.example.com:7050 -C $CHANNEL_NAME -n assetscc -l ${LANGUAGE} -v ${VERSION} -c '{"Args":["init"]}' -P "OR ('Org1MSP.peer','Org2MSP.peer')"  >&log.txt
+    peer chaincode instantiate -o orderer.example.com:7050 -C $CHANNEL_NAME -n assetscc -l ${LANGUAGE} -v ${VERSION} -c "${CC_INIT_ARGS}" -P "OR ('Org1MSP.peer','Org2MSP.peer')" --collections-config $COLLECTIONS_CONFIG >&log.txt
     res=$?
     set +x
   else
     set -x
-    peer chaincode instantiate -o orderer.example.com:7050 --tls $CORE_PEER_TLS_ENABLED --cafile $ORDERER_CA -C $CHANNEL_NAME -n assetscc -l ${LANGUAGE} -v ${VERSION} -c '{"Args":["init"]}' -P "OR ('Org1MSP.peer','Org2MSP.peer')"  >&log.txt
+    peer chaincode instantiate -o orderer.example.com:7050 --tls $CORE_PEER_TLS_ENABLED --cafile $ORDERER_CA -C $CHANNEL_NAME -n assetscc -l ${LANGUAGE} -v ${VERSION} -c "${CC_INIT_ARGS}" -P "OR ('Org1MSP.peer','Org2MSP.peer')" --collections-config $COLLECTIONS_CONFIG >&log.txt
     res=$?
     set +x
   fi
@@ -160,7 +169,7 @@ upgradeChaincode() {
   setGlobals $PEER $ORG
 
   set -x
-  peer chaincode upgrade -o orderer.example.com:7050 --tls $CORE_PEER_TLS_ENABLED --cafile $ORDERER_CA -C $CHANNEL_NAME -n assetscc -v 2.0 -c '{"Args":["init"]}' -P "OR ('Org1MSP.peer','Org2MSP.peer','Org3MSP.peer')"
+  peer chaincode upgrade -o orderer.example.com:7050 --tls $CORE_PEER_TLS_ENABLED --cafile $ORDERER_CA -C $CHANNEL_NAME -n assetscc -v 2.0 -c "${CC_INIT_ARGS}" -P "OR ('Org1MSP.peer','Org2MSP.peer','Org3MSP.peer')" --collections-config $COLLECTIONS_CONFIG_ORG3
   res=$?
   set +x
   cat log.txt